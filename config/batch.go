@@ -0,0 +1,37 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+type Batch struct {
+	MaxLines      int
+	MaxBytes      uint
+	FlushInterval time.Duration
+}
+
+func initBatch() {
+	viper.SetDefault("batch.maxLines", 5000)
+	_ = viper.BindEnv("batch.maxLines", "BLM_BATCH_MAX_LINES")
+	pflag.Int("batch.maxLines", 5000, `maximum lines of a statsd or opentsdb_telnet write batch. Env "BLM_BATCH_MAX_LINES"`)
+
+	viper.SetDefault("batch.maxBytes", "4MB")
+	_ = viper.BindEnv("batch.maxBytes", "BLM_BATCH_MAX_BYTES")
+	pflag.String("batch.maxBytes", "4MB", `maximum size of a statsd or opentsdb_telnet write batch(KB MB GB). Env "BLM_BATCH_MAX_BYTES"`)
+
+	viper.SetDefault("batch.flushInterval", time.Second)
+	_ = viper.BindEnv("batch.flushInterval", "BLM_BATCH_FLUSH_INTERVAL")
+	pflag.Duration("batch.flushInterval", time.Second, `maximum time lines wait in a statsd or opentsdb_telnet write batch. Env "BLM_BATCH_FLUSH_INTERVAL"`)
+}
+
+func (b *Batch) setValue() {
+	b.MaxLines = viper.GetInt("batch.maxLines")
+	b.MaxBytes = viper.GetSizeInBytes("batch.maxBytes")
+	b.FlushInterval = viper.GetDuration("batch.flushInterval")
+	if b.MaxLines <= 0 || b.MaxBytes == 0 || b.FlushInterval <= 0 {
+		panic("batch.maxLines, batch.maxBytes and batch.flushInterval must be positive")
+	}
+}
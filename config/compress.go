@@ -0,0 +1,31 @@
+package config
+
+import (
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+type Compress struct {
+	// ResponseGzip compresses responses with gzip when the client accepts it
+	ResponseGzip bool
+	// MaxDecompressedSize is the maximum size in bytes of a decompressed request body
+	MaxDecompressedSize int64
+}
+
+func initCompress() {
+	viper.SetDefault("compress.responseGzip", false)
+	_ = viper.BindEnv("compress.responseGzip", "BLM_COMPRESS_RESPONSE_GZIP")
+	pflag.Bool("compress.responseGzip", false, `enable gzip compression of responses. Env "BLM_COMPRESS_RESPONSE_GZIP"`)
+
+	viper.SetDefault("compress.maxDecompressedSize", 64<<20)
+	_ = viper.BindEnv("compress.maxDecompressedSize", "BLM_COMPRESS_MAX_DECOMPRESSED_SIZE")
+	pflag.Int64("compress.maxDecompressedSize", 64<<20, `maximum size in bytes of a decompressed request body. Env "BLM_COMPRESS_MAX_DECOMPRESSED_SIZE"`)
+}
+
+func (c *Compress) setValue() {
+	c.ResponseGzip = viper.GetBool("compress.responseGzip")
+	c.MaxDecompressedSize = viper.GetInt64("compress.maxDecompressedSize")
+	if c.MaxDecompressedSize <= 0 {
+		panic("compress.maxDecompressedSize must be positive")
+	}
+}
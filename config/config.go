@@ -15,9 +15,22 @@ type Config struct {
 	Debug         bool
 	Port          int
 	LogLevel      string
-	SSl           SSl
-	Log           Log
-	Pool          Pool
+	// TrustedProxies holds the addresses and cidrs of the reverse proxies whose X-Forwarded-For is trusted
+	TrustedProxies []string
+	SSl            SSl
+	Log            Log
+	Pool           Pool
+	Restful        Restful
+	Compress       Compress
+	WriteBuffer    WriteBuffer
+	Batch          Batch
+	Monitor        Monitor
+	Plugin         Plugin
+	Shutdown       Shutdown
+	Limit          Limit
+	Token          Token
+	Login          Login
+	WatchConfig    bool
 }
 
 var (
@@ -57,19 +70,37 @@ func Init() {
 			panic(err)
 		}
 	}
-	Conf = &Config{
-		Debug:         viper.GetBool("debug"),
-		Port:          viper.GetInt("port"),
-		LogLevel:      viper.GetString("logLevel"),
-		TaosConfigDir: viper.GetString("taosConfigDir"),
+	Conf = load()
+}
+
+// load builds the configuration from viper.
+func load() *Config {
+	conf := &Config{
+		Debug:          viper.GetBool("debug"),
+		Port:           viper.GetInt("port"),
+		LogLevel:       viper.GetString("logLevel"),
+		TaosConfigDir:  viper.GetString("taosConfigDir"),
+		WatchConfig:    viper.GetBool("watchConfig"),
+		TrustedProxies: viper.GetStringSlice("trustedProxies"),
 	}
-	Conf.Log.setValue()
-	Conf.Cors.setValue()
-	Conf.SSl.setValue()
-	Conf.Pool.setValue()
+	conf.Log.setValue()
+	conf.Cors.setValue()
+	conf.SSl.setValue()
+	conf.Pool.setValue()
+	conf.Restful.setValue()
+	conf.Compress.setValue()
+	conf.WriteBuffer.setValue()
+	conf.Batch.setValue()
+	conf.Monitor.setValue()
+	conf.Plugin.setValue()
+	conf.Shutdown.setValue()
+	conf.Limit.setValue()
+	conf.Token.setValue()
+	conf.Login.setValue()
+	return conf
 }
 
-//arg > file > env
+// arg > file > env
 func init() {
 	viper.SetDefault("debug", false)
 	_ = viper.BindEnv("debug", "BLM_DEBUG")
@@ -87,10 +118,28 @@ func init() {
 	_ = viper.BindEnv("taosConfigDir", "BLM_TAOS_CONFIG_FILE")
 	pflag.String("taosConfigDir", "", `load taos client config path. Env "BLM_TAOS_CONFIG_FILE"`)
 
+	viper.SetDefault("trustedProxies", []string{})
+	_ = viper.BindEnv("trustedProxies", "BLM_TRUSTED_PROXIES")
+	pflag.StringSlice("trustedProxies", []string{}, `addresses and cidrs of reverse proxies whose X-Forwarded-For and X-Real-IP headers give the client ip, the headers are ignored by default. Env "BLM_TRUSTED_PROXIES"`)
+
+	viper.SetDefault("watchConfig", false)
+	_ = viper.BindEnv("watchConfig", "BLM_WATCH_CONFIG")
+	pflag.Bool("watchConfig", false, `reload the config file when it changes, SIGHUP always reloads it. Env "BLM_WATCH_CONFIG"`)
+
 	initLog()
 	initSSL()
 	initCors()
 	initPool()
+	initRestful()
+	initCompress()
+	initWriteBuffer()
+	initBatch()
+	initMonitor()
+	initPlugin()
+	initShutdown()
+	initLimit()
+	initToken()
+	initLogin()
 
 	err := viper.BindPFlags(pflag.CommandLine)
 	if err != nil {
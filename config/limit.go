@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+type Limit struct {
+	Enable bool
+	User   LimitRule
+	IP     LimitRule
+}
+
+// LimitRule limits the requests of one user or client ip, zero means no limit.
+type LimitRule struct {
+	Rate        float64
+	Burst       int
+	BytesRate   uint
+	Concurrency int
+}
+
+func initLimit() {
+	viper.SetDefault("limit.enable", false)
+	_ = viper.BindEnv("limit.enable", "BLM_LIMIT_ENABLE")
+	pflag.Bool("limit.enable", false, `enable the rate limits of restful and write requests. Env "BLM_LIMIT_ENABLE"`)
+
+	initLimitRule("user", "each TDengine user")
+	initLimitRule("ip", "each client ip")
+}
+
+func initLimitRule(name, who string) {
+	key := "limit." + name + "."
+	env := "BLM_LIMIT_" + strings.ToUpper(name) + "_"
+
+	viper.SetDefault(key+"rate", 0)
+	_ = viper.BindEnv(key+"rate", env+"RATE")
+	pflag.Float64(key+"rate", 0, fmt.Sprintf(`requests per second of %s, 0 means no limit. Env "%sRATE"`, who, env))
+
+	viper.SetDefault(key+"burst", 0)
+	_ = viper.BindEnv(key+"burst", env+"BURST")
+	pflag.Int(key+"burst", 0, fmt.Sprintf(`requests %s can send at once above the rate, 0 means one second of the rate. Env "%sBURST"`, who, env))
+
+	viper.SetDefault(key+"bytesRate", "0")
+	_ = viper.BindEnv(key+"bytesRate", env+"BYTES_RATE")
+	pflag.String(key+"bytesRate", "0", fmt.Sprintf(`request body bytes per second of %s(KB MB GB), 0 means no limit. Env "%sBYTES_RATE"`, who, env))
+
+	viper.SetDefault(key+"concurrency", 0)
+	_ = viper.BindEnv(key+"concurrency", env+"CONCURRENCY")
+	pflag.Int(key+"concurrency", 0, fmt.Sprintf(`concurrent requests of %s, 0 means no limit. Env "%sCONCURRENCY"`, who, env))
+}
+
+func (l *Limit) setValue() {
+	l.Enable = viper.GetBool("limit.enable")
+	l.User.setValue("user")
+	l.IP.setValue("ip")
+}
+
+func (r *LimitRule) setValue(name string) {
+	key := "limit." + name + "."
+	r.Rate = viper.GetFloat64(key + "rate")
+	r.Burst = viper.GetInt(key + "burst")
+	r.BytesRate = viper.GetSizeInBytes(key + "bytesRate")
+	r.Concurrency = viper.GetInt(key + "concurrency")
+	if r.Rate < 0 || r.Burst < 0 || r.Concurrency < 0 {
+		panic(fmt.Sprintf("%srate, %sburst and %sconcurrency must not be negative", key, key, key))
+	}
+}
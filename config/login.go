@@ -0,0 +1,46 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+type Login struct {
+	FailureCache time.Duration
+	MaxFailures  int
+	Lockout      time.Duration
+	LockUser     bool
+}
+
+func initLogin() {
+	viper.SetDefault("login.failureCache", 10*time.Second)
+	_ = viper.BindEnv("login.failureCache", "BLM_LOGIN_FAILURE_CACHE")
+	pflag.Duration("login.failureCache", 10*time.Second, `time to reject a user and password which taosd refused without asking taosd again. Env "BLM_LOGIN_FAILURE_CACHE"`)
+
+	viper.SetDefault("login.maxFailures", 5)
+	_ = viper.BindEnv("login.maxFailures", "BLM_LOGIN_MAX_FAILURES")
+	pflag.Int("login.maxFailures", 5, `failed logins of a client ip before it is locked out, 0 disables the lockout. Env "BLM_LOGIN_MAX_FAILURES"`)
+
+	viper.SetDefault("login.lockout", 5*time.Minute)
+	_ = viper.BindEnv("login.lockout", "BLM_LOGIN_LOCKOUT")
+	pflag.Duration("login.lockout", 5*time.Minute, `window in which failed logins are counted and duration of the lockout. Env "BLM_LOGIN_LOCKOUT"`)
+
+	viper.SetDefault("login.lockUser", false)
+	_ = viper.BindEnv("login.lockUser", "BLM_LOGIN_LOCK_USER")
+	pflag.Bool("login.lockUser", false, `lock out a user from every client ip after login.maxFailures failed logins as well. Env "BLM_LOGIN_LOCK_USER"`)
+}
+
+func (l *Login) setValue() {
+	l.FailureCache = viper.GetDuration("login.failureCache")
+	l.MaxFailures = viper.GetInt("login.maxFailures")
+	l.Lockout = viper.GetDuration("login.lockout")
+	l.LockUser = viper.GetBool("login.lockUser")
+	if l.MaxFailures < 0 {
+		panic("login.maxFailures must not be negative")
+	}
+	if l.MaxFailures > 0 && l.Lockout <= 0 {
+		panic("login.lockout must be positive")
+	}
+}
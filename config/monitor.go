@@ -0,0 +1,50 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+type Monitor struct {
+	// WriteToTD enables writing the metrics of /metrics to a database of TDengine
+	WriteToTD bool
+	DB        string
+	User      string
+	Password  string
+	Interval  time.Duration
+}
+
+func initMonitor() {
+	viper.SetDefault("monitor.writeToTD", false)
+	_ = viper.BindEnv("monitor.writeToTD", "BLM_MONITOR_WRITE_TO_TD")
+	pflag.Bool("monitor.writeToTD", false, `write blm3 metrics to TDengine. Env "BLM_MONITOR_WRITE_TO_TD"`)
+
+	viper.SetDefault("monitor.db", "blm3_monitor")
+	_ = viper.BindEnv("monitor.db", "BLM_MONITOR_DB")
+	pflag.String("monitor.db", "blm3_monitor", `db name of blm3 metrics, created when not exists. Env "BLM_MONITOR_DB"`)
+
+	viper.SetDefault("monitor.user", "root")
+	_ = viper.BindEnv("monitor.user", "BLM_MONITOR_USER")
+	pflag.String("monitor.user", "root", `user to write blm3 metrics and check taosd. Env "BLM_MONITOR_USER"`)
+
+	viper.SetDefault("monitor.password", "taosdata")
+	_ = viper.BindEnv("monitor.password", "BLM_MONITOR_PASSWORD")
+	pflag.String("monitor.password", "taosdata", `password to write blm3 metrics and check taosd. Env "BLM_MONITOR_PASSWORD"`)
+
+	viper.SetDefault("monitor.interval", 30*time.Second)
+	_ = viper.BindEnv("monitor.interval", "BLM_MONITOR_INTERVAL")
+	pflag.Duration("monitor.interval", 30*time.Second, `interval of writing blm3 metrics. Env "BLM_MONITOR_INTERVAL"`)
+}
+
+func (m *Monitor) setValue() {
+	m.WriteToTD = viper.GetBool("monitor.writeToTD")
+	m.DB = viper.GetString("monitor.db")
+	m.User = viper.GetString("monitor.user")
+	m.Password = viper.GetString("monitor.password")
+	m.Interval = viper.GetDuration("monitor.interval")
+	if m.WriteToTD && m.Interval <= 0 {
+		panic("monitor.interval must be positive")
+	}
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+type Plugin struct {
+	// FailFast panics when a plugin fails to init or start, otherwise the plugin is marked failed
+	FailFast bool
+	// AdminUser is the taosd user allowed to start and stop plugins by the admin api
+	AdminUser string
+}
+
+func initPlugin() {
+	viper.SetDefault("plugin.failFast", true)
+	_ = viper.BindEnv("plugin.failFast", "BLM_PLUGIN_FAIL_FAST")
+	pflag.Bool("plugin.failFast", true, `exit when a plugin fails to start, otherwise the plugin is marked failed. Env "BLM_PLUGIN_FAIL_FAST"`)
+
+	viper.SetDefault("plugin.adminUser", "root")
+	_ = viper.BindEnv("plugin.adminUser", "BLM_PLUGIN_ADMIN_USER")
+	pflag.String("plugin.adminUser", "root", `taosd user allowed to start and stop plugins. Env "BLM_PLUGIN_ADMIN_USER"`)
+}
+
+func (p *Plugin) setValue() {
+	p.FailFast = viper.GetBool("plugin.failFast")
+	p.AdminUser = viper.GetString("plugin.adminUser")
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// lock guards the settings of Conf which a reload changes while blm3 is serving, they are read with GetPool, GetLogin,
+// GetToken and GetLimit and written with Update.
+var lock sync.RWMutex
+
+// Update applies the reloaded settings to Conf with f.
+func Update(f func(conf *Config)) {
+	lock.Lock()
+	defer lock.Unlock()
+	f(Conf)
+}
+
+func GetPool() Pool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return Conf.Pool
+}
+
+func GetLogin() Login {
+	lock.RLock()
+	defer lock.RUnlock()
+	return Conf.Login
+}
+
+func GetToken() Token {
+	lock.RLock()
+	defer lock.RUnlock()
+	return Conf.Token
+}
+
+func GetLimit() Limit {
+	lock.RLock()
+	defer lock.RUnlock()
+	return Conf.Limit
+}
+
+// Reload reads the configuration file again and returns the new configuration without changing Conf, command line
+// flags and environment variables still take precedence over the file.
+func Reload() (conf *Config, err error) {
+	defer func() {
+		// setValue panics on invalid values
+		if e := recover(); e != nil {
+			conf = nil
+			err = fmt.Errorf("invalid config: %v", e)
+		}
+	}()
+	err = viper.ReadInConfig()
+	if err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return nil, err
+		}
+	}
+	return load(), nil
+}
+
+// Diff returns the changed fields of two values of the same struct type as "name: old -> new", nested structs are
+// compared field by field and secrets are not printed.
+func Diff(prefix string, old, new interface{}) []string {
+	return diff(prefix, reflect.ValueOf(old), reflect.ValueOf(new))
+}
+
+func diff(name string, old, new reflect.Value) []string {
+	if old.Kind() == reflect.Ptr {
+		if old.IsNil() || new.IsNil() {
+			if old.IsNil() != new.IsNil() {
+				return []string{name + " changed"}
+			}
+			return nil
+		}
+		return diff(name, old.Elem(), new.Elem())
+	}
+	if old.Kind() == reflect.Struct {
+		var changes []string
+		t := old.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			fieldName := lowerFirst(field.Name)
+			if len(name) != 0 {
+				fieldName = name + "." + fieldName
+			}
+			changes = append(changes, diff(fieldName, old.Field(i), new.Field(i))...)
+		}
+		return changes
+	}
+	if reflect.DeepEqual(old.Interface(), new.Interface()) {
+		return nil
+	}
+	if isSecret(name) {
+		return []string{name + " changed"}
+	}
+	return []string{fmt.Sprintf("%s: %v -> %v", name, old.Interface(), new.Interface())}
+}
+
+func isSecret(name string) bool {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.ToLower(name)
+	return strings.Contains(name, "password") || strings.Contains(name, "token") || strings.Contains(name, "key") ||
+		strings.Contains(name, "secret")
+}
+
+// lowerFirst lowers the leading upper case letters like the config keys, "DB" becomes "db", "URLs" "urls" and
+// "TCPKeepAlive" "tcpKeepAlive".
+func lowerFirst(s string) string {
+	n := 0
+	for n < len(s) && s[n] >= 'A' && s[n] <= 'Z' {
+		n += 1
+	}
+	// keep the first letter of the next word, plural acronyms such as URLs are lowered
+	if n > 1 && n < len(s) && s[n:] != "s" {
+		n -= 1
+	}
+	return strings.ToLower(s[:n]) + s[n:]
+}
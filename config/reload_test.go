@@ -0,0 +1,32 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	type inner struct {
+		DB       string
+		Password string
+		URLs     []string
+	}
+	type conf struct {
+		LogLevel string
+		Interval time.Duration
+		Inner    inner
+		hidden   int
+	}
+	old := conf{LogLevel: "info", Interval: time.Second, Inner: inner{DB: "statsd", Password: "a", URLs: []string{"x"}}, hidden: 1}
+	assert.Empty(t, Diff("", old, old))
+	new := conf{LogLevel: "debug", Interval: time.Second, Inner: inner{DB: "statsd2", Password: "b", URLs: []string{"x", "y"}}, hidden: 2}
+	assert.Equal(t, []string{
+		"logLevel: info -> debug",
+		"inner.db: statsd -> statsd2",
+		"inner.password changed",
+		"inner.urls: [x] -> [x y]",
+	}, Diff("", old, new))
+	assert.Equal(t, []string{"statsd.logLevel: info -> debug"}, Diff("statsd", &old, &conf{LogLevel: "debug", Interval: time.Second, Inner: old.Inner}))
+}
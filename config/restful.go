@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+type Restful struct {
+	MaxRows      int
+	QueryTimeout time.Duration
+	Timezone     *time.Location
+	TimeLayout   string
+}
+
+var TimeLayouts = []string{"datetime", "rfc3339", "epoch", "epoch_s", "epoch_ms", "epoch_us", "epoch_ns"}
+
+func initRestful() {
+	viper.SetDefault("restful.maxRows", 0)
+	_ = viper.BindEnv("restful.maxRows", "BLM_RESTFUL_MAX_ROWS")
+	pflag.Int("restful.maxRows", 0, `max rows a restful query can return, 0 means no limit. Env "BLM_RESTFUL_MAX_ROWS"`)
+
+	viper.SetDefault("restful.queryTimeout", time.Duration(0))
+	_ = viper.BindEnv("restful.queryTimeout", "BLM_RESTFUL_QUERY_TIMEOUT")
+	pflag.Duration("restful.queryTimeout", 0, `max execution time of a restful query, 0 means no limit. Env "BLM_RESTFUL_QUERY_TIMEOUT"`)
+
+	viper.SetDefault("restful.timezone", "")
+	_ = viper.BindEnv("restful.timezone", "BLM_RESTFUL_TIMEZONE")
+	pflag.String("restful.timezone", "", `default IANA timezone of restful timestamps such as Asia/Shanghai, empty means the host timezone. Env "BLM_RESTFUL_TIMEZONE"`)
+
+	viper.SetDefault("restful.timeLayout", "datetime")
+	_ = viper.BindEnv("restful.timeLayout", "BLM_RESTFUL_TIME_LAYOUT")
+	pflag.String("restful.timeLayout", "datetime", `default layout of restful timestamps (datetime rfc3339 epoch epoch_s epoch_ms epoch_us epoch_ns). Env "BLM_RESTFUL_TIME_LAYOUT"`)
+}
+
+func (r *Restful) setValue() {
+	r.MaxRows = viper.GetInt("restful.maxRows")
+	r.QueryTimeout = viper.GetDuration("restful.queryTimeout")
+	tz := viper.GetString("restful.timezone")
+	if len(tz) == 0 {
+		r.Timezone = time.Local
+	} else {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			panic(err)
+		}
+		r.Timezone = loc
+	}
+	r.TimeLayout = viper.GetString("restful.timeLayout")
+	if !IsTimeLayout(r.TimeLayout) {
+		panic(fmt.Sprintf("unsupported restful.timeLayout %s", r.TimeLayout))
+	}
+}
+
+func IsTimeLayout(layout string) bool {
+	for _, l := range TimeLayouts {
+		if l == layout {
+			return true
+		}
+	}
+	return false
+}
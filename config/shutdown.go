@@ -0,0 +1,25 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+type Shutdown struct {
+	Timeout time.Duration
+}
+
+func initShutdown() {
+	viper.SetDefault("shutdown.timeout", 30*time.Second)
+	_ = viper.BindEnv("shutdown.timeout", "BLM_SHUTDOWN_TIMEOUT")
+	pflag.Duration("shutdown.timeout", 30*time.Second, `maximum time to finish in-flight requests and write the queued data of the plugins on shutdown. Env "BLM_SHUTDOWN_TIMEOUT"`)
+}
+
+func (s *Shutdown) setValue() {
+	s.Timeout = viper.GetDuration("shutdown.timeout")
+	if s.Timeout <= 0 {
+		panic("shutdown.timeout must be positive")
+	}
+}
@@ -1,16 +1,38 @@
 package config
 
 import (
+	"fmt"
+	"path"
+	"strings"
+
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 type SSl struct {
-	Enable   bool
-	CertFile string
-	KeyFile  string
+	Enable       bool
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	ClientAuth   string
+	// ClientUsers maps verified client certificates to TDengine users, the first match wins
+	ClientUsers []*CertUser
+}
+
+// CertUser is a TDengine user of the client certificates whose field matches Pattern.
+type CertUser struct {
+	// Field is cn, dns or email
+	Field    string
+	Pattern  string
+	User     string
+	Password string
 }
 
+const (
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
 func initSSL() {
 	viper.SetDefault("ssl.enable", false)
 	_ = viper.BindEnv("ssl.enable", "BLM_SSL_ENABLE")
@@ -23,10 +45,83 @@ func initSSL() {
 	viper.SetDefault("ssl.keyFile", "")
 	_ = viper.BindEnv("ssl.keyFile", "BLM_SSL_KEY_FILE")
 	pflag.String("ssl.keyFile", "", `ssl key file path. Env "BLM_SSL_KEY_FILE"`)
+
+	viper.SetDefault("ssl.clientCAFile", "")
+	_ = viper.BindEnv("ssl.clientCAFile", "BLM_SSL_CLIENT_CA_FILE")
+	pflag.String("ssl.clientCAFile", "", `CA bundle to verify client certificates, empty disables client certificates. Env "BLM_SSL_CLIENT_CA_FILE"`)
+
+	viper.SetDefault("ssl.clientAuth", ClientAuthOptional)
+	_ = viper.BindEnv("ssl.clientAuth", "BLM_SSL_CLIENT_AUTH")
+	pflag.String("ssl.clientAuth", ClientAuthOptional, `optional verifies client certificates when given, require rejects connections without one. Env "BLM_SSL_CLIENT_AUTH"`)
+
+	viper.SetDefault("ssl.clientUsers", []string{})
+	_ = viper.BindEnv("ssl.clientUsers", "BLM_SSL_CLIENT_USERS")
+	pflag.StringSlice("ssl.clientUsers", nil, `client certificate to user mapping, field=pattern:user:password with field cn dns or email. Env "BLM_SSL_CLIENT_USERS"`)
 }
 
 func (s *SSl) setValue() {
 	s.Enable = viper.GetBool("ssl.enable")
 	s.CertFile = viper.GetString("ssl.certFile")
 	s.KeyFile = viper.GetString("ssl.keyFile")
+	s.ClientCAFile = viper.GetString("ssl.clientCAFile")
+	s.ClientAuth = viper.GetString("ssl.clientAuth")
+	if s.ClientAuth != ClientAuthOptional && s.ClientAuth != ClientAuthRequire {
+		panic(fmt.Errorf("invalid ssl.clientAuth %s, expected optional or require", s.ClientAuth))
+	}
+	s.ClientUsers = nil
+	for _, item := range viper.GetStringSlice("ssl.clientUsers") {
+		sl := strings.SplitN(item, ":", 3)
+		kv := strings.SplitN(sl[0], "=", 2)
+		if len(sl) != 3 || len(kv) != 2 || len(kv[1]) == 0 || len(sl[1]) == 0 {
+			panic(fmt.Errorf("invalid ssl.clientUsers item, expected field=pattern:user:password"))
+		}
+		switch kv[0] {
+		case "cn", "dns", "email":
+		default:
+			panic(fmt.Errorf("invalid ssl.clientUsers field %s, expected cn dns or email", kv[0]))
+		}
+		if _, err := path.Match(kv[1], ""); err != nil {
+			panic(fmt.Errorf("invalid ssl.clientUsers pattern %s: %s", kv[1], err))
+		}
+		s.ClientUsers = append(s.ClientUsers, &CertUser{Field: kv[0], Pattern: kv[1], User: sl[1], Password: sl[2]})
+	}
+}
+
+// ListenerTLS is the tls of the tcp listener of a plugin, without its own certificate the one of the ssl section is
+// used.
+type ListenerTLS struct {
+	Enable       bool
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// InitListenerTLS registers the tls settings of a plugin listener as prefix.tls.*, env is the environment variable
+// prefix of the plugin such as BLM_STATSD.
+func InitListenerTLS(prefix, env string) {
+	viper.SetDefault(prefix+".tls.enable", false)
+	_ = viper.BindEnv(prefix+".tls.enable", env+"_TLS_ENABLE")
+	pflag.Bool(prefix+".tls.enable", false, fmt.Sprintf(`enable tls on the %s tcp listener. Env "%s_TLS_ENABLE"`, prefix, env))
+
+	viper.SetDefault(prefix+".tls.certFile", "")
+	_ = viper.BindEnv(prefix+".tls.certFile", env+"_TLS_CERT_FILE")
+	pflag.String(prefix+".tls.certFile", "", fmt.Sprintf(`%s tls cert file path, default ssl.certFile. Env "%s_TLS_CERT_FILE"`, prefix, env))
+
+	viper.SetDefault(prefix+".tls.keyFile", "")
+	_ = viper.BindEnv(prefix+".tls.keyFile", env+"_TLS_KEY_FILE")
+	pflag.String(prefix+".tls.keyFile", "", fmt.Sprintf(`%s tls key file path, default ssl.keyFile. Env "%s_TLS_KEY_FILE"`, prefix, env))
+
+	viper.SetDefault(prefix+".tls.clientCAFile", "")
+	_ = viper.BindEnv(prefix+".tls.clientCAFile", env+"_TLS_CLIENT_CA_FILE")
+	pflag.String(prefix+".tls.clientCAFile", "", fmt.Sprintf(`CA bundle which %s clients must present a certificate of, empty accepts any client. Env "%s_TLS_CLIENT_CA_FILE"`, prefix, env))
+}
+
+func (t *ListenerTLS) SetValue(prefix string) {
+	t.Enable = viper.GetBool(prefix + ".tls.enable")
+	t.CertFile = viper.GetString(prefix + ".tls.certFile")
+	t.KeyFile = viper.GetString(prefix + ".tls.keyFile")
+	t.ClientCAFile = viper.GetString(prefix + ".tls.clientCAFile")
+	if len(t.CertFile) == 0 != (len(t.KeyFile) == 0) {
+		panic(fmt.Errorf("%s.tls.certFile and %s.tls.keyFile must be set together", prefix, prefix))
+	}
 }
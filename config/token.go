@@ -0,0 +1,49 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+type Token struct {
+	Secret      string
+	KeyFile     string
+	Expire      time.Duration
+	RevokedFile string
+	Legacy      bool
+}
+
+func initToken() {
+	viper.SetDefault("token.secret", "")
+	_ = viper.BindEnv("token.secret", "BLM_TOKEN_SECRET")
+	pflag.String("token.secret", "", `secret which signs the restful login tokens, a random secret is used when neither it nor token.keyFile is set. Env "BLM_TOKEN_SECRET"`)
+
+	viper.SetDefault("token.keyFile", "")
+	_ = viper.BindEnv("token.keyFile", "BLM_TOKEN_KEY_FILE")
+	pflag.String("token.keyFile", "", `file of token secrets one per line, the first signs new tokens and the others are still accepted. Env "BLM_TOKEN_KEY_FILE"`)
+
+	viper.SetDefault("token.expire", 24*time.Hour)
+	_ = viper.BindEnv("token.expire", "BLM_TOKEN_EXPIRE")
+	pflag.Duration("token.expire", 24*time.Hour, `lifetime of the restful login tokens. Env "BLM_TOKEN_EXPIRE"`)
+
+	viper.SetDefault("token.revokedFile", "/var/lib/taos/blm3/revoked_tokens.json")
+	_ = viper.BindEnv("token.revokedFile", "BLM_TOKEN_REVOKED_FILE")
+	pflag.String("token.revokedFile", "/var/lib/taos/blm3/revoked_tokens.json", `file which keeps the revoked tokens across restarts, empty keeps them in memory. Env "BLM_TOKEN_REVOKED_FILE"`)
+
+	viper.SetDefault("token.legacy", false)
+	_ = viper.BindEnv("token.legacy", "BLM_TOKEN_LEGACY")
+	pflag.Bool("token.legacy", false, `issue and accept the DES tokens of earlier versions, which never expire. Env "BLM_TOKEN_LEGACY"`)
+}
+
+func (t *Token) setValue() {
+	t.Secret = viper.GetString("token.secret")
+	t.KeyFile = viper.GetString("token.keyFile")
+	t.Expire = viper.GetDuration("token.expire")
+	t.RevokedFile = viper.GetString("token.revokedFile")
+	t.Legacy = viper.GetBool("token.legacy")
+	if t.Expire <= 0 {
+		panic("token.expire must be positive")
+	}
+}
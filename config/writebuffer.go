@@ -0,0 +1,55 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+type WriteBuffer struct {
+	Enable        bool
+	Path          string
+	SegmentSize   uint
+	MaxSize       uint
+	MaxAge        time.Duration
+	RetryInterval time.Duration
+}
+
+func initWriteBuffer() {
+	viper.SetDefault("writeBuffer.enable", false)
+	_ = viper.BindEnv("writeBuffer.enable", "BLM_WRITE_BUFFER_ENABLE")
+	pflag.Bool("writeBuffer.enable", false, `enable the disk buffer of statsd, collectd, opentsdb_telnet and node_exporter writes when taosd is unavailable. Env "BLM_WRITE_BUFFER_ENABLE"`)
+
+	viper.SetDefault("writeBuffer.path", "/var/lib/taos/blm3/buffer")
+	_ = viper.BindEnv("writeBuffer.path", "BLM_WRITE_BUFFER_PATH")
+	pflag.String("writeBuffer.path", "/var/lib/taos/blm3/buffer", `write buffer path. Env "BLM_WRITE_BUFFER_PATH"`)
+
+	viper.SetDefault("writeBuffer.segmentSize", "16MB")
+	_ = viper.BindEnv("writeBuffer.segmentSize", "BLM_WRITE_BUFFER_SEGMENT_SIZE")
+	pflag.String("writeBuffer.segmentSize", "16MB", `write buffer segment file size(KB MB GB). Env "BLM_WRITE_BUFFER_SEGMENT_SIZE"`)
+
+	viper.SetDefault("writeBuffer.maxSize", "1GB")
+	_ = viper.BindEnv("writeBuffer.maxSize", "BLM_WRITE_BUFFER_MAX_SIZE")
+	pflag.String("writeBuffer.maxSize", "1GB", `write buffer maximum size of each plugin and database(KB MB GB), the oldest data is dropped. Env "BLM_WRITE_BUFFER_MAX_SIZE"`)
+
+	viper.SetDefault("writeBuffer.maxAge", time.Hour*24)
+	_ = viper.BindEnv("writeBuffer.maxAge", "BLM_WRITE_BUFFER_MAX_AGE")
+	pflag.Duration("writeBuffer.maxAge", time.Hour*24, `write buffer maximum age of data, 0 means no limit. Env "BLM_WRITE_BUFFER_MAX_AGE"`)
+
+	viper.SetDefault("writeBuffer.retryInterval", time.Second*5)
+	_ = viper.BindEnv("writeBuffer.retryInterval", "BLM_WRITE_BUFFER_RETRY_INTERVAL")
+	pflag.Duration("writeBuffer.retryInterval", time.Second*5, `write buffer replay retry interval. Env "BLM_WRITE_BUFFER_RETRY_INTERVAL"`)
+}
+
+func (w *WriteBuffer) setValue() {
+	w.Enable = viper.GetBool("writeBuffer.enable")
+	w.Path = viper.GetString("writeBuffer.path")
+	w.SegmentSize = viper.GetSizeInBytes("writeBuffer.segmentSize")
+	w.MaxSize = viper.GetSizeInBytes("writeBuffer.maxSize")
+	w.MaxAge = viper.GetDuration("writeBuffer.maxAge")
+	w.RetryInterval = viper.GetDuration("writeBuffer.retryInterval")
+	if w.Enable && (w.SegmentSize == 0 || w.MaxSize == 0 || w.RetryInterval <= 0) {
+		panic("writeBuffer.segmentSize, writeBuffer.maxSize and writeBuffer.retryInterval must be positive")
+	}
+}
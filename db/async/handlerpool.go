@@ -104,3 +104,10 @@ func (c *HandlerPool) Put(handler *Handler) {
 		return
 	}
 }
+
+// Stats returns the number of free handlers and of callers waiting for one.
+func (c *HandlerPool) Stats() (free, waiting int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.handlers), c.reqList.Len()
+}
@@ -0,0 +1,23 @@
+package async
+
+import "github.com/prometheus/client_golang/prometheus"
+
+func handlerStats() (free, waiting int) {
+	if GlobalAsync == nil {
+		return 0, 0
+	}
+	return GlobalAsync.handlerPool.Stats()
+}
+
+func init() {
+	prometheus.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{Namespace: "blm3", Subsystem: "async", Name: "handlers_free", Help: "Number of free async handlers."}, func() float64 {
+			free, _ := handlerStats()
+			return float64(free)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{Namespace: "blm3", Subsystem: "async", Name: "handlers_waiting", Help: "Number of queries waiting for an async handler."}, func() float64 {
+			_, waiting := handlerStats()
+			return float64(waiting)
+		}),
+	)
+}
@@ -1,15 +1,20 @@
 package async
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
 	"unsafe"
 
+	"github.com/taosdata/blm3/httperror"
 	"github.com/taosdata/blm3/thread"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
 	"github.com/taosdata/driver-go/v2/wrapper"
 )
 
 var FetchRowError = errors.New("fetch row error")
+var QueryTimeoutError = errors.New("query timeout")
+var MaxRowsError = errors.New("result rows exceed limit")
 var GlobalAsync *Async
 
 type Async struct {
@@ -20,21 +25,78 @@ func NewAsync(handlerPool *HandlerPool) *Async {
 	return &Async{handlerPool: handlerPool}
 }
 
+// HeaderHandler is called once with the column header before any rows are fetched.
+type HeaderHandler func(header *wrapper.RowsHeader) error
+
+// RowsHandler is called with each batch of rows returned by TaosFetchRowsA.
+type RowsHandler func(rows [][]driver.Value) error
+
 func (a *Async) TaosExec(taosConnect unsafe.Pointer, sql string, timeFormat wrapper.FormatTimeFunc) (*ExecResult, error) {
+	var data [][]driver.Value
+	execResult, err := a.TaosExecStream(context.Background(), taosConnect, sql, timeFormat, 0, nil, func(rows [][]driver.Value) error {
+		data = append(data, rows...)
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	execResult.Data = data
+	return execResult, nil
+}
+
+// TaosExecStream executes sql and hands every fetched batch to rowsHandler instead of buffering
+// the whole result set. Data of the returned ExecResult is always empty, Rows holds the total row count.
+// Execution stops with QueryTimeoutError when ctx deadline is exceeded and with MaxRowsError after
+// maxRows rows have been handed over, maxRows <= 0 means no limit.
+// done is called when the result is freed and taosConnect can run the next query, the query abandoned on ctx is
+// stopped and done is called on another goroutine after its outstanding callback arrived. done may be nil.
+func (a *Async) TaosExecStream(ctx context.Context, taosConnect unsafe.Pointer, sql string, timeFormat wrapper.FormatTimeFunc, maxRows int, headerHandler HeaderHandler, rowsHandler RowsHandler, done func()) (*ExecResult, error) {
+	// sql is not sent to taosd once ctx is done
+	if err := ctx.Err(); err != nil {
+		if done != nil {
+			done()
+		}
+		if err == context.DeadlineExceeded {
+			return nil, QueryTimeoutError
+		}
+		return nil, err
+	}
 	handler := a.handlerPool.Get()
-	defer a.handlerPool.Put(handler)
-	result, err := a.TaosQuery(taosConnect, sql, handler)
+	var res unsafe.Pointer
+	var pending chan *Result
 	defer func() {
-		if result != nil && result.res != nil {
+		if pending != nil {
+			// the callback is still on its way, the handler and the result can only be released after it arrives
+			if res != nil {
+				TaosStopQuery(res)
+			}
+			go a.release(handler, pending, res, done)
+			return
+		}
+		if res != nil {
 			thread.Lock()
-			wrapper.TaosFreeResult(result.res)
+			wrapper.TaosFreeResult(res)
 			thread.Unlock()
 		}
+		a.handlerPool.Put(handler)
+		if done != nil {
+			done()
+		}
 	}()
+	result, err := a.TaosQuery(ctx, taosConnect, sql, handler)
 	if err != nil {
+		pending = handler.Caller.QueryResult
 		return nil, err
 	}
-	res := result.res
+	res = result.res
+	code := wrapper.TaosError(res)
+	if code != httperror.SUCCESS {
+		errStr := wrapper.TaosErrorStr(res)
+		return nil, &tErrors.TaosError{
+			Code:   int32(code) & 0xffff,
+			ErrStr: errStr,
+		}
+	}
 	var fieldsCount int
 	fieldsCount = wrapper.TaosNumFields(res)
 	execResult := &ExecResult{FieldCount: fieldsCount}
@@ -50,53 +112,109 @@ func (a *Async) TaosExec(taosConnect unsafe.Pointer, sql string, timeFormat wrap
 		return nil, err
 	}
 	execResult.Header = rowsHeader
+	if headerHandler != nil {
+		err = headerHandler(rowsHeader)
+		if err != nil {
+			return nil, err
+		}
+	}
 	precision := wrapper.TaosResultPrecision(res)
 	for {
-		result, err = a.TaosFetchRowsA(res, handler)
+		result, err = a.TaosFetchRowsA(ctx, res, handler)
 		if err != nil {
+			pending = handler.Caller.FetchResult
 			return nil, err
 		}
 		if result.n == 0 {
 			return execResult, nil
-		} else {
-			res = result.res
-			for i := 0; i < result.n; i++ {
-				var row unsafe.Pointer
-				thread.Lock()
-				row = wrapper.TaosFetchRow(res)
-				thread.Unlock()
-				values := make([]driver.Value, len(rowsHeader.ColNames))
-				for j := range rowsHeader.ColTypes {
-					if row == nil {
-						return nil, FetchRowError
-					}
-					values[j] = wrapper.FetchRow(row, j, rowsHeader.ColTypes[j], precision, timeFormat)
+		}
+		if result.n < 0 {
+			errStr := wrapper.TaosErrorStr(result.res)
+			return nil, &tErrors.TaosError{
+				Code:   int32(result.n) & 0xffff,
+				ErrStr: errStr,
+			}
+		}
+		n := result.n
+		exceeded := maxRows > 0 && execResult.Rows+n > maxRows
+		if exceeded {
+			n = maxRows - execResult.Rows
+		}
+		rows := make([][]driver.Value, 0, n)
+		for i := 0; i < n; i++ {
+			var row unsafe.Pointer
+			thread.Lock()
+			row = wrapper.TaosFetchRow(res)
+			thread.Unlock()
+			values := make([]driver.Value, len(rowsHeader.ColNames))
+			for j := range rowsHeader.ColTypes {
+				if row == nil {
+					return nil, FetchRowError
 				}
-				execResult.Data = append(execResult.Data, values)
+				values[j] = wrapper.FetchRow(row, j, rowsHeader.ColTypes[j], precision, timeFormat)
 			}
+			rows = append(rows, values)
+		}
+		execResult.Rows += len(rows)
+		err = rowsHandler(rows)
+		if err != nil {
+			return nil, err
+		}
+		if exceeded {
+			return execResult, MaxRowsError
 		}
 	}
 }
 
-func (a *Async) TaosQuery(taosConnect unsafe.Pointer, sql string, handler *Handler) (*Result, error) {
+// release waits for the outstanding callback of an abandoned query, stops the query when its result only arrives with
+// the callback, frees the result, returns the handler to the pool and calls done.
+func (a *Async) release(handler *Handler, pending chan *Result, res unsafe.Pointer, done func()) {
+	r := <-pending
+	if res == nil && r.res != nil {
+		res = r.res
+		TaosStopQuery(res)
+	}
+	if res != nil {
+		thread.Lock()
+		wrapper.TaosFreeResult(res)
+		thread.Unlock()
+	}
+	a.handlerPool.Put(handler)
+	if done != nil {
+		done()
+	}
+}
+
+func (a *Async) TaosQuery(ctx context.Context, taosConnect unsafe.Pointer, sql string, handler *Handler) (*Result, error) {
 	thread.Lock()
 	wrapper.TaosQueryA(taosConnect, sql, handler.Handler)
 	thread.Unlock()
-	r := <-handler.Caller.QueryResult
-	return r, nil
+	return wait(ctx, handler.Caller.QueryResult)
 }
 
-func (a *Async) TaosFetchRowsA(res unsafe.Pointer, handler *Handler) (*Result, error) {
+func (a *Async) TaosFetchRowsA(ctx context.Context, res unsafe.Pointer, handler *Handler) (*Result, error) {
 	thread.Lock()
 	wrapper.TaosFetchRowsA(res, handler.Handler)
 	thread.Unlock()
-	r := <-handler.Caller.FetchResult
-	return r, nil
+	return wait(ctx, handler.Caller.FetchResult)
+}
+
+func wait(ctx context.Context, c chan *Result) (*Result, error) {
+	select {
+	case r := <-c:
+		return r, nil
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, QueryTimeoutError
+		}
+		return nil, ctx.Err()
+	}
 }
 
 type ExecResult struct {
 	AffectedRows int
 	FieldCount   int
+	Rows         int
 	Header       *wrapper.RowsHeader
 	Data         [][]driver.Value
 }
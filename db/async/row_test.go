@@ -3,6 +3,7 @@ package async
 import (
 	"database/sql/driver"
 	"testing"
+	"time"
 	"unsafe"
 
 	"github.com/taosdata/driver-go/v2/wrapper"
@@ -63,3 +64,27 @@ func TestAsync_TaosExec(t *testing.T) {
 		})
 	}
 }
+
+func TestAsync_release(t *testing.T) {
+	a := &Async{handlerPool: NewHandlerPool(1)}
+	handler := a.handlerPool.Get()
+	pending := make(chan *Result, 1)
+	done := make(chan struct{})
+	go a.release(handler, pending, nil, func() {
+		close(done)
+	})
+	select {
+	case <-done:
+		t.Fatal("released before the callback arrived")
+	case <-time.After(50 * time.Millisecond):
+	}
+	pending <- &Result{n: -1}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not released after the callback arrived")
+	}
+	if free, _ := a.handlerPool.Stats(); free != 1 {
+		t.Errorf("free handlers except 1 got %d", free)
+	}
+}
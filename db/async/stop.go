@@ -0,0 +1,22 @@
+package async
+
+/*
+#cgo CFLAGS: -IC:/TDengine/include -I/usr/include
+#cgo linux LDFLAGS: -L/usr/lib -ltaos
+#cgo windows LDFLAGS: -LC:/TDengine/driver -ltaos
+#cgo darwin LDFLAGS: -L/usr/local/taos/driver -ltaos
+#include <taos.h>
+*/
+import "C"
+import (
+	"unsafe"
+
+	"github.com/taosdata/blm3/thread"
+)
+
+// TaosStopQuery asks taosd to stop the query of res, the outstanding callback or the blocked fetch of res returns early.
+func TaosStopQuery(res unsafe.Pointer) {
+	thread.Lock()
+	C.taos_stop_query(res)
+	thread.Unlock()
+}
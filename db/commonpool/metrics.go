@@ -0,0 +1,33 @@
+package commonpool
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	idleDesc   = prometheus.NewDesc("blm3_pool_connections_idle", "Number of idle connections by user.", []string{"user"}, nil)
+	activeDesc = prometheus.NewDesc("blm3_pool_connections_active", "Number of connections in use by user.", []string{"user"}, nil)
+)
+
+// poolCollector reports the connection pools of connectionMap.
+type poolCollector struct{}
+
+func (poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- idleDesc
+	ch <- activeDesc
+}
+
+func (poolCollector) Collect(ch chan<- prometheus.Metric) {
+	connectionMap.Range(func(key, value interface{}) bool {
+		p := value.(*ConnectorPool)
+		ch <- prometheus.MustNewConstMetric(idleDesc, prometheus.GaugeValue, float64(p.pool.Len()), p.user)
+		ch <- prometheus.MustNewConstMetric(activeDesc, prometheus.GaugeValue, float64(atomic.LoadInt64(&p.active)), p.user)
+		return true
+	})
+}
+
+func init() {
+	prometheus.MustRegister(poolCollector{})
+}
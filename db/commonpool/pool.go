@@ -2,6 +2,7 @@ package commonpool
 
 import (
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/silenceper/pool"
@@ -14,17 +15,21 @@ type ConnectorPool struct {
 	user     string
 	password string
 	pool     pool.Pool
+	active   int64
+	lock     sync.RWMutex
+	released bool
 }
 
 func NewConnectorPool(user, password string) (*ConnectorPool, error) {
 	a := &ConnectorPool{user: user, password: password}
+	conf := config.GetPool()
 	poolConfig := &pool.Config{
 		InitialCap:  1,
-		MaxCap:      config.Conf.Pool.MaxConnect,
-		MaxIdle:     config.Conf.Pool.MaxIdle,
+		MaxCap:      conf.MaxConnect,
+		MaxIdle:     conf.MaxIdle,
 		Factory:     a.factory,
 		Close:       a.close,
-		IdleTimeout: config.Conf.Pool.IdleTimeout,
+		IdleTimeout: conf.IdleTimeout,
 	}
 	p, err := pool.NewChannelPool(poolConfig)
 	if err != nil {
@@ -54,18 +59,35 @@ func (a *ConnectorPool) Get() (unsafe.Pointer, error) {
 	if err != nil {
 		return nil, err
 	}
+	atomic.AddInt64(&a.active, 1)
 	return v.(unsafe.Pointer), nil
 }
 
 func (a *ConnectorPool) Put(c unsafe.Pointer) error {
+	atomic.AddInt64(&a.active, -1)
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.released {
+		// the released pool does not close returned connections
+		return a.close(c)
+	}
 	return a.pool.Put(c)
 }
 
 func (a *ConnectorPool) Close(c unsafe.Pointer) error {
+	atomic.AddInt64(&a.active, -1)
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.released {
+		return a.close(c)
+	}
 	return a.pool.Close(c)
 }
 
 func (a *ConnectorPool) Release() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.released = true
 	a.pool.Release()
 }
 
@@ -75,6 +97,16 @@ func (a *ConnectorPool) verifyPassword(password string) bool {
 
 var connectionMap = sync.Map{}
 
+// Reset releases the pools, the next connections are taken from new pools created with the current pool limits.
+// Connections in use are closed when they are put back.
+func Reset() {
+	connectionMap.Range(func(key, value interface{}) bool {
+		connectionMap.Delete(key)
+		value.(*ConnectorPool).Release()
+		return true
+	})
+}
+
 type Conn struct {
 	TaosConnection unsafe.Pointer
 	pool           *ConnectorPool
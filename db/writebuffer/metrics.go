@@ -0,0 +1,49 @@
+package writebuffer
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// writers holds the open writers with an enabled buffer.
+var writers = sync.Map{}
+
+var (
+	labels       = []string{"plugin", "db"}
+	pendingDesc  = prometheus.NewDesc("blm3_write_buffer_pending", "Number of batches in the buffer.", labels, nil)
+	sizeDesc     = prometheus.NewDesc("blm3_write_buffer_size_bytes", "Size of the buffer files.", labels, nil)
+	segmentsDesc = prometheus.NewDesc("blm3_write_buffer_segments", "Number of buffer segment files.", labels, nil)
+	bufferedDesc = prometheus.NewDesc("blm3_write_buffer_buffered_total", "Number of batches appended to the buffer.", labels, nil)
+	replayedDesc = prometheus.NewDesc("blm3_write_buffer_replayed_total", "Number of batches replayed from the buffer.", labels, nil)
+	droppedDesc  = prometheus.NewDesc("blm3_write_buffer_dropped_total", "Number of batches dropped by the size or age limit of the buffer.", labels, nil)
+)
+
+type writerCollector struct{}
+
+func (writerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pendingDesc
+	ch <- sizeDesc
+	ch <- segmentsDesc
+	ch <- bufferedDesc
+	ch <- replayedDesc
+	ch <- droppedDesc
+}
+
+func (writerCollector) Collect(ch chan<- prometheus.Metric) {
+	writers.Range(func(key, value interface{}) bool {
+		w := key.(*Writer)
+		s := w.Stats()
+		ch <- prometheus.MustNewConstMetric(pendingDesc, prometheus.GaugeValue, float64(s.Pending), w.plugin, w.db)
+		ch <- prometheus.MustNewConstMetric(sizeDesc, prometheus.GaugeValue, float64(s.Size), w.plugin, w.db)
+		ch <- prometheus.MustNewConstMetric(segmentsDesc, prometheus.GaugeValue, float64(s.Segments), w.plugin, w.db)
+		ch <- prometheus.MustNewConstMetric(bufferedDesc, prometheus.CounterValue, float64(s.Buffered), w.plugin, w.db)
+		ch <- prometheus.MustNewConstMetric(replayedDesc, prometheus.CounterValue, float64(s.Replayed), w.plugin, w.db)
+		ch <- prometheus.MustNewConstMetric(droppedDesc, prometheus.CounterValue, float64(s.Dropped), w.plugin, w.db)
+		return true
+	})
+}
+
+func init() {
+	prometheus.MustRegister(writerCollector{})
+}
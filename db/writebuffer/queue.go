@@ -0,0 +1,401 @@
+package writebuffer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentSuffix    = ".seg"
+	cursorFile       = "cursor"
+	recordHeaderSize = 8
+)
+
+var errCorrupt = errors.New("corrupt record")
+
+// segment is a file of records, a record is the length and the crc32 of the data followed by the data.
+type segment struct {
+	id      uint64
+	size    int64
+	records int
+	modTime time.Time
+}
+
+func (s *segment) name() string {
+	return fmt.Sprintf("%020d%s", s.id, segmentSuffix)
+}
+
+// Queue is a FIFO of byte batches persisted in segment files, the read position is saved in the cursor file so
+// batches are replayed at least once after a restart.
+type Queue struct {
+	dir         string
+	segmentSize int64
+	maxSize     int64
+	maxAge      time.Duration
+	lock        sync.Mutex
+	segments    []*segment
+	writer      *os.File
+	reader      *os.File
+	readerID    uint64
+	readOffset  int64
+	readRecords int
+	peekSize    int64
+	size        int64
+	dropped     uint64
+}
+
+type Stats struct {
+	Segments int
+	Size     int64
+	Pending  int
+	Dropped  uint64
+}
+
+// OpenQueue opens the queue in dir, the segments are truncated after their last valid record. When the total size
+// exceeds maxSize or the last write of a segment is older than maxAge the oldest segment is dropped, maxAge 0 means
+// no limit.
+func OpenQueue(dir string, segmentSize, maxSize int64, maxAge time.Duration) (*Queue, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	q := &Queue{dir: dir, segmentSize: segmentSize, maxSize: maxSize, maxAge: maxAge}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(entry.Name(), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		s := &segment{id: id, modTime: entry.ModTime()}
+		s.size, s.records, err = scan(q.path(s), entry.Size(), -1)
+		if err != nil {
+			return nil, err
+		}
+		if s.size != entry.Size() {
+			err = os.Truncate(q.path(s), s.size)
+			if err != nil {
+				return nil, err
+			}
+		}
+		q.segments = append(q.segments, s)
+		q.size += s.size
+	}
+	sort.Slice(q.segments, func(i, j int) bool {
+		return q.segments[i].id < q.segments[j].id
+	})
+	err = q.loadCursor()
+	if err != nil {
+		return nil, err
+	}
+	if len(q.segments) == 0 {
+		err = q.rotate()
+	} else {
+		q.writer, err = os.OpenFile(q.path(q.segments[len(q.segments)-1]), os.O_WRONLY|os.O_APPEND, 0644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *Queue) path(s *segment) string {
+	return filepath.Join(q.dir, s.name())
+}
+
+// scan returns the size and the number of the valid records of a segment, stopping at limit when it is not negative.
+func scan(path string, fileSize, limit int64) (int64, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	var offset int64
+	records := 0
+	header := make([]byte, recordHeaderSize)
+	for limit < 0 || offset < limit {
+		length, err := readRecord(f, offset, fileSize, header, nil)
+		if err != nil {
+			break
+		}
+		offset += recordHeaderSize + length
+		records += 1
+	}
+	return offset, records, nil
+}
+
+// readRecord reads the record at offset, data may be nil to only validate the header and the length.
+func readRecord(f *os.File, offset, fileSize int64, header []byte, data *[]byte) (int64, error) {
+	_, err := f.ReadAt(header, offset)
+	if err != nil {
+		return 0, err
+	}
+	length := int64(binary.LittleEndian.Uint32(header))
+	if offset+recordHeaderSize+length > fileSize {
+		return 0, errCorrupt
+	}
+	buf := make([]byte, length)
+	_, err = f.ReadAt(buf, offset+recordHeaderSize)
+	if err != nil {
+		return 0, err
+	}
+	if crc32.ChecksumIEEE(buf) != binary.LittleEndian.Uint32(header[4:]) {
+		return 0, errCorrupt
+	}
+	if data != nil {
+		*data = buf
+	}
+	return length, nil
+}
+
+func (q *Queue) loadCursor() error {
+	if len(q.segments) == 0 {
+		return nil
+	}
+	b, err := ioutil.ReadFile(filepath.Join(q.dir, cursorFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var id uint64
+	var offset int64
+	_, err = fmt.Sscanf(string(b), "%d %d", &id, &offset)
+	if err != nil {
+		return nil
+	}
+	// segments before the cursor have been replayed
+	for len(q.segments) > 1 && q.segments[0].id < id {
+		err = q.removeFirst()
+		if err != nil {
+			return err
+		}
+	}
+	first := q.segments[0]
+	if first.id != id {
+		return nil
+	}
+	if offset > first.size {
+		offset = first.size
+	}
+	q.readOffset, q.readRecords, err = scan(q.path(first), first.size, offset)
+	return err
+}
+
+func (q *Queue) saveCursor() error {
+	return ioutil.WriteFile(filepath.Join(q.dir, cursorFile), []byte(fmt.Sprintf("%d %d", q.segments[0].id, q.readOffset)), 0644)
+}
+
+// rotate starts a new segment for writing.
+func (q *Queue) rotate() error {
+	var id uint64 = 1
+	if len(q.segments) != 0 {
+		id = q.segments[len(q.segments)-1].id + 1
+	}
+	s := &segment{id: id, modTime: time.Now()}
+	f, err := os.OpenFile(q.path(s), os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if q.writer != nil {
+		_ = q.writer.Close()
+	}
+	q.writer = f
+	q.segments = append(q.segments, s)
+	return nil
+}
+
+// removeFirst removes the oldest segment.
+func (q *Queue) removeFirst() error {
+	first := q.segments[0]
+	if q.reader != nil && q.readerID == first.id {
+		_ = q.reader.Close()
+		q.reader = nil
+	}
+	err := os.Remove(q.path(first))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	q.size -= first.size
+	q.segments = q.segments[1:]
+	q.readOffset = 0
+	q.readRecords = 0
+	q.peekSize = 0
+	return nil
+}
+
+// dropFirst removes the oldest segment and counts its unread records as dropped.
+func (q *Queue) dropFirst() error {
+	unread := q.segments[0].records - q.readRecords
+	if len(q.segments) == 1 {
+		err := q.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	err := q.removeFirst()
+	if err != nil {
+		return err
+	}
+	q.dropped += uint64(unread)
+	return nil
+}
+
+// Append adds data to the end of the queue, the oldest segments are dropped when the queue exceeds its maximum size.
+func (q *Queue) Append(data []byte) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	last := q.segments[len(q.segments)-1]
+	if last.size >= q.segmentSize {
+		err := q.rotate()
+		if err != nil {
+			return err
+		}
+		last = q.segments[len(q.segments)-1]
+	}
+	buf := make([]byte, recordHeaderSize+len(data))
+	binary.LittleEndian.PutUint32(buf, uint32(len(data)))
+	binary.LittleEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(data))
+	copy(buf[recordHeaderSize:], data)
+	n, err := q.writer.Write(buf)
+	if err != nil {
+		// drop the partial record so the next record starts at a valid offset
+		_ = q.writer.Truncate(last.size)
+		return err
+	}
+	last.size += int64(n)
+	last.records += 1
+	last.modTime = time.Now()
+	q.size += int64(n)
+	for q.size > q.maxSize && len(q.segments) != 0 {
+		err = q.dropFirst()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Peek returns the oldest batch without removing it, nil when the queue is empty. Corrupt records skip the rest of
+// their segment.
+func (q *Queue) Peek() ([]byte, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for {
+		first := q.segments[0]
+		if q.readOffset >= first.size {
+			if len(q.segments) == 1 {
+				return nil, nil
+			}
+			err := q.removeFirst()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if q.reader == nil || q.readerID != first.id {
+			if q.reader != nil {
+				_ = q.reader.Close()
+			}
+			f, err := os.Open(q.path(first))
+			if err != nil {
+				return nil, err
+			}
+			q.reader = f
+			q.readerID = first.id
+		}
+		var data []byte
+		length, err := readRecord(q.reader, q.readOffset, first.size, make([]byte, recordHeaderSize), &data)
+		if err != nil {
+			if err != errCorrupt && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return nil, err
+			}
+			q.dropped += uint64(first.records - q.readRecords)
+			q.readRecords = first.records
+			q.readOffset = first.size
+			continue
+		}
+		q.peekSize = recordHeaderSize + length
+		return data, nil
+	}
+}
+
+// Ack removes the batch returned by the last Peek.
+func (q *Queue) Ack() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.peekSize == 0 {
+		return nil
+	}
+	q.readOffset += q.peekSize
+	q.readRecords += 1
+	q.peekSize = 0
+	return q.saveCursor()
+}
+
+// DropExpired drops the segments whose last write is older than the maximum age.
+func (q *Queue) DropExpired(now time.Time) error {
+	if q.maxAge <= 0 {
+		return nil
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for len(q.segments) != 0 {
+		first := q.segments[0]
+		if now.Sub(first.modTime) <= q.maxAge || (len(q.segments) == 1 && first.records == q.readRecords) {
+			return nil
+		}
+		err := q.dropFirst()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len returns the number of batches in the queue.
+func (q *Queue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	n := -q.readRecords
+	for _, s := range q.segments {
+		n += s.records
+	}
+	return n
+}
+
+func (q *Queue) Stats() Stats {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	n := -q.readRecords
+	for _, s := range q.segments {
+		n += s.records
+	}
+	return Stats{Segments: len(q.segments), Size: q.size, Pending: n, Dropped: q.dropped}
+}
+
+func (q *Queue) Close() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.reader != nil {
+		_ = q.reader.Close()
+	}
+	return q.writer.Close()
+}
@@ -0,0 +1,109 @@
+package writebuffer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "writebuffer")
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func TestQueue(t *testing.T) {
+	dir := tempDir(t)
+	q, err := OpenQueue(dir, 64, 1<<20, 0)
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, q.Append([]byte(fmt.Sprintf("batch %d", i))))
+	}
+	assert.Equal(t, 10, q.Len())
+	assert.True(t, q.Stats().Segments > 1)
+	for i := 0; i < 4; i++ {
+		data, err := q.Peek()
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("batch %d", i), string(data))
+		assert.NoError(t, q.Ack())
+	}
+	// not acknowledged, replayed again after reopening
+	data, err := q.Peek()
+	assert.NoError(t, err)
+	assert.Equal(t, "batch 4", string(data))
+	assert.NoError(t, q.Close())
+
+	q, err = OpenQueue(dir, 64, 1<<20, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, q.Len())
+	for i := 4; i < 10; i++ {
+		data, err := q.Peek()
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("batch %d", i), string(data))
+		assert.NoError(t, q.Ack())
+	}
+	data, err = q.Peek()
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+	assert.Equal(t, 0, q.Len())
+	assert.Equal(t, 1, q.Stats().Segments)
+	assert.NoError(t, q.Close())
+}
+
+func TestQueueTruncated(t *testing.T) {
+	dir := tempDir(t)
+	q, err := OpenQueue(dir, 1<<20, 1<<20, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Append([]byte("first")))
+	assert.NoError(t, q.Append([]byte("second")))
+	assert.NoError(t, q.Close())
+	path := filepath.Join(dir, (&segment{id: 1}).name())
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Truncate(path, info.Size()-2))
+
+	q, err = OpenQueue(dir, 1<<20, 1<<20, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, q.Len())
+	assert.NoError(t, q.Append([]byte("third")))
+	for _, want := range []string{"first", "third"} {
+		data, err := q.Peek()
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(data))
+		assert.NoError(t, q.Ack())
+	}
+	assert.NoError(t, q.Close())
+}
+
+func TestQueueLimits(t *testing.T) {
+	q, err := OpenQueue(tempDir(t), 32, 100, time.Hour)
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, q.Append([]byte(fmt.Sprintf("batch %d", i))))
+	}
+	stats := q.Stats()
+	assert.True(t, stats.Size <= 100)
+	assert.Equal(t, 10, stats.Pending+int(stats.Dropped))
+	data, err := q.Peek()
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("batch %d", stats.Dropped), string(data))
+
+	assert.NoError(t, q.DropExpired(time.Now()))
+	assert.Equal(t, stats.Pending, q.Len())
+	assert.NoError(t, q.DropExpired(time.Now().Add(2*time.Hour)))
+	assert.Equal(t, 0, q.Len())
+	assert.Equal(t, uint64(10), q.Stats().Dropped)
+	assert.NoError(t, q.Append([]byte("new")))
+	data, err = q.Peek()
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	assert.NoError(t, q.Close())
+}
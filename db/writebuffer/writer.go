@@ -0,0 +1,171 @@
+package writebuffer
+
+import (
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/blm3/log"
+	"github.com/taosdata/blm3/tools/monitor"
+	"github.com/taosdata/blm3/tools/taoserror"
+)
+
+var logger = log.GetLogger("write_buffer")
+
+// InsertFunc writes a batch of lines to taosd. Network, connection and server unavailable errors are retried later,
+// the batches failing with other errors are counted as failed points and not retried.
+type InsertFunc func(data []byte) error
+
+// Writer writes batches with an InsertFunc, when the buffer is enabled the batches which fail are appended to a disk
+// queue and replayed in order, new batches are queued behind them until the queue is empty.
+type Writer struct {
+	name          string
+	plugin        string
+	db            string
+	insert        InsertFunc
+	queue         *Queue
+	retryInterval time.Duration
+	closeChan     chan struct{}
+	wg            sync.WaitGroup
+	buffered      uint64
+	replayed      uint64
+}
+
+type WriterStats struct {
+	Stats
+	Buffered uint64
+	Replayed uint64
+}
+
+// NewWriter returns a writer of the plugin name to database db, the queue is stored in conf.Path/name/db.
+func NewWriter(conf *config.WriteBuffer, name, db string, insert InsertFunc) (*Writer, error) {
+	w := &Writer{name: name + "/" + db, plugin: name, db: db, insert: insert}
+	if !conf.Enable {
+		return w, nil
+	}
+	queue, err := OpenQueue(filepath.Join(conf.Path, name, db), int64(conf.SegmentSize), int64(conf.MaxSize), conf.MaxAge)
+	if err != nil {
+		return nil, err
+	}
+	w.queue = queue
+	w.retryInterval = conf.RetryInterval
+	w.closeChan = make(chan struct{})
+	if pending := queue.Len(); pending != 0 {
+		logger.Infof("%s has %d buffered batches", w.name, pending)
+	}
+	w.wg.Add(1)
+	go w.replayLoop()
+	writers.Store(w, struct{}{})
+	return w, nil
+}
+
+// Write inserts data, when the buffer is enabled data is buffered instead of returning insert errors.
+func (w *Writer) Write(data []byte) error {
+	if w.queue == nil || w.queue.Len() == 0 {
+		err := w.insert(data)
+		if err == nil {
+			return nil
+		}
+		if !taoserror.IsUnavailable(err) {
+			w.failed(data, err)
+			return nil
+		}
+		if w.queue == nil {
+			return err
+		}
+		logger.WithError(err).Warnf("%s insert error, buffer to disk", w.name)
+	}
+	err := w.queue.Append(data)
+	if err != nil {
+		return err
+	}
+	atomic.AddUint64(&w.buffered, 1)
+	return nil
+}
+
+func (w *Writer) replayLoop() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.retryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.closeChan:
+			return
+		case <-ticker.C:
+			w.replay()
+		}
+	}
+}
+
+// replay inserts the buffered batches in order until the queue is empty or an insert fails.
+func (w *Writer) replay() {
+	err := w.queue.DropExpired(time.Now())
+	if err != nil {
+		logger.WithError(err).Errorf("%s drop expired segments error", w.name)
+	}
+	count := 0
+	defer func() {
+		if count != 0 {
+			logger.Infof("%s replayed %d buffered batches, %d left", w.name, count, w.queue.Len())
+		}
+	}()
+	for {
+		select {
+		case <-w.closeChan:
+			return
+		default:
+		}
+		data, err := w.queue.Peek()
+		if err != nil {
+			logger.WithError(err).Errorf("%s read buffer error", w.name)
+			return
+		}
+		if data == nil {
+			return
+		}
+		err = w.insert(data)
+		if err != nil {
+			if taoserror.IsUnavailable(err) {
+				logger.WithError(err).Warnf("%s replay error, retry in %s", w.name, w.retryInterval)
+				return
+			}
+			w.failed(data, err)
+		}
+		err = w.queue.Ack()
+		if err != nil {
+			logger.WithError(err).Errorf("%s save buffer cursor error", w.name)
+		}
+		count += 1
+		atomic.AddUint64(&w.replayed, 1)
+	}
+}
+
+// failed counts the lines of a batch which taosd refused as failed points, the batch is not retried.
+func (w *Writer) failed(data []byte, err error) {
+	monitor.AddPoints(w.plugin, monitor.PointsFailed, monitor.CountLines(data))
+	logger.WithError(err).Errorf("%s insert error, batch dropped", w.name)
+}
+
+func (w *Writer) Stats() WriterStats {
+	s := WriterStats{Buffered: atomic.LoadUint64(&w.buffered), Replayed: atomic.LoadUint64(&w.replayed)}
+	if w.queue != nil {
+		s.Stats = w.queue.Stats()
+	}
+	return s
+}
+
+// Close stops replaying, the buffered batches are kept for the next start.
+func (w *Writer) Close() error {
+	if w.queue == nil {
+		return nil
+	}
+	writers.Delete(w)
+	close(w.closeChan)
+	w.wg.Wait()
+	if pending := w.queue.Len(); pending != 0 {
+		logger.Infof("%s keeps %d buffered batches for the next start", w.name, pending)
+	}
+	return w.queue.Close()
+}
@@ -0,0 +1,82 @@
+package writebuffer
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/config"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
+)
+
+type fakeTaosd struct {
+	lock     sync.Mutex
+	down     bool
+	received []string
+}
+
+func (f *fakeTaosd) insert(data []byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.down {
+		return &tErrors.TaosError{Code: tErrors.RPC_NETWORK_UNAVAIL, ErrStr: "Unable to establish connection"}
+	}
+	if string(data) == "bad" {
+		return &tErrors.TaosError{Code: tErrors.TSC_LINE_SYNTAX_ERROR, ErrStr: "syntax error"}
+	}
+	f.received = append(f.received, string(data))
+	return nil
+}
+
+func (f *fakeTaosd) setDown(down bool) {
+	f.lock.Lock()
+	f.down = down
+	f.lock.Unlock()
+}
+
+func (f *fakeTaosd) get() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string(nil), f.received...)
+}
+
+func TestWriter(t *testing.T) {
+	taosd := &fakeTaosd{}
+	conf := &config.WriteBuffer{
+		Enable:        true,
+		Path:          tempDir(t),
+		SegmentSize:   1 << 20,
+		MaxSize:       1 << 20,
+		RetryInterval: 10 * time.Millisecond,
+	}
+	w, err := NewWriter(conf, "statsd", "statsd", taosd.insert)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Write([]byte("a")))
+	// refused data is not buffered
+	assert.NoError(t, w.Write([]byte("bad")))
+	assert.Equal(t, 0, w.Stats().Pending)
+	taosd.setDown(true)
+	assert.NoError(t, w.Write([]byte("b")))
+	assert.NoError(t, w.Write([]byte("c")))
+	assert.Equal(t, []string{"a"}, taosd.get())
+	taosd.setDown(false)
+	// queued behind the buffered batches to keep the order
+	assert.NoError(t, w.Write([]byte("d")))
+	assert.Eventually(t, func() bool {
+		return len(taosd.get()) == 4 && w.Stats().Pending == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, taosd.get())
+	stats := w.Stats()
+	assert.Equal(t, stats.Buffered, stats.Replayed)
+	assert.True(t, stats.Buffered >= 2)
+	assert.NoError(t, w.Close())
+}
+
+func TestWriterDisabled(t *testing.T) {
+	taosd := &fakeTaosd{down: true}
+	w, err := NewWriter(&config.WriteBuffer{}, "statsd", "statsd", taosd.insert)
+	assert.NoError(t, err)
+	assert.Error(t, w.Write([]byte("a")))
+	assert.NoError(t, w.Close())
+}
@@ -5,15 +5,22 @@ go 1.14
 require (
 	cloud.google.com/go/kms v1.0.0 // indirect
 	cloud.google.com/go/monitoring v1.0.0 // indirect
+	github.com/apache/arrow/go/arrow v0.0.0-20200923215132-ac86123a3f01
+	github.com/fsnotify/fsnotify v1.5.1
 	github.com/gin-contrib/cors v1.3.1
 	github.com/gin-contrib/gzip v0.0.3
 	github.com/gin-contrib/pprof v1.3.0
 	github.com/gin-gonic/gin v1.7.2
+	github.com/golang/snappy v0.0.3
 	github.com/influxdata/influxdb/v2 v2.0.9
 	github.com/influxdata/telegraf v1.20.0
+	github.com/klauspost/compress v1.13.1
 	github.com/lestrrat-go/file-rotatelogs v2.4.0+incompatible
 	github.com/lestrrat-go/strftime v1.0.5 // indirect
 	github.com/patrickmn/go-cache v2.1.0+incompatible
+	github.com/prometheus/client_golang v1.11.0
+	github.com/prometheus/client_model v0.2.0
+	github.com/prometheus/prometheus v1.8.2-0.20200911110723-e83ef207b6c2
 	github.com/silenceper/pool v1.0.0
 	github.com/sirupsen/logrus v1.8.1
 	github.com/spf13/pflag v1.0.5
@@ -22,4 +29,5 @@ require (
 	github.com/taosdata/driver-go/v2 v2.0.1-0.20211029033648-a87ab9dee7ce
 	github.com/valyala/fastjson v1.6.3
 	go.uber.org/automaxprocs v1.4.0
+	golang.org/x/time v0.0.0-20210723032227-1f47c861a9ac
 )
@@ -3,6 +3,7 @@ package httperror
 const (
 	SUCCESS                      = 0x0
 	TSDB_CODE_RPC_AUTH_FAILURE   = 0x0003
+	TSDB_CODE_MND_INVALID_TABLE  = 0x0362
 	HTTP_SERVER_OFFLINE          = 0x1100
 	HTTP_UNSUPPORT_URL           = 0x1101
 	HTTP_INVALID_URL             = 0x1102
@@ -98,10 +99,19 @@ const (
 	HTTP_OP_VALUE_NULL           = 0x11A5
 	HTTP_OP_VALUE_TYPE           = 0x11A6
 	HTTP_REQUEST_JSON_ERROR      = 0x1F00
+	HTTP_QUERY_TIMEOUT           = 0x1F01
+	HTTP_QUERY_ROWS_EXCEEDED     = 0x1F02
+	HTTP_INVALID_QUERY_LIMIT     = 0x1F03
+	HTTP_INVALID_STMT_PARAM      = 0x1F04
+	HTTP_UNSUPPORTED_FORMAT      = 0x1F05
+	HTTP_INVALID_TIME_FORMAT     = 0x1F06
+	HTTP_RATE_LIMITED            = 0x1F07
+	HTTP_NOT_EXECUTED            = 0x1F08
 )
 
 var ErrorMsgMap = map[int]string{
 	TSDB_CODE_RPC_AUTH_FAILURE:   "Authentication failure",
+	TSDB_CODE_MND_INVALID_TABLE:  "Table does not exist",
 	HTTP_SERVER_OFFLINE:          "http server is not onlin",
 	HTTP_UNSUPPORT_URL:           "url is not support",
 	HTTP_INVALID_URL:             "invalid url format",
@@ -197,4 +207,12 @@ var ErrorMsgMap = map[int]string{
 	HTTP_OP_VALUE_NULL:           "value not find",
 	HTTP_OP_VALUE_TYPE:           "value type should be boolean number or string",
 	HTTP_REQUEST_JSON_ERROR:      "http request json error",
+	HTTP_QUERY_TIMEOUT:           "query timeout",
+	HTTP_QUERY_ROWS_EXCEEDED:     "query result rows exceed limit",
+	HTTP_INVALID_QUERY_LIMIT:     "invalid query limit",
+	HTTP_INVALID_STMT_PARAM:      "invalid stmt param",
+	HTTP_UNSUPPORTED_FORMAT:      "unsupported result format",
+	HTTP_INVALID_TIME_FORMAT:     "invalid timezone or time layout",
+	HTTP_RATE_LIMITED:            "rate limit exceeded",
+	HTTP_NOT_EXECUTED:            "not executed, query timeout",
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"github.com/taosdata/blm3/tools/monitor"
 )
 
 func GinLog() gin.HandlerFunc {
@@ -22,6 +23,7 @@ func GinLog() gin.HandlerFunc {
 		reqUri := c.Request.RequestURI
 		statusCode := c.Writer.Status()
 		clientIP := c.ClientIP()
+		monitor.ObserveRequest(c.FullPath(), reqMethod, statusCode, latencyTime)
 		logger.WithField("sessionID", currentID).Infof("| %3d | %13v | %15s | %s | %s \n",
 			statusCode,
 			latencyTime,
@@ -6,15 +6,19 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
 	"github.com/taosdata/blm3/config"
 	"github.com/taosdata/blm3/db"
+	"github.com/taosdata/blm3/db/commonpool"
 	"github.com/taosdata/blm3/log"
 	"github.com/taosdata/blm3/plugin"
 	_ "github.com/taosdata/blm3/plugin/collectd"
@@ -22,20 +26,35 @@ import (
 	_ "github.com/taosdata/blm3/plugin/nodeexporter"
 	_ "github.com/taosdata/blm3/plugin/opentsdb"
 	_ "github.com/taosdata/blm3/plugin/opentsdbtelnet"
+	_ "github.com/taosdata/blm3/plugin/prometheus"
 	_ "github.com/taosdata/blm3/plugin/statsd"
 	"github.com/taosdata/blm3/rest"
+	"github.com/taosdata/blm3/tools/certauth"
+	"github.com/taosdata/blm3/tools/limit"
+	"github.com/taosdata/blm3/tools/monitor"
+	"github.com/taosdata/blm3/tools/monitor/health"
+	"github.com/taosdata/blm3/tools/monitor/reporter"
+	"github.com/taosdata/blm3/tools/token"
+	"github.com/taosdata/blm3/tools/web"
 	_ "go.uber.org/automaxprocs"
 )
 
 var logger = log.GetLogger("main")
 
-func createRouter(debug bool, corsConf *config.CorsConfig, enableGzip bool) *gin.Engine {
+func createRouter(debug bool, corsHandler gin.HandlerFunc, compressConf *config.Compress) *gin.Engine {
 	if debug {
 		gin.SetMode(gin.DebugMode)
 	} else {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.New()
+	// the client ip comes from the forwarding headers only when the peer is a trusted proxy
+	router.ForwardedByClientIP = false
+	realIP, err := web.RealIP(config.Conf.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+	router.Use(realIP)
 	router.Use(log.GinLog())
 	router.Use(log.GinRecoverLog())
 	if debug {
@@ -44,30 +63,104 @@ func createRouter(debug bool, corsConf *config.CorsConfig, enableGzip bool) *gin
 	router.GET("-/ping", func(c *gin.Context) {
 		c.Status(http.StatusOK)
 	})
-	if enableGzip {
+	router.GET("metrics", monitor.Handler())
+	if compressConf.ResponseGzip {
 		router.Use(gzip.Gzip(gzip.DefaultCompression))
 	}
-	router.Use(cors.New(corsConf.GetConfig()))
+	router.Use(web.Decompress(compressConf.MaxDecompressedSize))
+	router.Use(corsHandler)
 	return router
 }
 
+var reloadLock sync.Mutex
+
+// reload applies the log level, pool limits, cors, login, rate limit, token and plugin settings of the config file,
+// other changes take effect after a restart.
+func reload(corsSwitch *web.Switch) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	logger.Info("reload config")
+	conf, err := config.Reload()
+	if err != nil {
+		logger.WithError(err).Error("reload config error, keep the running config")
+		return
+	}
+	for _, change := range config.Diff("", config.Conf, conf) {
+		logger.Infof("config changed %s", change)
+	}
+	if conf.LogLevel != config.Conf.LogLevel {
+		err = log.SetLevel(conf.LogLevel)
+		if err != nil {
+			logger.WithError(err).Error("set log level error")
+		} else {
+			config.Update(func(c *config.Config) { c.LogLevel = conf.LogLevel })
+		}
+	}
+	if len(config.Diff("pool", config.Conf.Pool, conf.Pool)) != 0 {
+		config.Update(func(c *config.Config) { c.Pool = conf.Pool })
+		commonpool.Reset()
+	}
+	if len(config.Diff("cors", config.Conf.Cors, conf.Cors)) != 0 {
+		config.Update(func(c *config.Config) { c.Cors = conf.Cors })
+		corsSwitch.Set(cors.New(conf.Cors.GetConfig()))
+	}
+	config.Update(func(c *config.Config) { c.Login = conf.Login })
+	// the limit handlers are only installed when limit.enable is set at start
+	if conf.Limit.Enable == config.Conf.Limit.Enable && len(config.Diff("limit", config.Conf.Limit, conf.Limit)) != 0 {
+		config.Update(func(c *config.Config) { c.Limit = conf.Limit })
+		limit.Reset(&conf.Limit)
+	}
+	// the key file is read again even if the config is unchanged, so keys are rotated by editing it and sending SIGHUP
+	err = token.Init(&conf.Token)
+	if err != nil {
+		logger.WithError(err).Error("reload token keys error")
+	} else {
+		config.Update(func(c *config.Config) { c.Token = conf.Token })
+	}
+	for _, change := range config.Diff("", config.Conf, conf) {
+		logger.Warnf("config %s requires restart", change)
+	}
+	plugin.Reload()
+}
+
 func main() {
 	config.Init()
 	log.ConfigLog()
 	db.PrepareConnection()
+	err := token.Init(&config.Conf.Token)
+	if err != nil {
+		panic(err)
+	}
 	logger.Info("start server:", log.ServerID)
-	router := createRouter(config.Conf.Debug, &config.Conf.Cors, false)
+	corsSwitch := web.NewSwitch(cors.New(config.Conf.Cors.GetConfig()))
+	router := createRouter(config.Conf.Debug, corsSwitch.Handle, &config.Conf.Compress)
+	health.New(&config.Conf.Monitor).Register(router)
 	r := rest.Restful{}
 	_ = r.Init(router)
 	plugin.RegisterGenerateAuth(router)
+	plugin.RegisterAdmin(router)
 	plugin.Init(router)
 	plugin.Start()
+	monitorReporter := reporter.New(&config.Conf.Monitor)
+	monitorReporter.Start()
 	server := &http.Server{
 		Addr:              ":" + strconv.Itoa(config.Conf.Port),
 		Handler:           router,
 		ReadHeaderTimeout: 20 * time.Second,
 		ReadTimeout:       200 * time.Second,
 		WriteTimeout:      90 * time.Second,
+		// the streamed restful results set their own write deadline
+		ConnContext: web.ConnContext,
+	}
+	tlsConfig, err := certauth.TLSConfig(&config.Conf.SSl)
+	if err != nil {
+		panic(err)
+	}
+	if tlsConfig != nil {
+		if !config.Conf.SSl.Enable {
+			logger.Warn("ssl.clientCAFile is ignored as ssl is disabled")
+		}
+		server.TLSConfig = tlsConfig
 	}
 	logger.Println("server on :", config.Conf.Port)
 	if config.Conf.SSl.Enable {
@@ -83,30 +176,54 @@ func main() {
 			}
 		}()
 	}
+	if config.Conf.WatchConfig {
+		viper.OnConfigChange(func(_ fsnotify.Event) {
+			reload(corsSwitch)
+		})
+		viper.WatchConfig()
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reload(corsSwitch)
+		}
+	}()
 	quit := make(chan os.Signal)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
 	<-quit
-	logger.Println("Shutdown WebServer ...")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	signal.Stop(hup)
+	shutdown(server, &r, monitorReporter)
+	logger.Println("Server exiting")
+}
+
+// shutdown stops accepting requests and data, waits for the running requests and for the plugins to write their
+// queued data until shutdown.timeout, then reports the points dropped meanwhile.
+func shutdown(server *http.Server, r *rest.Restful, monitorReporter *reporter.Reporter) {
+	logger.Infof("shutdown, timeout %s", config.Conf.Shutdown.Timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), config.Conf.Shutdown.Timeout)
 	defer cancel()
+	dropped := monitor.Points(monitor.PointsDropped)
+	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
-		if err := server.Shutdown(ctx); err != nil {
-			logger.Println("WebServer Shutdown error:", err)
+		defer wg.Done()
+		// waits for the running restful queries and http writes
+		err := server.Shutdown(ctx)
+		if err != nil {
+			logger.WithError(err).Error("abort the http requests running at the shutdown deadline")
 		}
-	}()
-	logger.Println("Stop Plugins ...")
-	ticker := time.NewTicker(time.Second * 5)
-	done := make(chan struct{})
-	go func() {
 		r.Close()
-		plugin.Stop()
-		close(done)
 	}()
-	select {
-	case <-done:
-		break
-	case <-ticker.C:
-		break
+	stopping := plugin.Shutdown(ctx)
+	wg.Wait()
+	for _, name := range stopping {
+		logger.Errorf("plugin %s has not written its queued data before the shutdown deadline", name)
 	}
-	logger.Println("Server exiting")
+	for name, n := range monitor.Points(monitor.PointsDropped) {
+		if n > dropped[name] {
+			logger.Warnf("%s dropped %.0f points during shutdown", name, n-dropped[name])
+		}
+	}
+	monitorReporter.Stop()
 }
@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/blm3/tools/login"
+)
+
+type adminMessage struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// RegisterAdmin registers the api to list, start, stop and restart plugins, only plugin.adminUser is allowed.
+func RegisterAdmin(r gin.IRouter) {
+	g := r.Group("-/plugins", Auth(adminError), adminAuth)
+	g.GET("", func(c *gin.Context) {
+		c.JSON(http.StatusOK, Statuses())
+	})
+	g.POST(":name/:version/:action", adminAction)
+}
+
+func adminError(c *gin.Context, code int, err error) {
+	c.AbortWithStatusJSON(code, &adminMessage{Code: code, Message: err.Error()})
+}
+
+func adminAuth(c *gin.Context) {
+	user, password, err := GetAuth(c)
+	if err != nil {
+		adminError(c, http.StatusUnauthorized, errors.New("auth needed"))
+		return
+	}
+	if user != config.Conf.Plugin.AdminUser {
+		adminError(c, http.StatusForbidden, errors.New("permission denied"))
+		return
+	}
+	// the admin api checks the password of every request with taosd
+	err = login.Authenticate(c, "admin", user, password)
+	if err != nil {
+		adminError(c, login.StatusCode(err), err)
+		return
+	}
+}
+
+func adminAction(c *gin.Context) {
+	name := c.Param("name") + "/" + c.Param("version")
+	var err error
+	switch c.Param("action") {
+	case "start":
+		err = StartPlugin(name)
+	case "stop":
+		err = StopPlugin(name)
+	case "restart":
+		err = RestartPlugin(name)
+	default:
+		adminError(c, http.StatusNotFound, errors.New("unknown action"))
+		return
+	}
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, Statuses()[name])
+	case ErrNotFound:
+		adminError(c, http.StatusNotFound, err)
+	case ErrDisabled, ErrInitFailed:
+		adminError(c, http.StatusConflict, err)
+	default:
+		adminError(c, http.StatusInternalServerError, err)
+	}
+}
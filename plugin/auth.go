@@ -9,7 +9,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/taosdata/blm3/tools"
+	"github.com/taosdata/blm3/tools/certauth"
+	"github.com/taosdata/blm3/tools/login"
 	"github.com/taosdata/blm3/tools/pool"
+	"github.com/taosdata/blm3/tools/token"
 )
 
 const (
@@ -21,6 +24,11 @@ func Auth(errHandler func(c *gin.Context, code int, err error)) func(c *gin.Cont
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
 		if len(auth) == 0 {
+			if user, password, ok := certauth.User(c.Request); ok {
+				c.Set(UserKey, user)
+				c.Set(PasswordKey, password)
+				return
+			}
 			errHandler(c, http.StatusUnauthorized, errors.New("auth needed"))
 			c.Abort()
 			return
@@ -82,12 +90,40 @@ func Auth(errHandler func(c *gin.Context, code int, err error)) func(c *gin.Cont
 				c.Abort()
 				return
 			}
+			if !check(c, errHandler, "basic auth", user, password) {
+				return
+			}
+			c.Set(UserKey, user)
+			c.Set(PasswordKey, password)
+		} else if strings.HasPrefix(auth, "Taosd") {
+			user, password, claims, err := token.Decode(strings.TrimSpace(auth[5:]), token.ScopePlugin)
+			if err != nil {
+				errHandler(c, http.StatusUnauthorized, err)
+				c.Abort()
+				return
+			}
+			// the password of a legacy token has not been checked
+			if claims == nil && !check(c, errHandler, "taosd auth", user, password) {
+				return
+			}
 			c.Set(UserKey, user)
 			c.Set(PasswordKey, password)
 		}
 	}
 }
 
+// check checks the credentials of a request with login.Check and answers the request with errHandler when they are
+// refused.
+func check(c *gin.Context, errHandler func(c *gin.Context, code int, err error), action, user, password string) bool {
+	err := login.Check(c, action, user, password)
+	if err != nil {
+		errHandler(c, login.StatusCode(err), err)
+		c.Abort()
+		return false
+	}
+	return true
+}
+
 func RegisterGenerateAuth(r gin.IRouter) {
 	r.GET("genauth/:user/:password/:key", func(c *gin.Context) {
 		user := c.Param("user")
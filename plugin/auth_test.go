@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/blm3/tools/login"
+	"github.com/taosdata/blm3/tools/token"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
+)
+
+func TestAuth(t *testing.T) {
+	defer func(conf *config.Config, verifyUser func(user, password string) error) {
+		config.Conf = conf
+		login.VerifyUser = verifyUser
+	}(config.Conf, login.VerifyUser)
+	config.Conf = &config.Config{}
+	login.VerifyUser = func(user, password string) error {
+		if password != "taosdata" {
+			return &tErrors.TaosError{Code: tErrors.RPC_AUTH_FAILURE, ErrStr: "Authentication failure"}
+		}
+		return nil
+	}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.GET("/", Auth(func(c *gin.Context, code int, err error) {
+		c.AbortWithStatus(code)
+	}), func(c *gin.Context) {
+		user, password, err := GetAuth(c)
+		assert.NoError(t, err)
+		c.String(http.StatusOK, user+":"+password)
+	})
+	auth := func(header string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", header)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	legacy, err := token.EncodeDes("root", "taosdata")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, auth("Taosd "+legacy).Code)
+	config.Conf.Token.Legacy = true
+	w := auth("Taosd " + legacy)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "root:taosdata", w.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, auth("Taosd abc").Code)
+	wrong, err := token.EncodeDes("root", "wrong")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, auth("Taosd "+wrong).Code)
+
+	// basic auth is checked with taosd
+	w = auth("Basic " + base64.StdEncoding.EncodeToString([]byte("root:taosdata")))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "root:taosdata", w.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, auth("Basic "+base64.StdEncoding.EncodeToString([]byte("root:wrong"))).Code)
+}
@@ -1,6 +1,8 @@
 package collectd
 
 import (
+	"fmt"
+
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/taosdata/driver-go/v2/common"
@@ -13,6 +15,9 @@ type Config struct {
 	User     string
 	Password string
 	Worker   int
+	// SecurityLevel is the minimum collectd network security level accepted, none sign or encrypt
+	SecurityLevel string
+	AuthFile      string
 }
 
 func (c *Config) setValue() {
@@ -22,6 +27,13 @@ func (c *Config) setValue() {
 	c.User = viper.GetString("collectd.user")
 	c.Password = viper.GetString("collectd.password")
 	c.Worker = viper.GetInt("collectd.worker")
+	c.SecurityLevel = viper.GetString("collectd.securityLevel")
+	c.AuthFile = viper.GetString("collectd.authFile")
+	switch c.SecurityLevel {
+	case "none", "sign", "encrypt":
+	default:
+		panic(fmt.Errorf("invalid collectd.securityLevel %s, expected none sign or encrypt", c.SecurityLevel))
+	}
 }
 
 func init() {
@@ -48,4 +60,12 @@ func init() {
 	_ = viper.BindEnv("collectd.worker", "BLM_COLLECTD_WORKER")
 	pflag.Int("collectd.worker", 10, `collectd write worker. Env "BLM_COLLECTD_WORKER"`)
 	viper.SetDefault("collectd.worker", 10)
+
+	_ = viper.BindEnv("collectd.securityLevel", "BLM_COLLECTD_SECURITY_LEVEL")
+	pflag.String("collectd.securityLevel", "none", `collectd network security level, sign or encrypt drop the packets not signed or encrypted with a user of collectd.authFile. Env "BLM_COLLECTD_SECURITY_LEVEL"`)
+	viper.SetDefault("collectd.securityLevel", "none")
+
+	_ = viper.BindEnv("collectd.authFile", "BLM_COLLECTD_AUTH_FILE")
+	pflag.String("collectd.authFile", "/etc/collectd/auth_file", `collectd auth file of user: password lines. Env "BLM_COLLECTD_AUTH_FILE"`)
+	viper.SetDefault("collectd.authFile", "/etc/collectd/auth_file")
 }
@@ -3,7 +3,9 @@ package collectd
 import (
 	"fmt"
 	"net"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -11,25 +13,32 @@ import (
 	"github.com/influxdata/telegraf/plugins/parsers/collectd"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/spf13/viper"
+	"github.com/taosdata/blm3/config"
 	"github.com/taosdata/blm3/db/commonpool"
+	"github.com/taosdata/blm3/db/writebuffer"
 	"github.com/taosdata/blm3/log"
 	"github.com/taosdata/blm3/plugin"
 	"github.com/taosdata/blm3/schemaless/capi"
+	"github.com/taosdata/blm3/tools/monitor"
 )
 
 var logger = log.GetLogger("collectd")
 
 type Plugin struct {
+	plugin.State
 	conf       Config
 	conn       net.PacketConn
 	serializer *influx.Serializer
 	parser     *collectd.CollectdParser
 	metricChan chan []telegraf.Metric
-	closeChan  chan struct{}
+	listenDone chan struct{}
+	wg         sync.WaitGroup
+	writer     *writebuffer.Writer
 }
 
 func (p *Plugin) Init(_ gin.IRouter) error {
 	p.conf.setValue()
+	p.SetEnabled(p.conf.Enable)
 	if !p.conf.Enable {
 		logger.Info("collectd disabled")
 		return nil
@@ -38,52 +47,90 @@ func (p *Plugin) Init(_ gin.IRouter) error {
 	p.conf.DB = viper.GetString("collectd.db")
 	p.conf.User = viper.GetString("collectd.user")
 	p.conf.Password = viper.GetString("collectd.password")
-	p.parser = &collectd.CollectdParser{
-		ParseMultiValue: "split",
-	}
 	return nil
 }
 
+// Reload reads the configuration, the udp listener and the parser use the new port and security level after the
+// plugin is started again.
+func (p *Plugin) Reload() ([]string, func() error) {
+	var conf Config
+	conf.setValue()
+	return config.Diff("collectd", p.conf, conf), func() error {
+		p.conf = conf
+		p.SetEnabled(conf.Enable)
+		return nil
+	}
+}
+
 func (p *Plugin) Start() error {
-	if p.conn != nil {
-		err := p.conn.Close()
-		if err != nil {
+	if !p.conf.Enable {
+		return nil
+	}
+	if p.conf.SecurityLevel != "none" {
+		// the auth file is read on the first signed packet, a missing file would drop every packet
+		if _, err := os.Stat(p.conf.AuthFile); err != nil {
 			return err
 		}
 	}
+	// packets below the security level are dropped by the parser
+	parser, err := collectd.NewCollectdParser(p.conf.AuthFile, p.conf.SecurityLevel, nil, "split")
+	if err != nil {
+		return err
+	}
+	p.parser = parser
+	p.writer, err = writebuffer.NewWriter(&config.Conf.WriteBuffer, "collectd", p.conf.DB, p.insert)
+	if err != nil {
+		return err
+	}
 	conn, err := udpListen("udp", fmt.Sprintf(":%d", p.conf.Port))
 	if err != nil {
 		return err
 	}
-	p.closeChan = make(chan struct{})
 	p.metricChan = make(chan []telegraf.Metric, 2*p.conf.Worker)
 	for i := 0; i < p.conf.Worker; i++ {
-		go func() {
+		p.wg.Add(1)
+		go func(metricChan chan []telegraf.Metric) {
+			defer p.wg.Done()
 			serializer := influx.NewSerializer()
-			for {
-				select {
-				case metric := <-p.metricChan:
-					p.HandleMetrics(serializer, metric)
-				case <-p.closeChan:
-					return
-				}
+			for metric := range metricChan {
+				p.HandleMetrics(serializer, metric)
 			}
-		}()
+		}(p.metricChan)
 	}
 	p.conn = conn
-	go p.listen()
+	p.SetListening(true)
+	p.listenDone = make(chan struct{})
+	go p.listen(conn, p.metricChan, p.listenDone)
 	return nil
 }
 
+// Stop closes the listener and writes the queued metrics before it returns, it is also called after a failed Start.
 func (p *Plugin) Stop() error {
 	if !p.conf.Enable {
 		return nil
 	}
+	var err error
 	if p.conn != nil {
-		return p.conn.Close()
+		err = p.conn.Close()
+		p.conn = nil
 	}
-	close(p.closeChan)
-	return nil
+	if p.listenDone != nil {
+		<-p.listenDone
+		p.listenDone = nil
+	}
+	if p.metricChan != nil {
+		close(p.metricChan)
+		p.metricChan = nil
+	}
+	p.wg.Wait()
+	if p.writer != nil {
+		closeErr := p.writer.Close()
+		if closeErr != nil {
+			logger.WithError(closeErr).Error("close write buffer error")
+		}
+		p.writer = nil
+	}
+	return err
 }
 
 func (p *Plugin) String() string {
@@ -103,10 +150,20 @@ func (p *Plugin) HandleMetrics(serializer *influx.Serializer, metrics []telegraf
 		logger.WithError(err).Error("serialize collectd error")
 		return
 	}
+	monitor.AddPoints(p.String(), monitor.PointsReceived, len(metrics))
+	err = p.writer.Write(data)
+	if err != nil {
+		monitor.AddPoints(p.String(), monitor.PointsDropped, len(metrics))
+		p.WriteFailed(err)
+		logger.WithError(err).Errorln("insert lines error", string(data))
+	}
+}
+
+func (p *Plugin) insert(data []byte) error {
 	taosConn, err := commonpool.GetConnection(p.conf.User, p.conf.Password)
 	if err != nil {
-		logger.WithError(err).Errorln("connect taosd error")
-		return
+		p.WriteFailed(err)
+		return err
 	}
 	defer func() {
 		putErr := taosConn.Put()
@@ -118,16 +175,28 @@ func (p *Plugin) HandleMetrics(serializer *influx.Serializer, metrics []telegraf
 	logger.Debugln(start, "insert lines", string(data))
 	result, err := capi.InsertInfluxdb(taosConn.TaosConnection, data, p.conf.DB, "ns")
 	logger.Debugln("insert lines finish cost:", time.Now().Sub(start), string(data))
-	if err != nil || result.FailCount != 0 {
-		logger.WithError(err).WithField("result", result).Errorln("insert lines error", string(data))
-		return
+	if err != nil {
+		p.WriteFailed(err)
+		return err
 	}
+	monitor.AddPoints(p.String(), monitor.PointsInserted, result.SuccessCount)
+	monitor.AddPoints(p.String(), monitor.PointsFailed, result.FailCount)
+	if result.SuccessCount != 0 {
+		p.WriteSucceeded()
+	}
+	if result.FailCount != 0 {
+		p.WriteFailed(result.Errors[0])
+		logger.WithField("result", result).Errorln("insert lines error", string(data))
+	}
+	return nil
 }
 
-func (p *Plugin) listen() {
+func (p *Plugin) listen(conn net.PacketConn, metricChan chan []telegraf.Metric, done chan struct{}) {
+	defer close(done)
+	defer p.SetListening(false)
 	buf := make([]byte, 64*1024) // 64kb - maximum size of IP packet
 	for {
-		n, _, err := p.conn.ReadFrom(buf)
+		n, _, err := conn.ReadFrom(buf)
 		if err != nil {
 			if !strings.HasSuffix(err.Error(), ": use of closed network connection") {
 				logger.Error(err.Error())
@@ -140,8 +209,7 @@ func (p *Plugin) listen() {
 			logger.Errorf("Unable to parse incoming packet: %s", err.Error())
 			continue
 		}
-		p.metricChan <- metrics
-
+		metricChan <- metrics
 	}
 }
 
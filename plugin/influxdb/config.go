@@ -1,20 +1,56 @@
 package influxdb
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
 	Enable bool
+	// Buckets maps influxdb v2 buckets to TDengine databases, unmapped buckets are used as database names
+	Buckets map[string]string
+	// Tokens maps influxdb v2 tokens to TDengine users
+	Tokens map[string]*TokenUser
+}
+
+type TokenUser struct {
+	User     string
+	Password string
 }
 
 func (c *Config) setValue() {
 	c.Enable = viper.GetBool("influxdb.enable")
+	c.Buckets = map[string]string{}
+	for _, item := range viper.GetStringSlice("influxdb.buckets") {
+		sl := strings.SplitN(item, ":", 2)
+		if len(sl) != 2 || len(sl[0]) == 0 || len(sl[1]) == 0 {
+			panic(fmt.Errorf("invalid influxdb.buckets item %s, expected bucket:database", item))
+		}
+		c.Buckets[sl[0]] = sl[1]
+	}
+	c.Tokens = map[string]*TokenUser{}
+	for _, item := range viper.GetStringSlice("influxdb.tokens") {
+		sl := strings.SplitN(item, ":", 3)
+		if len(sl) != 3 || len(sl[0]) == 0 || len(sl[1]) == 0 {
+			panic(fmt.Errorf("invalid influxdb.tokens item, expected token:user:password"))
+		}
+		c.Tokens[sl[0]] = &TokenUser{User: sl[1], Password: sl[2]}
+	}
 }
 
 func init() {
 	_ = viper.BindEnv("influxdb.enable", "BLM_INFLUXDB_ENABLE")
 	pflag.Bool("influxdb.enable", true, `enable influxdb. Env "BLM_INFLUXDB_ENABLE"`)
 	viper.SetDefault("influxdb.enable", true)
+
+	_ = viper.BindEnv("influxdb.buckets", "BLM_INFLUXDB_BUCKETS")
+	pflag.StringSlice("influxdb.buckets", nil, `influxdb v2 bucket to database mapping, bucket:database. Env "BLM_INFLUXDB_BUCKETS"`)
+	viper.SetDefault("influxdb.buckets", []string{})
+
+	_ = viper.BindEnv("influxdb.tokens", "BLM_INFLUXDB_TOKENS")
+	pflag.StringSlice("influxdb.tokens", nil, `influxdb v2 token to user mapping, token:user:password. Env "BLM_INFLUXDB_TOKENS"`)
+	viper.SetDefault("influxdb.tokens", []string{})
 }
@@ -0,0 +1,159 @@
+package influxql
+
+import "time"
+
+// Statement is one of the supported InfluxQL statements.
+type Statement interface {
+	statement()
+}
+
+type ShowDatabasesStatement struct{}
+
+type ShowMeasurementsStatement struct {
+	Database string
+}
+
+type ShowTagKeysStatement struct {
+	Database    string
+	Measurement string
+}
+
+type ShowTagValuesStatement struct {
+	Database    string
+	Measurement string
+	Key         string
+}
+
+type ShowFieldKeysStatement struct {
+	Database    string
+	Measurement string
+}
+
+type SelectStatement struct {
+	Fields      []*Field
+	Database    string
+	Measurement string
+	Condition   Expr
+	// Interval is the duration of GROUP BY time(), 0 means no time grouping
+	Interval   time.Duration
+	Offset     time.Duration
+	GroupByTag []string
+	Fill       FillOption
+	FillValue  float64
+	Descending bool
+	Limit      int
+	OffsetRows int
+	SLimit     int
+	SOffset    int
+}
+
+func (*ShowDatabasesStatement) statement()    {}
+func (*ShowMeasurementsStatement) statement() {}
+func (*ShowTagKeysStatement) statement()      {}
+func (*ShowTagValuesStatement) statement()    {}
+func (*ShowFieldKeysStatement) statement()    {}
+func (*SelectStatement) statement()           {}
+
+type FillOption int
+
+const (
+	FillDefault FillOption = iota
+	FillNone
+	FillNull
+	FillPrevious
+	FillLinear
+	FillValue
+)
+
+type Field struct {
+	Expr  Expr
+	Alias string
+}
+
+// Name returns the column name of the field in the result like influxdb does, the alias, the function name
+// or the names of the variables joined by underscores.
+func (f *Field) Name() string {
+	if len(f.Alias) != 0 {
+		return f.Alias
+	}
+	switch e := f.Expr.(type) {
+	case *Call:
+		return e.Name
+	case *VarRef:
+		return e.Name
+	case *ParenExpr:
+		return (&Field{Expr: e.Expr}).Name()
+	case *BinaryExpr:
+		l := (&Field{Expr: e.LHS}).Name()
+		r := (&Field{Expr: e.RHS}).Name()
+		if len(l) == 0 {
+			return r
+		}
+		if len(r) == 0 {
+			return l
+		}
+		return l + "_" + r
+	}
+	return ""
+}
+
+type Expr interface {
+	expr()
+}
+
+type VarRef struct {
+	Name string
+}
+
+type Call struct {
+	Name string
+	Args []Expr
+}
+
+type BinaryExpr struct {
+	Op  Token
+	LHS Expr
+	RHS Expr
+}
+
+type ParenExpr struct {
+	Expr Expr
+}
+
+type StringLiteral struct {
+	Val string
+}
+
+type NumberLiteral struct {
+	Val float64
+}
+
+type IntegerLiteral struct {
+	Val int64
+}
+
+type DurationLiteral struct {
+	Val time.Duration
+}
+
+type BooleanLiteral struct {
+	Val bool
+}
+
+type RegexLiteral struct {
+	Val string
+}
+
+type Wildcard struct{}
+
+func (*VarRef) expr()          {}
+func (*Call) expr()            {}
+func (*BinaryExpr) expr()      {}
+func (*ParenExpr) expr()       {}
+func (*StringLiteral) expr()   {}
+func (*NumberLiteral) expr()   {}
+func (*IntegerLiteral) expr()  {}
+func (*DurationLiteral) expr() {}
+func (*BooleanLiteral) expr()  {}
+func (*RegexLiteral) expr()    {}
+func (*Wildcard) expr()        {}
@@ -0,0 +1,587 @@
+package influxql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"u":  time.Microsecond,
+	"µ":  time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+}
+
+// ParseDuration parses an influxql duration literal such as 10m.
+func ParseDuration(lit string) (time.Duration, error) {
+	i := 0
+	for i < len(lit) && isDigit(rune(lit[i])) {
+		i++
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("invalid duration %s", lit)
+	}
+	unit, ok := durationUnits[lit[i:]]
+	if !ok {
+		return 0, fmt.Errorf("invalid duration %s", lit)
+	}
+	n, err := strconv.ParseInt(lit[:i], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %s", lit)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+type parser struct {
+	s   *scanner
+	buf []item
+}
+
+// ParseQuery parses a query of one or more statements separated by semicolons.
+func ParseQuery(q string) ([]Statement, error) {
+	p := &parser{s: &scanner{s: q}}
+	var statements []Statement
+	for {
+		it, err := p.scan()
+		if err != nil {
+			return nil, err
+		}
+		switch it.tok {
+		case EOF:
+			if len(statements) == 0 {
+				return nil, fmt.Errorf("empty query")
+			}
+			return statements, nil
+		case SEMICOLON:
+			continue
+		}
+		p.unscan(it)
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, stmt)
+		it, err = p.scan()
+		if err != nil {
+			return nil, err
+		}
+		if it.tok != SEMICOLON && it.tok != EOF {
+			return nil, fmt.Errorf("found %s, expected ; at %d", it, it.pos)
+		}
+		p.unscan(it)
+	}
+}
+
+func (p *parser) scan() (item, error) {
+	if n := len(p.buf); n != 0 {
+		it := p.buf[n-1]
+		p.buf = p.buf[:n-1]
+		return it, nil
+	}
+	return p.s.scan()
+}
+
+func (p *parser) unscan(it item) {
+	p.buf = append(p.buf, it)
+}
+
+func (p *parser) peek() (item, error) {
+	it, err := p.scan()
+	if err != nil {
+		return it, err
+	}
+	p.unscan(it)
+	return it, nil
+}
+
+func (p *parser) expectKeyword(keywords ...string) error {
+	for _, kw := range keywords {
+		it, err := p.scan()
+		if err != nil {
+			return err
+		}
+		if !it.isKeyword(kw) {
+			return fmt.Errorf("found %s, expected %s at %d", it, strings.ToUpper(kw), it.pos)
+		}
+	}
+	return nil
+}
+
+func (p *parser) expect(tok Token) (item, error) {
+	it, err := p.scan()
+	if err != nil {
+		return it, err
+	}
+	if it.tok != tok {
+		return it, fmt.Errorf("found %s, expected %s at %d", it, tok, it.pos)
+	}
+	return it, nil
+}
+
+// acceptKeyword consumes the next item when it is the keyword kw.
+func (p *parser) acceptKeyword(kw string) (bool, error) {
+	it, err := p.scan()
+	if err != nil {
+		return false, err
+	}
+	if it.isKeyword(kw) {
+		return true, nil
+	}
+	p.unscan(it)
+	return false, nil
+}
+
+func (p *parser) parseStatement() (Statement, error) {
+	it, err := p.scan()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case it.isKeyword("select"):
+		return p.parseSelect()
+	case it.isKeyword("show"):
+		return p.parseShow()
+	}
+	return nil, fmt.Errorf("found %s, expected SELECT, SHOW at %d", it, it.pos)
+}
+
+func (p *parser) parseShow() (Statement, error) {
+	it, err := p.scan()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case it.isKeyword("databases"):
+		return &ShowDatabasesStatement{}, nil
+	case it.isKeyword("measurements"):
+		stmt := &ShowMeasurementsStatement{}
+		stmt.Database, err = p.parseOn()
+		return stmt, err
+	case it.isKeyword("tag"):
+		next, err := p.scan()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case next.isKeyword("keys"):
+			stmt := &ShowTagKeysStatement{}
+			stmt.Database, stmt.Measurement, err = p.parseOnFrom()
+			return stmt, err
+		case next.isKeyword("values"):
+			stmt := &ShowTagValuesStatement{}
+			stmt.Database, stmt.Measurement, err = p.parseOnFrom()
+			if err != nil {
+				return nil, err
+			}
+			err = p.expectKeyword("with", "key")
+			if err != nil {
+				return nil, err
+			}
+			_, err = p.expect(EQ)
+			if err != nil {
+				return nil, err
+			}
+			key, err := p.expect(IDENT)
+			if err != nil {
+				return nil, err
+			}
+			stmt.Key = key.lit
+			return stmt, nil
+		}
+		return nil, fmt.Errorf("found %s, expected KEYS, VALUES at %d", next, next.pos)
+	case it.isKeyword("field"):
+		err = p.expectKeyword("keys")
+		if err != nil {
+			return nil, err
+		}
+		stmt := &ShowFieldKeysStatement{}
+		stmt.Database, stmt.Measurement, err = p.parseOnFrom()
+		return stmt, err
+	}
+	return nil, fmt.Errorf("found %s, expected DATABASES, MEASUREMENTS, TAG, FIELD at %d", it, it.pos)
+}
+
+// parseOn parses an optional ON clause.
+func (p *parser) parseOn() (string, error) {
+	ok, err := p.acceptKeyword("on")
+	if err != nil || !ok {
+		return "", err
+	}
+	it, err := p.expect(IDENT)
+	return it.lit, err
+}
+
+// parseOnFrom parses optional ON and FROM clauses.
+func (p *parser) parseOnFrom() (db, measurement string, err error) {
+	db, err = p.parseOn()
+	if err != nil {
+		return "", "", err
+	}
+	ok, err := p.acceptKeyword("from")
+	if err != nil || !ok {
+		return db, "", err
+	}
+	fromDB, measurement, err := p.parseSource()
+	if err != nil {
+		return "", "", err
+	}
+	if len(fromDB) != 0 {
+		db = fromDB
+	}
+	return db, measurement, nil
+}
+
+// parseSource parses a measurement optionally qualified as db.rp.measurement or db..measurement,
+// the retention policy is ignored.
+func (p *parser) parseSource() (db, measurement string, err error) {
+	var parts []string
+	expectIdent := true
+	for {
+		it, err := p.scan()
+		if err != nil {
+			return "", "", err
+		}
+		if it.tok == IDENT && expectIdent {
+			parts = append(parts, it.lit)
+			expectIdent = false
+			continue
+		}
+		if it.tok == DOT && len(parts) != 0 {
+			if expectIdent {
+				parts = append(parts, "")
+			}
+			expectIdent = true
+			continue
+		}
+		if it.tok == DIV || it.tok == REGEX {
+			return "", "", fmt.Errorf("regex source is not supported at %d", it.pos)
+		}
+		if expectIdent {
+			return "", "", fmt.Errorf("found %s, expected identifier at %d", it, it.pos)
+		}
+		p.unscan(it)
+		break
+	}
+	switch len(parts) {
+	case 1:
+		return "", parts[0], nil
+	case 2:
+		return parts[0], parts[1], nil
+	case 3:
+		return parts[0], parts[2], nil
+	}
+	return "", "", fmt.Errorf("invalid source %s", strings.Join(parts, "."))
+}
+
+func (p *parser) parseSelect() (*SelectStatement, error) {
+	stmt := &SelectStatement{}
+	for {
+		expr, err := p.parseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		field := &Field{Expr: expr}
+		ok, err := p.acceptKeyword("as")
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			alias, err := p.expect(IDENT)
+			if err != nil {
+				return nil, err
+			}
+			field.Alias = alias.lit
+		}
+		stmt.Fields = append(stmt.Fields, field)
+		it, err := p.scan()
+		if err != nil {
+			return nil, err
+		}
+		if it.tok != COMMA {
+			p.unscan(it)
+			break
+		}
+	}
+	err := p.expectKeyword("from")
+	if err != nil {
+		return nil, err
+	}
+	stmt.Database, stmt.Measurement, err = p.parseSource()
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := p.acceptKeyword("where"); err != nil {
+		return nil, err
+	} else if ok {
+		stmt.Condition, err = p.parseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if ok, err := p.acceptKeyword("group"); err != nil {
+		return nil, err
+	} else if ok {
+		err = p.expectKeyword("by")
+		if err != nil {
+			return nil, err
+		}
+		err = p.parseGroupBy(stmt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if ok, err := p.acceptKeyword("fill"); err != nil {
+		return nil, err
+	} else if ok {
+		err = p.parseFill(stmt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if ok, err := p.acceptKeyword("order"); err != nil {
+		return nil, err
+	} else if ok {
+		err = p.expectKeyword("by", "time")
+		if err != nil {
+			return nil, err
+		}
+		if ok, err := p.acceptKeyword("desc"); err != nil {
+			return nil, err
+		} else if ok {
+			stmt.Descending = true
+		} else if _, err := p.acceptKeyword("asc"); err != nil {
+			return nil, err
+		}
+	}
+	for _, clause := range []struct {
+		keyword string
+		value   *int
+	}{
+		{"limit", &stmt.Limit},
+		{"offset", &stmt.OffsetRows},
+		{"slimit", &stmt.SLimit},
+		{"soffset", &stmt.SOffset},
+	} {
+		ok, err := p.acceptKeyword(clause.keyword)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		it, err := p.expect(INTEGER)
+		if err != nil {
+			return nil, err
+		}
+		*clause.value, err = strconv.Atoi(it.lit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %s", clause.keyword, it.lit)
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) parseGroupBy(stmt *SelectStatement) error {
+	for {
+		expr, err := p.parseExpr(0)
+		if err != nil {
+			return err
+		}
+		switch e := expr.(type) {
+		case *VarRef:
+			stmt.GroupByTag = append(stmt.GroupByTag, e.Name)
+		case *Call:
+			if !strings.EqualFold(e.Name, "time") || len(e.Args) == 0 || len(e.Args) > 2 {
+				return fmt.Errorf("invalid group by %s()", e.Name)
+			}
+			for i, arg := range e.Args {
+				d, ok := arg.(*DurationLiteral)
+				if !ok {
+					return fmt.Errorf("time() arguments must be durations")
+				}
+				if i == 0 {
+					stmt.Interval = d.Val
+				} else {
+					stmt.Offset = d.Val
+				}
+			}
+		case *Wildcard:
+			return fmt.Errorf("group by * is not supported")
+		default:
+			return fmt.Errorf("invalid group by expression")
+		}
+		it, err := p.scan()
+		if err != nil {
+			return err
+		}
+		if it.tok != COMMA {
+			p.unscan(it)
+			return nil
+		}
+	}
+}
+
+func (p *parser) parseFill(stmt *SelectStatement) error {
+	_, err := p.expect(LPAREN)
+	if err != nil {
+		return err
+	}
+	it, err := p.scan()
+	if err != nil {
+		return err
+	}
+	switch {
+	case it.isKeyword("null"):
+		stmt.Fill = FillNull
+	case it.isKeyword("none"):
+		stmt.Fill = FillNone
+	case it.isKeyword("previous"):
+		stmt.Fill = FillPrevious
+	case it.isKeyword("linear"):
+		stmt.Fill = FillLinear
+	case it.tok == INTEGER, it.tok == NUMBER:
+		stmt.Fill = FillValue
+		stmt.FillValue, _ = strconv.ParseFloat(it.lit, 64)
+	case it.tok == SUB:
+		n, err := p.scan()
+		if err != nil {
+			return err
+		}
+		if n.tok != INTEGER && n.tok != NUMBER {
+			return fmt.Errorf("found %s, expected number at %d", n, n.pos)
+		}
+		stmt.Fill = FillValue
+		stmt.FillValue, _ = strconv.ParseFloat("-"+n.lit, 64)
+	default:
+		return fmt.Errorf("found %s, expected fill option at %d", it, it.pos)
+	}
+	_, err = p.expect(RPAREN)
+	return err
+}
+
+// parseExpr parses a binary expression whose operators bind tighter than minPrecedence.
+func (p *parser) parseExpr(minPrecedence int) (Expr, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		it, err := p.scan()
+		if err != nil {
+			return nil, err
+		}
+		prec := it.tok.precedence()
+		if prec == 0 || prec <= minPrecedence {
+			p.unscan(it)
+			return lhs, nil
+		}
+		rhs, err := p.parseExpr(prec)
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{Op: it.tok, LHS: lhs, RHS: rhs}
+	}
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	it, err := p.scan()
+	if err != nil {
+		return nil, err
+	}
+	switch it.tok {
+	case LPAREN:
+		expr, err := p.parseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expect(RPAREN)
+		if err != nil {
+			return nil, err
+		}
+		return &ParenExpr{Expr: expr}, nil
+	case MUL:
+		return &Wildcard{}, nil
+	case STRING:
+		return &StringLiteral{Val: it.lit}, nil
+	case TRUE, FALSE:
+		return &BooleanLiteral{Val: it.tok == TRUE}, nil
+	case REGEX:
+		return &RegexLiteral{Val: it.lit}, nil
+	case INTEGER:
+		v, err := strconv.ParseInt(it.lit, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %s at %d", it.lit, it.pos)
+		}
+		return &IntegerLiteral{Val: v}, nil
+	case NUMBER:
+		v, err := strconv.ParseFloat(it.lit, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at %d", it.lit, it.pos)
+		}
+		return &NumberLiteral{Val: v}, nil
+	case DURATION:
+		d, err := ParseDuration(it.lit)
+		if err != nil {
+			return nil, err
+		}
+		return &DurationLiteral{Val: d}, nil
+	case SUB:
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		switch e := expr.(type) {
+		case *IntegerLiteral:
+			e.Val = -e.Val
+			return e, nil
+		case *NumberLiteral:
+			e.Val = -e.Val
+			return e, nil
+		case *DurationLiteral:
+			e.Val = -e.Val
+			return e, nil
+		}
+		return &BinaryExpr{Op: MUL, LHS: &IntegerLiteral{Val: -1}, RHS: expr}, nil
+	case IDENT:
+		next, err := p.scan()
+		if err != nil {
+			return nil, err
+		}
+		if next.tok != LPAREN || it.quoted {
+			p.unscan(next)
+			return &VarRef{Name: it.lit}, nil
+		}
+		call := &Call{Name: strings.ToLower(it.lit)}
+		if n, err := p.peek(); err != nil {
+			return nil, err
+		} else if n.tok == RPAREN {
+			_, _ = p.scan()
+			return call, nil
+		}
+		for {
+			arg, err := p.parseExpr(0)
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+			sep, err := p.scan()
+			if err != nil {
+				return nil, err
+			}
+			if sep.tok == RPAREN {
+				return call, nil
+			}
+			if sep.tok != COMMA {
+				return nil, fmt.Errorf("found %s, expected , or ) at %d", sep, sep.pos)
+			}
+		}
+	}
+	return nil, fmt.Errorf("found %s, expected expression at %d", it, it.pos)
+}
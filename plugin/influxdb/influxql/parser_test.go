@@ -0,0 +1,82 @@
+package influxql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		q    string
+		want []Statement
+	}{
+		{
+			name: "show",
+			q:    `SHOW DATABASES; show measurements on db; SHOW TAG KEYS FROM "db"."autogen"."cpu"; SHOW TAG VALUES ON db FROM cpu WITH KEY = "host"; SHOW FIELD KEYS FROM cpu`,
+			want: []Statement{
+				&ShowDatabasesStatement{},
+				&ShowMeasurementsStatement{Database: "db"},
+				&ShowTagKeysStatement{Database: "db", Measurement: "cpu"},
+				&ShowTagValuesStatement{Database: "db", Measurement: "cpu", Key: "host"},
+				&ShowFieldKeysStatement{Measurement: "cpu"},
+			},
+		},
+		{
+			name: "select",
+			q:    `SELECT mean("usage") AS avg_usage FROM db..cpu WHERE host = 'a' AND time > now() - 1h GROUP BY time(10m), host fill(previous) ORDER BY time DESC LIMIT 10 SLIMIT 2`,
+			want: []Statement{&SelectStatement{
+				Fields:      []*Field{{Expr: &Call{Name: "mean", Args: []Expr{&VarRef{Name: "usage"}}}, Alias: "avg_usage"}},
+				Database:    "db",
+				Measurement: "cpu",
+				Condition: &BinaryExpr{
+					Op:  AND,
+					LHS: &BinaryExpr{Op: EQ, LHS: &VarRef{Name: "host"}, RHS: &StringLiteral{Val: "a"}},
+					RHS: &BinaryExpr{
+						Op:  GT,
+						LHS: &VarRef{Name: "time"},
+						RHS: &BinaryExpr{Op: SUB, LHS: &Call{Name: "now"}, RHS: &DurationLiteral{Val: time.Hour}},
+					},
+				},
+				Interval:   10 * time.Minute,
+				GroupByTag: []string{"host"},
+				Fill:       FillPrevious,
+				Descending: true,
+				Limit:      10,
+				SLimit:     2,
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQuery(tt.q)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseQueryError(t *testing.T) {
+	for _, q := range []string{
+		"",
+		"DROP DATABASE db",
+		"SELECT FROM cpu",
+		"SELECT * FROM /cpu.*/",
+		"SELECT * FROM cpu WHERE host = 'a",
+		"SELECT * FROM cpu LIMIT x",
+		"SELECT mean(v) FROM cpu GROUP BY time(10x)",
+	} {
+		_, err := ParseQuery(q)
+		assert.Error(t, err, q)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	d, err := ParseDuration("90s")
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Second, d)
+	_, err = ParseDuration("1y")
+	assert.Error(t, err)
+}
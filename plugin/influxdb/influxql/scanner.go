@@ -0,0 +1,342 @@
+package influxql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Token int
+
+const (
+	ILLEGAL Token = iota
+	EOF
+	IDENT
+	STRING
+	NUMBER
+	INTEGER
+	DURATION
+	REGEX
+	TRUE
+	FALSE
+
+	ADD
+	SUB
+	MUL
+	DIV
+
+	AND
+	OR
+
+	EQ
+	NEQ
+	EQREGEX
+	NEQREGEX
+	LT
+	LTE
+	GT
+	GTE
+
+	LPAREN
+	RPAREN
+	COMMA
+	DOT
+	SEMICOLON
+)
+
+var tokenStrings = map[Token]string{
+	ILLEGAL:   "ILLEGAL",
+	EOF:       "EOF",
+	IDENT:     "IDENT",
+	STRING:    "STRING",
+	NUMBER:    "NUMBER",
+	INTEGER:   "INTEGER",
+	DURATION:  "DURATION",
+	REGEX:     "REGEX",
+	TRUE:      "TRUE",
+	FALSE:     "FALSE",
+	ADD:       "+",
+	SUB:       "-",
+	MUL:       "*",
+	DIV:       "/",
+	AND:       "AND",
+	OR:        "OR",
+	EQ:        "=",
+	NEQ:       "!=",
+	EQREGEX:   "=~",
+	NEQREGEX:  "!~",
+	LT:        "<",
+	LTE:       "<=",
+	GT:        ">",
+	GTE:       ">=",
+	LPAREN:    "(",
+	RPAREN:    ")",
+	COMMA:     ",",
+	DOT:       ".",
+	SEMICOLON: ";",
+}
+
+func (t Token) String() string {
+	return tokenStrings[t]
+}
+
+// precedence of binary operators, 0 means not a binary operator
+func (t Token) precedence() int {
+	switch t {
+	case OR:
+		return 1
+	case AND:
+		return 2
+	case EQ, NEQ, EQREGEX, NEQREGEX, LT, LTE, GT, GTE:
+		return 3
+	case ADD, SUB:
+		return 4
+	case MUL, DIV:
+		return 5
+	}
+	return 0
+}
+
+type item struct {
+	tok Token
+	pos int
+	lit string
+	// quoted is true for identifiers in double quotes, they are never keywords
+	quoted bool
+}
+
+func (i item) String() string {
+	if len(i.lit) != 0 {
+		return i.lit
+	}
+	return i.tok.String()
+}
+
+// isKeyword reports whether the item is the unquoted keyword kw, keywords are case insensitive.
+func (i item) isKeyword(kw string) bool {
+	return i.tok == IDENT && !i.quoted && strings.EqualFold(i.lit, kw)
+}
+
+type scanner struct {
+	s    string
+	pos  int
+	last Token
+}
+
+func (s *scanner) next() rune {
+	if s.pos >= len(s.s) {
+		return 0
+	}
+	r, w := utf8.DecodeRuneInString(s.s[s.pos:])
+	s.pos += w
+	return r
+}
+
+func (s *scanner) peek() rune {
+	if s.pos >= len(s.s) {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(s.s[s.pos:])
+	return r
+}
+
+func (s *scanner) scan() (item, error) {
+	it, err := s.scanItem()
+	s.last = it.tok
+	return it, err
+}
+
+func (s *scanner) scanItem() (item, error) {
+	for unicode.IsSpace(s.peek()) {
+		s.next()
+	}
+	pos := s.pos
+	r := s.next()
+	switch {
+	case r == 0:
+		return item{tok: EOF, pos: pos}, nil
+	case r == '/' && (s.last == EQREGEX || s.last == NEQREGEX):
+		return s.scanRegex(pos)
+	case isIdentStart(r):
+		for isIdentChar(s.peek()) {
+			s.next()
+		}
+		lit := s.s[pos:s.pos]
+		switch strings.ToLower(lit) {
+		case "and":
+			return item{tok: AND, pos: pos, lit: lit}, nil
+		case "or":
+			return item{tok: OR, pos: pos, lit: lit}, nil
+		case "true":
+			return item{tok: TRUE, pos: pos, lit: lit}, nil
+		case "false":
+			return item{tok: FALSE, pos: pos, lit: lit}, nil
+		}
+		return item{tok: IDENT, pos: pos, lit: lit}, nil
+	case r == '"':
+		lit, err := s.scanQuoted('"', pos)
+		return item{tok: IDENT, pos: pos, lit: lit, quoted: true}, err
+	case r == '\'':
+		lit, err := s.scanQuoted('\'', pos)
+		return item{tok: STRING, pos: pos, lit: lit}, err
+	case isDigit(r) || (r == '.' && isDigit(s.peek())):
+		return s.scanNumber(pos)
+	}
+	switch r {
+	case '+':
+		return item{tok: ADD, pos: pos}, nil
+	case '-':
+		return item{tok: SUB, pos: pos}, nil
+	case '*':
+		return item{tok: MUL, pos: pos}, nil
+	case '/':
+		return item{tok: DIV, pos: pos}, nil
+	case '(':
+		return item{tok: LPAREN, pos: pos}, nil
+	case ')':
+		return item{tok: RPAREN, pos: pos}, nil
+	case ',':
+		return item{tok: COMMA, pos: pos}, nil
+	case '.':
+		return item{tok: DOT, pos: pos}, nil
+	case ';':
+		return item{tok: SEMICOLON, pos: pos}, nil
+	case '=':
+		if s.peek() == '~' {
+			s.next()
+			return item{tok: EQREGEX, pos: pos}, nil
+		}
+		return item{tok: EQ, pos: pos}, nil
+	case '!':
+		switch s.next() {
+		case '=':
+			return item{tok: NEQ, pos: pos}, nil
+		case '~':
+			return item{tok: NEQREGEX, pos: pos}, nil
+		}
+	case '<':
+		switch s.peek() {
+		case '=':
+			s.next()
+			return item{tok: LTE, pos: pos}, nil
+		case '>':
+			s.next()
+			return item{tok: NEQ, pos: pos}, nil
+		}
+		return item{tok: LT, pos: pos}, nil
+	case '>':
+		if s.peek() == '=' {
+			s.next()
+			return item{tok: GTE, pos: pos}, nil
+		}
+		return item{tok: GT, pos: pos}, nil
+	}
+	return item{tok: ILLEGAL, pos: pos, lit: s.s[pos:s.pos]}, fmt.Errorf("found %s, unexpected character at %d", s.s[pos:s.pos], pos)
+}
+
+func (s *scanner) scanQuoted(quote rune, pos int) (string, error) {
+	var b strings.Builder
+	for {
+		r := s.next()
+		switch r {
+		case 0:
+			return "", fmt.Errorf("unterminated quoted string at %d", pos)
+		case quote:
+			return b.String(), nil
+		case '\\':
+			next := s.next()
+			switch next {
+			case quote, '\\':
+				b.WriteRune(next)
+			case 'n':
+				b.WriteRune('\n')
+			default:
+				b.WriteRune('\\')
+				b.WriteRune(next)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+func (s *scanner) scanRegex(pos int) (item, error) {
+	var b strings.Builder
+	for {
+		r := s.next()
+		switch r {
+		case 0:
+			return item{tok: ILLEGAL, pos: pos}, fmt.Errorf("unterminated regex at %d", pos)
+		case '/':
+			return item{tok: REGEX, pos: pos, lit: b.String()}, nil
+		case '\\':
+			if s.peek() == '/' {
+				b.WriteRune(s.next())
+			} else {
+				b.WriteRune(r)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// scanNumber scans an integer, a float or a duration literal such as 10m, a duration is an integer
+// immediately followed by a unit.
+func (s *scanner) scanNumber(pos int) (item, error) {
+	s.pos = pos
+	for isDigit(s.peek()) {
+		s.next()
+	}
+	isFloat := false
+	if s.peek() == '.' {
+		isFloat = true
+		s.next()
+		for isDigit(s.peek()) {
+			s.next()
+		}
+	}
+	if s.peek() == 'e' || s.peek() == 'E' {
+		save := s.pos
+		s.next()
+		if s.peek() == '+' || s.peek() == '-' {
+			s.next()
+		}
+		if isDigit(s.peek()) {
+			isFloat = true
+			for isDigit(s.peek()) {
+				s.next()
+			}
+		} else {
+			s.pos = save
+		}
+	}
+	lit := s.s[pos:s.pos]
+	if isFloat {
+		return item{tok: NUMBER, pos: pos, lit: lit}, nil
+	}
+	unitStart := s.pos
+	for isIdentChar(s.peek()) || s.peek() == 'µ' {
+		s.next()
+	}
+	if s.pos == unitStart {
+		return item{tok: INTEGER, pos: pos, lit: lit}, nil
+	}
+	if _, ok := durationUnits[s.s[unitStart:s.pos]]; !ok {
+		return item{tok: ILLEGAL, pos: pos, lit: s.s[pos:s.pos]}, fmt.Errorf("invalid duration %s at %d", s.s[pos:s.pos], pos)
+	}
+	return item{tok: DURATION, pos: pos, lit: s.s[pos:s.pos]}, nil
+}
+
+func isIdentStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
+
+func isIdentChar(r rune) bool {
+	return isIdentStart(r) || isDigit(r)
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
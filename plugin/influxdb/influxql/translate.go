@@ -0,0 +1,487 @@
+package influxql
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema describes a super table created by the schemaless interface.
+type Schema struct {
+	TimeColumn string
+	Tags       []string
+	Fields     []string
+}
+
+func (s *Schema) isTag(name string) bool {
+	for _, tag := range s.Tags {
+		if tag == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) isField(name string) bool {
+	for _, field := range s.Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Query is a select statement translated to TDengine sql. Rows of the result are, in order, the timestamp
+// when HasTime is true, one value per element of Columns except time and the values of GroupByTags.
+type Query struct {
+	SQL         string
+	Columns     []string
+	HasTime     bool
+	GroupByTags []string
+	// StartTime is the lower time bound of the condition, influxdb uses it as the time of aggregates
+	// without GROUP BY time(), the epoch when the condition has no lower time bound
+	StartTime time.Time
+}
+
+var functions = map[string]string{
+	"mean":   "avg",
+	"count":  "count",
+	"sum":    "sum",
+	"min":    "min",
+	"max":    "max",
+	"first":  "first",
+	"last":   "last",
+	"spread": "spread",
+	"stddev": "stddev",
+}
+
+type translator struct {
+	schema    *Schema
+	now       time.Time
+	startTime time.Time
+	b         strings.Builder
+}
+
+// Translate translates stmt to sql on db.measurement, now is the time of now() in the condition.
+func Translate(stmt *SelectStatement, db string, schema *Schema, now time.Time) (*Query, error) {
+	t := &translator{schema: schema, now: now, startTime: time.Unix(0, 0).UTC()}
+	q := &Query{Columns: []string{"time"}}
+	fields, aggregate, err := t.expandFields(stmt.Fields)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range stmt.GroupByTag {
+		if !schema.isTag(tag) {
+			return nil, fmt.Errorf("unknown tag %s", tag)
+		}
+	}
+	if !aggregate {
+		if stmt.Interval != 0 {
+			return nil, errors.New("GROUP BY time() requires an aggregate function")
+		}
+		if len(stmt.GroupByTag) != 0 {
+			return nil, errors.New("GROUP BY tags requires an aggregate function")
+		}
+	}
+	q.HasTime = !aggregate || stmt.Interval != 0
+	q.GroupByTags = stmt.GroupByTag
+	t.b.WriteString("select ")
+	if !aggregate {
+		t.b.WriteString(schema.TimeColumn)
+		t.b.WriteByte(',')
+	}
+	for i, field := range fields {
+		if i != 0 {
+			t.b.WriteByte(',')
+		}
+		err = t.fieldSQL(field.Expr)
+		if err != nil {
+			return nil, err
+		}
+		q.Columns = append(q.Columns, field.Name())
+	}
+	fmt.Fprintf(&t.b, " from %s.%s", db, stmt.Measurement)
+	if stmt.Condition != nil {
+		t.b.WriteString(" where ")
+		err = t.conditionSQL(stmt.Condition)
+		if err != nil {
+			return nil, err
+		}
+	}
+	q.StartTime = t.startTime
+	if stmt.Interval != 0 {
+		t.b.WriteString(" interval(")
+		t.b.WriteString(durationSQL(stmt.Interval))
+		if stmt.Offset != 0 {
+			t.b.WriteByte(',')
+			t.b.WriteString(durationSQL(stmt.Offset))
+		}
+		t.b.WriteByte(')')
+		switch stmt.Fill {
+		case FillDefault, FillNull:
+			t.b.WriteString(" fill(null)")
+		case FillPrevious:
+			t.b.WriteString(" fill(prev)")
+		case FillLinear:
+			t.b.WriteString(" fill(linear)")
+		case FillValue:
+			t.b.WriteString(" fill(value,")
+			t.b.WriteString(strconv.FormatFloat(stmt.FillValue, 'f', -1, 64))
+			t.b.WriteByte(')')
+		}
+	}
+	if len(stmt.GroupByTag) != 0 {
+		t.b.WriteString(" group by ")
+		t.b.WriteString(strings.Join(stmt.GroupByTag, ","))
+	}
+	if q.HasTime {
+		t.b.WriteString(" order by ")
+		t.b.WriteString(schema.TimeColumn)
+		if stmt.Descending {
+			t.b.WriteString(" desc")
+		}
+	}
+	if stmt.SLimit > 0 {
+		fmt.Fprintf(&t.b, " slimit %d", stmt.SLimit)
+		if stmt.SOffset > 0 {
+			fmt.Fprintf(&t.b, " soffset %d", stmt.SOffset)
+		}
+	}
+	if stmt.Limit > 0 {
+		fmt.Fprintf(&t.b, " limit %d", stmt.Limit)
+		if stmt.OffsetRows > 0 {
+			fmt.Fprintf(&t.b, " offset %d", stmt.OffsetRows)
+		}
+	}
+	q.SQL = t.b.String()
+	return q, nil
+}
+
+// expandFields replaces a top level wildcard by all fields and tags and drops time, which is always the first column.
+func (t *translator) expandFields(fields []*Field) (result []*Field, aggregate bool, err error) {
+	raw := false
+	for _, field := range fields {
+		switch e := field.Expr.(type) {
+		case *Wildcard:
+			for _, name := range t.schema.Fields {
+				result = append(result, &Field{Expr: &VarRef{Name: name}})
+			}
+			for _, name := range t.schema.Tags {
+				result = append(result, &Field{Expr: &VarRef{Name: name}})
+			}
+			raw = true
+			continue
+		case *VarRef:
+			if strings.EqualFold(e.Name, "time") {
+				continue
+			}
+		}
+		if hasCall(field.Expr) {
+			aggregate = true
+		} else {
+			raw = true
+		}
+		result = append(result, field)
+	}
+	if aggregate && raw {
+		return nil, false, errors.New("mixing aggregate and non-aggregate queries is not supported")
+	}
+	if len(result) == 0 {
+		return nil, false, errors.New("at least 1 non-time field must be queried")
+	}
+	return result, aggregate, nil
+}
+
+func hasCall(expr Expr) bool {
+	switch e := expr.(type) {
+	case *Call:
+		return true
+	case *ParenExpr:
+		return hasCall(e.Expr)
+	case *BinaryExpr:
+		return hasCall(e.LHS) || hasCall(e.RHS)
+	}
+	return false
+}
+
+func (t *translator) fieldSQL(expr Expr) error {
+	switch e := expr.(type) {
+	case *VarRef:
+		if !t.schema.isField(e.Name) && !t.schema.isTag(e.Name) {
+			return fmt.Errorf("unknown field or tag %s", e.Name)
+		}
+		t.b.WriteString(e.Name)
+	case *Call:
+		return t.callSQL(e)
+	case *ParenExpr:
+		t.b.WriteByte('(')
+		err := t.fieldSQL(e.Expr)
+		if err != nil {
+			return err
+		}
+		t.b.WriteByte(')')
+	case *BinaryExpr:
+		switch e.Op {
+		case ADD, SUB, MUL, DIV:
+		default:
+			return fmt.Errorf("invalid operator %s in field", e.Op)
+		}
+		err := t.fieldSQL(e.LHS)
+		if err != nil {
+			return err
+		}
+		t.b.WriteString(e.Op.String())
+		return t.fieldSQL(e.RHS)
+	case *IntegerLiteral, *NumberLiteral:
+		return t.literalSQL(expr)
+	default:
+		return errors.New("unsupported field expression")
+	}
+	return nil
+}
+
+func (t *translator) callSQL(call *Call) error {
+	var name string
+	var args []Expr
+	switch call.Name {
+	case "median":
+		if len(call.Args) != 1 {
+			return errors.New("median() requires 1 argument")
+		}
+		name = "apercentile"
+		args = []Expr{call.Args[0], &IntegerLiteral{Val: 50}}
+	case "percentile":
+		if len(call.Args) != 2 {
+			return errors.New("percentile() requires 2 arguments")
+		}
+		switch call.Args[1].(type) {
+		case *IntegerLiteral, *NumberLiteral:
+		default:
+			return errors.New("percentile() requires a number as second argument")
+		}
+		name = "apercentile"
+		args = call.Args
+	default:
+		var ok bool
+		name, ok = functions[call.Name]
+		if !ok {
+			return fmt.Errorf("unsupported function %s()", call.Name)
+		}
+		if len(call.Args) != 1 {
+			return fmt.Errorf("%s() requires 1 argument", call.Name)
+		}
+		args = call.Args
+	}
+	t.b.WriteString(name)
+	t.b.WriteByte('(')
+	switch e := args[0].(type) {
+	case *Wildcard:
+		if call.Name != "count" {
+			return fmt.Errorf("%s(*) is not supported", call.Name)
+		}
+		t.b.WriteByte('*')
+	case *VarRef:
+		if !t.schema.isField(e.Name) {
+			return fmt.Errorf("unknown field %s", e.Name)
+		}
+		t.b.WriteString(e.Name)
+	default:
+		return fmt.Errorf("invalid argument of %s()", call.Name)
+	}
+	for _, arg := range args[1:] {
+		t.b.WriteByte(',')
+		err := t.literalSQL(arg)
+		if err != nil {
+			return err
+		}
+	}
+	t.b.WriteByte(')')
+	return nil
+}
+
+func (t *translator) conditionSQL(expr Expr) error {
+	switch e := expr.(type) {
+	case *ParenExpr:
+		t.b.WriteByte('(')
+		err := t.conditionSQL(e.Expr)
+		if err != nil {
+			return err
+		}
+		t.b.WriteByte(')')
+		return nil
+	case *BinaryExpr:
+		switch e.Op {
+		case AND, OR:
+			t.b.WriteByte('(')
+			err := t.conditionSQL(e.LHS)
+			if err != nil {
+				return err
+			}
+			t.b.WriteByte(' ')
+			t.b.WriteString(e.Op.String())
+			t.b.WriteByte(' ')
+			err = t.conditionSQL(e.RHS)
+			if err != nil {
+				return err
+			}
+			t.b.WriteByte(')')
+			return nil
+		case EQREGEX, NEQREGEX:
+			return errors.New("regular expression is not supported")
+		case EQ, NEQ, LT, LTE, GT, GTE:
+			return t.comparisonSQL(e)
+		}
+	}
+	return errors.New("unsupported condition")
+}
+
+var flipped = map[Token]Token{EQ: EQ, NEQ: NEQ, LT: GT, LTE: GTE, GT: LT, GTE: LTE}
+
+func (t *translator) comparisonSQL(e *BinaryExpr) error {
+	op := e.Op
+	ref, ok := e.LHS.(*VarRef)
+	value := e.RHS
+	if !ok {
+		ref, ok = e.RHS.(*VarRef)
+		if !ok {
+			return errors.New("condition requires a field, tag or time on one side")
+		}
+		value = e.LHS
+		op = flipped[op]
+	}
+	if strings.EqualFold(ref.Name, "time") {
+		ts, err := t.evalTime(value)
+		if err != nil {
+			return err
+		}
+		if (op == GT || op == GTE) && ts.After(t.startTime) {
+			t.startTime = ts
+		}
+		fmt.Fprintf(&t.b, "%s %s '%s'", t.schema.TimeColumn, op, ts.UTC().Format("2006-01-02T15:04:05.999999999Z"))
+		return nil
+	}
+	if t.schema.isTag(ref.Name) {
+		s, ok := value.(*StringLiteral)
+		if !ok {
+			return fmt.Errorf("tag %s must be compared with a string", ref.Name)
+		}
+		if len(s.Val) == 0 {
+			switch op {
+			case EQ:
+				fmt.Fprintf(&t.b, "%s is null", ref.Name)
+				return nil
+			case NEQ:
+				fmt.Fprintf(&t.b, "%s is not null", ref.Name)
+				return nil
+			}
+		}
+		if op == NEQ {
+			// influxdb matches the series without the tag as well
+			fmt.Fprintf(&t.b, "(%s <> ", ref.Name)
+			err := t.literalSQL(value)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&t.b, " or %s is null)", ref.Name)
+			return nil
+		}
+	} else if !t.schema.isField(ref.Name) {
+		return fmt.Errorf("unknown field or tag %s", ref.Name)
+	}
+	t.b.WriteString(ref.Name)
+	t.b.WriteByte(' ')
+	if op == NEQ {
+		t.b.WriteString("<>")
+	} else {
+		t.b.WriteString(op.String())
+	}
+	t.b.WriteByte(' ')
+	return t.literalSQL(value)
+}
+
+func (t *translator) literalSQL(expr Expr) error {
+	switch e := expr.(type) {
+	case *StringLiteral:
+		t.b.WriteByte('\'')
+		t.b.WriteString(strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(e.Val))
+		t.b.WriteByte('\'')
+	case *IntegerLiteral:
+		t.b.WriteString(strconv.FormatInt(e.Val, 10))
+	case *NumberLiteral:
+		t.b.WriteString(strconv.FormatFloat(e.Val, 'f', -1, 64))
+	case *BooleanLiteral:
+		t.b.WriteString(strconv.FormatBool(e.Val))
+	default:
+		return errors.New("unsupported literal")
+	}
+	return nil
+}
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02"}
+
+// evalTime evaluates a time expression such as now() - 1h, an rfc3339 string or an epoch in nanoseconds.
+func (t *translator) evalTime(expr Expr) (time.Time, error) {
+	switch e := expr.(type) {
+	case *ParenExpr:
+		return t.evalTime(e.Expr)
+	case *Call:
+		if e.Name == "now" && len(e.Args) == 0 {
+			return t.now, nil
+		}
+	case *StringLiteral:
+		for _, layout := range timeLayouts {
+			ts, err := time.Parse(layout, e.Val)
+			if err == nil {
+				return ts, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid time %s", e.Val)
+	case *IntegerLiteral:
+		return time.Unix(0, e.Val), nil
+	case *NumberLiteral:
+		return time.Unix(0, int64(e.Val)), nil
+	case *DurationLiteral:
+		return time.Unix(0, int64(e.Val)), nil
+	case *BinaryExpr:
+		if e.Op != ADD && e.Op != SUB {
+			break
+		}
+		ts, err := t.evalTime(e.LHS)
+		if err != nil {
+			return ts, err
+		}
+		d, ok := e.RHS.(*DurationLiteral)
+		if !ok {
+			return ts, errors.New("time can only be added or subtracted by a duration")
+		}
+		if e.Op == SUB {
+			return ts.Add(-d.Val), nil
+		}
+		return ts.Add(d.Val), nil
+	}
+	return time.Time{}, errors.New("invalid time expression")
+}
+
+// durationSQL formats d with the largest TDengine time unit which keeps it exact.
+func durationSQL(d time.Duration) string {
+	units := []struct {
+		unit   time.Duration
+		abbrev string
+	}{
+		{7 * 24 * time.Hour, "w"},
+		{24 * time.Hour, "d"},
+		{time.Hour, "h"},
+		{time.Minute, "m"},
+		{time.Second, "s"},
+		{time.Millisecond, "a"},
+		{time.Microsecond, "u"},
+	}
+	for _, u := range units {
+		if d%u.unit == 0 {
+			return strconv.FormatInt(int64(d/u.unit), 10) + u.abbrev
+		}
+	}
+	return strconv.FormatInt(int64(d), 10) + "b"
+}
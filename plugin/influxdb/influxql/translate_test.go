@@ -0,0 +1,85 @@
+package influxql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSchema = &Schema{TimeColumn: "_ts", Tags: []string{"host", "region"}, Fields: []string{"usage", "idle"}}
+
+func TestTranslate(t *testing.T) {
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		q           string
+		sql         string
+		columns     []string
+		hasTime     bool
+		groupByTags []string
+		startTime   time.Time
+	}{
+		{
+			name:      "raw",
+			q:         `SELECT * FROM cpu WHERE host = 'it\'s' OR region = '' LIMIT 5 OFFSET 1`,
+			sql:       `select _ts,usage,idle,host,region from db.cpu where (host = 'it\'s' OR region is null) order by _ts limit 5 offset 1`,
+			columns:   []string{"time", "usage", "idle", "host", "region"},
+			hasTime:   true,
+			startTime: time.Unix(0, 0),
+		},
+		{
+			name:      "tag not equal",
+			q:         `SELECT count(usage) FROM cpu WHERE host != 'a' AND usage != 1`,
+			sql:       `select count(usage) from db.cpu where ((host <> 'a' or host is null) AND usage <> 1)`,
+			columns:   []string{"time", "count"},
+			startTime: time.Unix(0, 0),
+		},
+		{
+			name:        "aggregate",
+			q:           `SELECT mean(usage), median(idle) FROM cpu WHERE time >= now() - 1h AND time < '2021-10-01T12:00:00Z' GROUP BY time(1m, 30s), host fill(0) ORDER BY time DESC`,
+			sql:         `select avg(usage),apercentile(idle,50) from db.cpu where (_ts >= '2021-10-01T11:00:00Z' AND _ts < '2021-10-01T12:00:00Z') interval(1m,30s) fill(value,0) group by host order by _ts desc`,
+			columns:     []string{"time", "mean", "median"},
+			hasTime:     true,
+			groupByTags: []string{"host"},
+			startTime:   now.Add(-time.Hour),
+		},
+		{
+			name:      "no interval",
+			q:         `SELECT count(*) FROM cpu WHERE time > 1633089600000000000`,
+			sql:       `select count(*) from db.cpu where _ts > '2021-10-01T12:00:00Z'`,
+			columns:   []string{"time", "count"},
+			startTime: now,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmts, err := ParseQuery(tt.q)
+			assert.NoError(t, err)
+			q, err := Translate(stmts[0].(*SelectStatement), "db", testSchema, now)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.sql, q.SQL)
+			assert.Equal(t, tt.columns, q.Columns)
+			assert.Equal(t, tt.hasTime, q.HasTime)
+			assert.Equal(t, tt.groupByTags, q.GroupByTags)
+			assert.True(t, tt.startTime.Equal(q.StartTime))
+		})
+	}
+}
+
+func TestTranslateError(t *testing.T) {
+	for _, q := range []string{
+		`SELECT unknown FROM cpu`,
+		`SELECT usage, mean(idle) FROM cpu`,
+		`SELECT usage FROM cpu GROUP BY time(1m)`,
+		`SELECT mean(usage) FROM cpu GROUP BY "x; drop database db"`,
+		`SELECT usage FROM cpu WHERE host =~ /a/`,
+		`SELECT usage FROM cpu WHERE host = 1`,
+		`SELECT holt_winters(usage) FROM cpu`,
+	} {
+		stmts, err := ParseQuery(q)
+		assert.NoError(t, err, q)
+		_, err = Translate(stmts[0].(*SelectStatement), "db", testSchema, time.Now())
+		assert.Error(t, err, q)
+	}
+}
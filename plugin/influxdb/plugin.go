@@ -1,17 +1,23 @@
 package influxdb
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"github.com/taosdata/blm3/config"
 	"github.com/taosdata/blm3/db/commonpool"
 	"github.com/taosdata/blm3/log"
 	"github.com/taosdata/blm3/plugin"
 	"github.com/taosdata/blm3/schemaless/capi"
 	"github.com/taosdata/blm3/tools"
+	"github.com/taosdata/blm3/tools/certauth"
+	"github.com/taosdata/blm3/tools/limit"
+	"github.com/taosdata/blm3/tools/login"
+	"github.com/taosdata/blm3/tools/monitor"
 	"github.com/taosdata/blm3/tools/web"
 	"github.com/taosdata/driver-go/v2/af"
 )
@@ -19,7 +25,9 @@ import (
 var logger = log.GetLogger("influxdb")
 
 type Influxdb struct {
+	plugin.State
 	conf        Config
+	routed      bool
 	reserveConn *af.Connector
 }
 
@@ -37,18 +45,41 @@ func (p *Influxdb) Init(r gin.IRouter) error {
 		logger.Info("influxdb disabled")
 		return nil
 	}
-	r.POST("write", getAuth, p.write)
+	p.SetEnabled(true)
+	ipLimiter := limit.IPHandler(limitResponse)
+	userLimiter := limit.UserHandler(limitResponse)
+	r.POST("write", ipLimiter, getAuth, userLimiter, p.write)
+	r.GET("query", ipLimiter, getAuth, userLimiter, p.query)
+	r.POST("query", ipLimiter, getAuth, userLimiter, p.query)
+	p.routed = true
 	return nil
 }
 
+// Reload reads the configuration, enabling influxdb takes a restart when it was disabled at startup because the
+// routes were not registered.
+func (p *Influxdb) Reload() ([]string, func() error) {
+	var conf Config
+	conf.setValue()
+	return config.Diff("influxdb", p.conf, conf), func() error {
+		if conf.Enable && !p.routed {
+			return plugin.ErrRestartRequired
+		}
+		p.conf = conf
+		p.SetEnabled(conf.Enable)
+		return nil
+	}
+}
+
 func (p *Influxdb) Start() error {
 	if !p.conf.Enable {
 		return nil
 	}
+	p.SetListening(true)
 	return nil
 }
 
 func (p *Influxdb) Stop() error {
+	p.SetListening(false)
 	if p.reserveConn != nil {
 		return p.reserveConn.Close()
 	}
@@ -58,7 +89,6 @@ func (p *Influxdb) Stop() error {
 func (p *Influxdb) write(c *gin.Context) {
 	id := web.GetRequestID(c)
 	logger := logger.WithField("sessionID", id)
-	isDebug := logger.Logger.IsLevelEnabled(logrus.DebugLevel)
 	precision := c.Query("precision")
 	if len(precision) == 0 {
 		precision = "ns"
@@ -95,10 +125,21 @@ func (p *Influxdb) write(c *gin.Context) {
 		})
 		return
 	}
+	insertLines(c, logger, "influxdb", &p.State, user, password, db, precision, data)
+}
+
+// insertLines writes data to db through the schemaless interface and responds 204 on success, the points are counted
+// under name.
+func insertLines(c *gin.Context, logger *logrus.Entry, name string, state *plugin.State, user, password, db, precision string, data []byte) {
+	isDebug := logger.Logger.IsLevelEnabled(logrus.DebugLevel)
+	lines := monitor.CountLines(data)
+	monitor.AddPoints(name, monitor.PointsReceived, lines)
 	taosConn, err := commonpool.GetConnection(user, password)
 	if err != nil {
+		monitor.AddPoints(name, monitor.PointsFailed, lines)
+		state.WriteFailed(err)
 		logger.WithError(err).Errorln("connect taosd error")
-		p.commonResponse(c, http.StatusInternalServerError, &message{Code: "internal error", Message: err.Error()})
+		commonResponse(c, http.StatusInternalServerError, &message{Code: "internal error", Message: err.Error()})
 		return
 	}
 	defer func() {
@@ -116,13 +157,21 @@ func (p *Influxdb) write(c *gin.Context) {
 	result, err := capi.InsertInfluxdb(conn, data, db, precision)
 	logger.Debugln("finish insert influxdb cost:", time.Now().Sub(start))
 	if err != nil {
+		monitor.AddPoints(name, monitor.PointsFailed, lines)
+		state.WriteFailed(err)
 		logger.WithField("result", result).WithError(err).Errorln("insert line error")
-		p.commonResponse(c, http.StatusInternalServerError, &message{Code: "internal error", Message: err.Error()})
+		commonResponse(c, http.StatusInternalServerError, &message{Code: "internal error", Message: err.Error()})
 		return
 	}
+	monitor.AddPoints(name, monitor.PointsInserted, result.SuccessCount)
+	monitor.AddPoints(name, monitor.PointsFailed, result.FailCount)
+	if result.SuccessCount != 0 {
+		state.WriteSucceeded()
+	}
 	if result.FailCount != 0 {
+		state.WriteFailed(result.Errors[0])
 		logger.WithField("result", result).Errorln("insert line inner error success:", result.SuccessCount, "fail:", result.FailCount, "errors:", strings.Join(result.ErrorList, ","))
-		p.commonResponse(c, http.StatusInternalServerError, &message{Code: "internal error", Message: strings.Join(result.ErrorList, ",")})
+		c.JSON(http.StatusBadRequest, partialWrite(result))
 		return
 	}
 
@@ -137,6 +186,18 @@ type badRequest struct {
 	Line    int    `json:"line"`
 }
 
+// partialWrite reports the failed lines like influxdb does, the good lines have been written.
+func partialWrite(result *capi.Result) *badRequest {
+	first := result.Errors[0]
+	return &badRequest{
+		Code:    "invalid",
+		Message: fmt.Sprintf("partial write: %s dropped=%d", strings.Join(result.ErrorList, "; "), result.FailCount),
+		Op:      "writing points",
+		Err:     first.Err.Error(),
+		Line:    first.Line,
+	}
+}
+
 type message struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
@@ -146,18 +207,35 @@ func (p *Influxdb) badRequestResponse(c *gin.Context, resp *badRequest) {
 	c.JSON(http.StatusBadRequest, resp)
 }
 func (p *Influxdb) commonResponse(c *gin.Context, code int, resp *message) {
+	commonResponse(c, code, resp)
+}
+
+func commonResponse(c *gin.Context, code int, resp *message) {
 	c.JSON(code, resp)
 }
 
+func limitResponse(c *gin.Context, code int, err error) {
+	commonResponse(c, code, &message{Code: "too many requests", Message: err.Error()})
+}
+
+// getAuth reads the user and password of a client certificate, basic auth or the u and p query parameters, those sent
+// by the client are checked with taosd.
 func getAuth(c *gin.Context) {
+	sent := false
 	auth := c.GetHeader("Authorization")
-	if len(auth) != 0 {
+	if len(auth) == 0 {
+		if user, password, ok := certauth.User(c.Request); ok {
+			c.Set(plugin.UserKey, user)
+			c.Set(plugin.PasswordKey, password)
+		}
+	} else {
 		auth = strings.TrimSpace(auth)
 		if strings.HasPrefix(auth, "Basic") {
 			user, password, err := tools.DecodeBasic(auth[6:])
 			if err == nil {
 				c.Set(plugin.UserKey, user)
 				c.Set(plugin.PasswordKey, password)
+				sent = true
 			}
 		}
 	}
@@ -166,10 +244,32 @@ func getAuth(c *gin.Context) {
 	password := c.Query("p")
 	if len(user) != 0 {
 		c.Set(plugin.UserKey, user)
+		sent = true
 	}
 	if len(password) != 0 {
 		c.Set(plugin.PasswordKey, password)
+		sent = true
+	}
+	if !sent {
+		return
+	}
+	// missing credentials are answered by the handlers
+	user, password, err := plugin.GetAuth(c)
+	if err != nil {
+		return
+	}
+	check(c, "influxdb auth", user, password)
+}
+
+// check checks the credentials sent by the client with login.Check and answers the request when they are refused.
+func check(c *gin.Context, action, user, password string) bool {
+	err := login.Check(c, action, user, password)
+	if err != nil {
+		commonResponse(c, login.StatusCode(err), &message{Code: "unauthorized", Message: err.Error()})
+		c.Abort()
+		return false
 	}
+	return true
 }
 
 func init() {
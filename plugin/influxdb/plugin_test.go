@@ -0,0 +1,27 @@
+package influxdb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/schemaless/capi"
+)
+
+func TestPartialWrite(t *testing.T) {
+	result := &capi.Result{SuccessCount: 2, FailCount: 2}
+	for _, e := range []*capi.LineError{
+		{Line: 2, Content: "cpu value=", Err: errors.New("invalid field")},
+		{Line: 5, Content: "cpu", Err: errors.New("missing fields")},
+	} {
+		result.Errors = append(result.Errors, e)
+		result.ErrorList = append(result.ErrorList, e.Error())
+	}
+	assert.Equal(t, &badRequest{
+		Code:    "invalid",
+		Message: "partial write: line 2: invalid field; line 5: missing fields dropped=2",
+		Op:      "writing points",
+		Err:     "invalid field",
+		Line:    2,
+	}, partialWrite(result))
+}
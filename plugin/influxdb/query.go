@@ -0,0 +1,412 @@
+package influxdb
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taosdata/blm3/db/async"
+	"github.com/taosdata/blm3/db/commonpool"
+	"github.com/taosdata/blm3/httperror"
+	"github.com/taosdata/blm3/plugin"
+	"github.com/taosdata/blm3/plugin/influxdb/influxql"
+	"github.com/taosdata/blm3/tools/web"
+	"github.com/taosdata/driver-go/v2/common"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
+)
+
+var identifierRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+type queryResponse struct {
+	Results []*statementResult `json:"results"`
+}
+
+type statementResult struct {
+	StatementID int       `json:"statement_id"`
+	Series      []*series `json:"series,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+type series struct {
+	Name    string            `json:"name,omitempty"`
+	Tags    map[string]string `json:"tags,omitempty"`
+	Columns []string          `json:"columns"`
+	Values  [][]interface{}   `json:"values"`
+}
+
+type errorResp struct {
+	Error string `json:"error"`
+}
+
+func (p *Influxdb) query(c *gin.Context) {
+	id := web.GetRequestID(c)
+	logger := logger.WithField("sessionID", id)
+	user, password, err := plugin.GetAuth(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, &errorResp{Error: err.Error()})
+		return
+	}
+	q := c.Query("q")
+	if len(q) == 0 {
+		q = c.PostForm("q")
+	}
+	if len(q) == 0 {
+		c.JSON(http.StatusBadRequest, &errorResp{Error: `missing required parameter "q"`})
+		return
+	}
+	epoch := c.Query("epoch")
+	if len(epoch) == 0 {
+		epoch = c.PostForm("epoch")
+	}
+	if _, ok := epochUnits[epoch]; !ok && len(epoch) != 0 {
+		c.JSON(http.StatusBadRequest, &errorResp{Error: fmt.Sprintf("invalid epoch %s", epoch)})
+		return
+	}
+	db := c.Query("db")
+	if len(db) == 0 {
+		db = c.PostForm("db")
+	}
+	statements, err := influxql.ParseQuery(q)
+	if err != nil {
+		logger.WithError(err).Errorln("parse influxql error:", q)
+		c.JSON(http.StatusBadRequest, &errorResp{Error: "error parsing query: " + err.Error()})
+		return
+	}
+	taosConn, err := commonpool.GetConnection(user, password)
+	if err != nil {
+		logger.WithError(err).Errorln("connect taosd error")
+		c.JSON(http.StatusInternalServerError, &errorResp{Error: err.Error()})
+		return
+	}
+	defer func() {
+		putErr := taosConn.Put()
+		if putErr != nil {
+			logger.WithError(putErr).Errorln("taos connect pool put error")
+		}
+	}()
+	e := &executor{conn: taosConn.TaosConnection, db: db, epoch: epoch, now: time.Now()}
+	resp := &queryResponse{Results: make([]*statementResult, len(statements))}
+	for i, stmt := range statements {
+		result, err := e.execute(stmt)
+		if err != nil {
+			logger.WithError(err).Errorln("execute influxql error:", q)
+			result = &statementResult{Error: err.Error()}
+		}
+		result.StatementID = i
+		resp.Results[i] = result
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+var epochUnits = map[string]time.Duration{
+	"h":  time.Hour,
+	"m":  time.Minute,
+	"s":  time.Second,
+	"ms": time.Millisecond,
+	"u":  time.Microsecond,
+	"µ":  time.Microsecond,
+	"ns": time.Nanosecond,
+}
+
+type executor struct {
+	conn  unsafe.Pointer
+	db    string
+	epoch string
+	now   time.Time
+}
+
+func (e *executor) formatTime(t time.Time) interface{} {
+	if len(e.epoch) == 0 {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return t.UnixNano() / int64(epochUnits[e.epoch])
+}
+
+func rawTime(ts int64, precision int) driver.Value {
+	return common.TimestampConvertToTime(ts, precision)
+}
+
+func (e *executor) exec(sql string) ([][]driver.Value, error) {
+	result, err := async.GlobalAsync.TaosExec(e.conn, sql, rawTime)
+	if err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+func (e *executor) database(db string) (string, error) {
+	if len(db) == 0 {
+		db = e.db
+	}
+	if len(db) == 0 {
+		return "", errors.New("database name required")
+	}
+	if !identifierRegexp.MatchString(db) {
+		return "", fmt.Errorf("invalid database name %s", db)
+	}
+	return db, nil
+}
+
+func (e *executor) execute(stmt influxql.Statement) (*statementResult, error) {
+	switch stmt := stmt.(type) {
+	case *influxql.ShowDatabasesStatement:
+		rows, err := e.exec("show databases")
+		if err != nil {
+			return nil, err
+		}
+		return &statementResult{Series: []*series{{Name: "databases", Columns: []string{"name"}, Values: firstColumn(rows)}}}, nil
+	case *influxql.ShowMeasurementsStatement:
+		db, err := e.database(stmt.Database)
+		if err != nil {
+			return nil, err
+		}
+		measurements, err := e.measurements(db)
+		if err != nil || len(measurements) == 0 {
+			return &statementResult{}, err
+		}
+		values := make([][]interface{}, len(measurements))
+		for i, m := range measurements {
+			values[i] = []interface{}{m}
+		}
+		return &statementResult{Series: []*series{{Name: "measurements", Columns: []string{"name"}, Values: values}}}, nil
+	case *influxql.ShowTagKeysStatement:
+		return e.showKeys(stmt.Database, stmt.Measurement, []string{"tagKey"}, func(schema *influxql.Schema, _ []string) [][]interface{} {
+			values := make([][]interface{}, len(schema.Tags))
+			for i, tag := range schema.Tags {
+				values[i] = []interface{}{tag}
+			}
+			return values
+		})
+	case *influxql.ShowFieldKeysStatement:
+		return e.showKeys(stmt.Database, stmt.Measurement, []string{"fieldKey", "fieldType"}, func(schema *influxql.Schema, fieldTypes []string) [][]interface{} {
+			values := make([][]interface{}, len(schema.Fields))
+			for i, field := range schema.Fields {
+				values[i] = []interface{}{field, fieldTypes[i]}
+			}
+			return values
+		})
+	case *influxql.ShowTagValuesStatement:
+		return e.showTagValues(stmt)
+	case *influxql.SelectStatement:
+		return e.selectStatement(stmt)
+	}
+	return nil, errors.New("unsupported statement")
+}
+
+func firstColumn(rows [][]driver.Value) [][]interface{} {
+	values := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		values[i] = []interface{}{row[0]}
+	}
+	return values
+}
+
+func (e *executor) measurements(db string) ([]string, error) {
+	rows, err := e.exec(fmt.Sprintf("show %s.stables", db))
+	if err != nil {
+		return nil, err
+	}
+	measurements := make([]string, len(rows))
+	for i, row := range rows {
+		measurements[i], _ = row[0].(string)
+	}
+	return measurements, nil
+}
+
+// describe returns the schema of a super table and the influxdb type of each field, the schema is nil when
+// the super table does not exist.
+func (e *executor) describe(db, measurement string) (*influxql.Schema, []string, error) {
+	if !identifierRegexp.MatchString(measurement) {
+		return nil, nil, nil
+	}
+	rows, err := e.exec(fmt.Sprintf("describe %s.%s", db, measurement))
+	if err != nil {
+		tError, ok := err.(*tErrors.TaosError)
+		if ok && tError.Code&0xffff == httperror.TSDB_CODE_MND_INVALID_TABLE {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil, nil
+	}
+	schema := &influxql.Schema{}
+	schema.TimeColumn, _ = rows[0][0].(string)
+	var fieldTypes []string
+	for _, row := range rows[1:] {
+		name, _ := row[0].(string)
+		typ, _ := row[1].(string)
+		note, _ := row[3].(string)
+		if note == "TAG" {
+			schema.Tags = append(schema.Tags, name)
+		} else {
+			schema.Fields = append(schema.Fields, name)
+			fieldTypes = append(fieldTypes, fieldType(typ))
+		}
+	}
+	return schema, fieldTypes, nil
+}
+
+func fieldType(typ string) string {
+	switch strings.ToUpper(typ) {
+	case "FLOAT", "DOUBLE":
+		return "float"
+	case "BOOL":
+		return "boolean"
+	case "BINARY", "NCHAR":
+		return "string"
+	}
+	return "integer"
+}
+
+// showKeys returns one series per measurement, all measurements of db when measurement is empty.
+func (e *executor) showKeys(db, measurement string, columns []string, values func(*influxql.Schema, []string) [][]interface{}) (*statementResult, error) {
+	db, err := e.database(db)
+	if err != nil {
+		return nil, err
+	}
+	measurements := []string{measurement}
+	if len(measurement) == 0 {
+		measurements, err = e.measurements(db)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := &statementResult{}
+	for _, m := range measurements {
+		schema, fieldTypes, err := e.describe(db, m)
+		if err != nil {
+			return nil, err
+		}
+		if schema == nil {
+			continue
+		}
+		v := values(schema, fieldTypes)
+		if len(v) == 0 {
+			continue
+		}
+		result.Series = append(result.Series, &series{Name: m, Columns: columns, Values: v})
+	}
+	return result, nil
+}
+
+func (e *executor) showTagValues(stmt *influxql.ShowTagValuesStatement) (*statementResult, error) {
+	db, err := e.database(stmt.Database)
+	if err != nil {
+		return nil, err
+	}
+	measurements := []string{stmt.Measurement}
+	if len(stmt.Measurement) == 0 {
+		measurements, err = e.measurements(db)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := &statementResult{}
+	for _, m := range measurements {
+		schema, _, err := e.describe(db, m)
+		if err != nil {
+			return nil, err
+		}
+		if schema == nil || !contains(schema.Tags, stmt.Key) {
+			continue
+		}
+		rows, err := e.exec(fmt.Sprintf("select distinct %s from %s.%s", stmt.Key, db, m))
+		if err != nil {
+			return nil, err
+		}
+		s := &series{Name: m, Columns: []string{"key", "value"}}
+		for _, row := range rows {
+			if row[0] != nil {
+				s.Values = append(s.Values, []interface{}{stmt.Key, row[0]})
+			}
+		}
+		if len(s.Values) != 0 {
+			result.Series = append(result.Series, s)
+		}
+	}
+	return result, nil
+}
+
+func contains(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *executor) selectStatement(stmt *influxql.SelectStatement) (*statementResult, error) {
+	db, err := e.database(stmt.Database)
+	if err != nil {
+		return nil, err
+	}
+	schema, _, err := e.describe(db, stmt.Measurement)
+	if err != nil || schema == nil {
+		return &statementResult{}, err
+	}
+	q, err := influxql.Translate(stmt, db, schema, e.now)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := e.exec(q.SQL)
+	if err != nil {
+		return nil, err
+	}
+	return &statementResult{Series: e.series(stmt.Measurement, q, rows)}, nil
+}
+
+// series splits rows into one series per group by tag values.
+func (e *executor) series(name string, q *influxql.Query, rows [][]driver.Value) []*series {
+	var result []*series
+	index := map[string]*series{}
+	valueCount := len(q.Columns) - 1
+	var key strings.Builder
+	for _, row := range rows {
+		values := make([]interface{}, 0, len(q.Columns))
+		offset := 0
+		if q.HasTime {
+			ts, _ := row[0].(time.Time)
+			values = append(values, e.formatTime(ts))
+			offset = 1
+		} else {
+			values = append(values, e.formatTime(q.StartTime))
+		}
+		for _, v := range row[offset : offset+valueCount] {
+			values = append(values, v)
+		}
+		tagValues := row[offset+valueCount:]
+		key.Reset()
+		for _, v := range tagValues {
+			if v != nil {
+				key.WriteString(fmt.Sprint(v))
+			}
+			key.WriteByte(0)
+		}
+		s, exist := index[key.String()]
+		if !exist {
+			s = &series{Name: name, Columns: q.Columns}
+			if len(q.GroupByTags) != 0 {
+				s.Tags = make(map[string]string, len(q.GroupByTags))
+				for i, tag := range q.GroupByTags {
+					if i < len(tagValues) && tagValues[i] != nil {
+						s.Tags[tag] = fmt.Sprint(tagValues[i])
+					} else {
+						s.Tags[tag] = ""
+					}
+				}
+			}
+			index[key.String()] = s
+			result = append(result, s)
+		}
+		s.Values = append(s.Values, values)
+	}
+	return result
+}
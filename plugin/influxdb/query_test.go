@@ -0,0 +1,85 @@
+package influxdb
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/blm3/plugin/influxdb/influxql"
+	"github.com/taosdata/blm3/tools/login"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
+)
+
+func TestQueryBadRequest(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	defer func(conf *config.Config, verifyUser func(user, password string) error) {
+		config.Conf = conf
+		login.VerifyUser = verifyUser
+	}(config.Conf, login.VerifyUser)
+	config.Conf = &config.Config{}
+	login.VerifyUser = func(user, password string) error {
+		if password != "taosdata" {
+			return &tErrors.TaosError{Code: tErrors.RPC_AUTH_FAILURE, ErrStr: "Authentication failure"}
+		}
+		return nil
+	}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("currentID", uint32(0))
+	})
+	p := &Influxdb{}
+	assert.NoError(t, p.Init(router.Group("influxdb/v1")))
+	tests := []struct {
+		name string
+		url  string
+		auth bool
+		code int
+	}{
+		{name: "no auth", url: "/influxdb/v1/query?q=show%20databases", code: http.StatusUnauthorized},
+		{name: "no q", url: "/influxdb/v1/query", auth: true, code: http.StatusBadRequest},
+		{name: "bad epoch", url: "/influxdb/v1/query?q=show%20databases&epoch=d", auth: true, code: http.StatusBadRequest},
+		{name: "bad query", url: "/influxdb/v1/query?q=drop%20database%20test", auth: true, code: http.StatusBadRequest},
+		{name: "refused", url: "/influxdb/v1/query?q=show%20databases&u=root&p=wrong", code: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.auth {
+				req.SetBasicAuth("root", "taosdata")
+			}
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+}
+
+func TestSeries(t *testing.T) {
+	ts := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	q := &influxql.Query{Columns: []string{"time", "mean"}, HasTime: true, GroupByTags: []string{"host"}}
+	rows := [][]driver.Value{
+		{ts, 1.5, "a"},
+		{ts, 2.5, nil},
+		{ts.Add(time.Minute), 3.5, "a"},
+	}
+	e := &executor{}
+	got := e.series("cpu", q, rows)
+	assert.Equal(t, []*series{
+		{Name: "cpu", Tags: map[string]string{"host": "a"}, Columns: q.Columns, Values: [][]interface{}{
+			{"2021-10-01T00:00:00Z", 1.5},
+			{"2021-10-01T00:01:00Z", 3.5},
+		}},
+		{Name: "cpu", Tags: map[string]string{"host": ""}, Columns: q.Columns, Values: [][]interface{}{
+			{"2021-10-01T00:00:00Z", 2.5},
+		}},
+	}, got)
+	e.epoch = "ms"
+	q = &influxql.Query{Columns: []string{"time", "count"}, StartTime: ts}
+	got = e.series("cpu", q, [][]driver.Value{{int64(3)}})
+	assert.Equal(t, [][]interface{}{{int64(1633046400000), int64(3)}}, got[0].Values)
+}
@@ -0,0 +1,190 @@
+package influxdb
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/blm3/plugin"
+	"github.com/taosdata/blm3/tools"
+	"github.com/taosdata/blm3/tools/certauth"
+	"github.com/taosdata/blm3/tools/limit"
+	"github.com/taosdata/blm3/tools/web"
+)
+
+// v2Precisions maps influxdb v2 precisions to schemaless precisions.
+var v2Precisions = map[string]string{
+	"s":  "s",
+	"ms": "ms",
+	"us": "u",
+	"ns": "ns",
+}
+
+// InfluxdbV2 serves the influxdb v2 write api, clients use /influxdb/v2 as the server url.
+type InfluxdbV2 struct {
+	plugin.State
+	// lock guards conf which a reload replaces while requests read the buckets and tokens
+	lock   sync.RWMutex
+	conf   Config
+	routed bool
+}
+
+func (p *InfluxdbV2) String() string {
+	return "influxdb"
+}
+
+func (p *InfluxdbV2) Version() string {
+	return "v2"
+}
+
+func (p *InfluxdbV2) Init(r gin.IRouter) error {
+	p.conf.setValue()
+	if !p.conf.Enable {
+		logger.Info("influxdb v2 disabled")
+		return nil
+	}
+	p.SetEnabled(true)
+	r.POST("api/v2/write", limit.IPHandler(limitResponse), p.getAuth, limit.UserHandler(limitResponse), p.write)
+	p.routed = true
+	return nil
+}
+
+// Reload reads the configuration, buckets and tokens take effect when the plugin starts again.
+func (p *InfluxdbV2) Reload() ([]string, func() error) {
+	var conf Config
+	conf.setValue()
+	return config.Diff("influxdb", p.conf, conf), func() error {
+		if conf.Enable && !p.routed {
+			return plugin.ErrRestartRequired
+		}
+		p.lock.Lock()
+		p.conf = conf
+		p.lock.Unlock()
+		p.SetEnabled(conf.Enable)
+		return nil
+	}
+}
+
+func (p *InfluxdbV2) getConf() Config {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.conf
+}
+
+func (p *InfluxdbV2) Start() error {
+	if !p.getConf().Enable {
+		return nil
+	}
+	p.SetListening(true)
+	return nil
+}
+
+func (p *InfluxdbV2) Stop() error {
+	p.SetListening(false)
+	return nil
+}
+
+func (p *InfluxdbV2) write(c *gin.Context) {
+	id := web.GetRequestID(c)
+	logger := logger.WithField("sessionID", id)
+	user, password, err := plugin.GetAuth(c)
+	if err != nil {
+		commonResponse(c, http.StatusUnauthorized, &message{Code: "unauthorized", Message: err.Error()})
+		return
+	}
+	if len(c.Query("org")) == 0 && len(c.Query("orgID")) == 0 {
+		commonResponse(c, http.StatusBadRequest, &message{Code: "invalid", Message: "org or orgID required"})
+		return
+	}
+	bucket := c.Query("bucket")
+	if len(bucket) == 0 {
+		commonResponse(c, http.StatusBadRequest, &message{Code: "invalid", Message: "bucket required"})
+		return
+	}
+	precision := "ns"
+	if p := c.Query("precision"); len(p) != 0 {
+		var ok bool
+		precision, ok = v2Precisions[p]
+		if !ok {
+			commonResponse(c, http.StatusBadRequest, &message{Code: "invalid", Message: "invalid precision " + p})
+			return
+		}
+	}
+	data, err := c.GetRawData()
+	if err != nil {
+		logger.WithError(err).Errorln("read line error")
+		commonResponse(c, http.StatusBadRequest, &message{Code: "invalid", Message: err.Error()})
+		return
+	}
+	conf := p.getConf()
+	insertLines(c, logger, "influxdb_v2", &p.State, user, password, conf.database(bucket), precision, data)
+}
+
+// database returns the database of bucket, an unmapped bucket in the influxdb 1.8 form database/retention
+// policy is written to database.
+func (conf *Config) database(bucket string) string {
+	if db, ok := conf.Buckets[bucket]; ok {
+		return db
+	}
+	if i := strings.IndexByte(bucket, '/'); i > 0 {
+		return bucket[:i]
+	}
+	return bucket
+}
+
+// getAuth accepts Authorization: Token with a configured token or user:password like influxdb 1.8, Basic auth, and
+// a client certificate mapped by ssl.clientUsers when there is no Authorization header.
+func (p *InfluxdbV2) getAuth(c *gin.Context) {
+	auth := strings.TrimSpace(c.GetHeader("Authorization"))
+	switch {
+	case strings.HasPrefix(auth, "Token "):
+		token := strings.TrimSpace(auth[6:])
+		conf := p.getConf()
+		// the users of the configured tokens are trusted
+		if u, ok := conf.Tokens[token]; ok {
+			c.Set(plugin.UserKey, u.User)
+			c.Set(plugin.PasswordKey, u.Password)
+			return
+		}
+		sl := strings.SplitN(token, ":", 2)
+		if len(sl) != 2 {
+			commonResponse(c, http.StatusUnauthorized, &message{Code: "unauthorized", Message: "unauthorized access"})
+			c.Abort()
+			return
+		}
+		if !check(c, "token auth", sl[0], sl[1]) {
+			return
+		}
+		c.Set(plugin.UserKey, sl[0])
+		c.Set(plugin.PasswordKey, sl[1])
+	case strings.HasPrefix(auth, "Basic "):
+		user, password, err := tools.DecodeBasic(auth[6:])
+		if err != nil {
+			commonResponse(c, http.StatusUnauthorized, &message{Code: "unauthorized", Message: err.Error()})
+			c.Abort()
+			return
+		}
+		if !check(c, "basic auth", user, password) {
+			return
+		}
+		c.Set(plugin.UserKey, user)
+		c.Set(plugin.PasswordKey, password)
+	case len(auth) == 0:
+		if user, password, ok := certauth.User(c.Request); ok {
+			c.Set(plugin.UserKey, user)
+			c.Set(plugin.PasswordKey, password)
+			return
+		}
+		commonResponse(c, http.StatusUnauthorized, &message{Code: "unauthorized", Message: "auth needed"})
+		c.Abort()
+	default:
+		commonResponse(c, http.StatusUnauthorized, &message{Code: "unauthorized", Message: "unknown auth type"})
+		c.Abort()
+	}
+}
+
+func init() {
+	plugin.Register(&InfluxdbV2{})
+}
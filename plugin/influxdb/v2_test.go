@@ -0,0 +1,125 @@
+package influxdb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/blm3/plugin"
+	"github.com/taosdata/blm3/tools/login"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
+)
+
+func TestV2Auth(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	defer func(conf *config.Config, verifyUser func(user, password string) error) {
+		config.Conf = conf
+		login.VerifyUser = verifyUser
+	}(config.Conf, login.VerifyUser)
+	config.Conf = &config.Config{}
+	login.VerifyUser = func(user, password string) error {
+		if password != "taosdata" {
+			return &tErrors.TaosError{Code: tErrors.RPC_AUTH_FAILURE, ErrStr: "Authentication failure"}
+		}
+		return nil
+	}
+	viper.Set("influxdb.tokens", []string{"secret-token:reader:pass:word"})
+	defer viper.Set("influxdb.tokens", []string{})
+	p := &InfluxdbV2{}
+	router := gin.New()
+	assert.NoError(t, p.Init(router))
+	router.GET("auth", p.getAuth, func(c *gin.Context) {
+		user, password, err := plugin.GetAuth(c)
+		assert.NoError(t, err)
+		c.String(http.StatusOK, user+" "+password)
+	})
+	tests := []struct {
+		name string
+		auth string
+		code int
+		body string
+	}{
+		{name: "mapped token", auth: "Token secret-token", code: http.StatusOK, body: "reader pass:word"},
+		{name: "user password token", auth: "Token root:taosdata", code: http.StatusOK, body: "root taosdata"},
+		{name: "basic", auth: "Basic cm9vdDp0YW9zZGF0YQ==", code: http.StatusOK, body: "root taosdata"},
+		{name: "refused token", auth: "Token root:wrong", code: http.StatusUnauthorized},
+		{name: "refused basic", auth: "Basic cm9vdDp3cm9uZw==", code: http.StatusUnauthorized},
+		{name: "unknown token", auth: "Token unknown", code: http.StatusUnauthorized},
+		{name: "no auth", code: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/auth", nil)
+			if len(tt.auth) != 0 {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == http.StatusOK {
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestV2ReloadWhileServing(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	viper.Set("influxdb.tokens", []string{"secret-token:reader:password"})
+	defer viper.Set("influxdb.tokens", []string{})
+	p := &InfluxdbV2{}
+	router := gin.New()
+	assert.NoError(t, p.Init(router))
+	router.GET("auth", p.getAuth, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_, apply := p.Reload()
+			assert.NoError(t, apply())
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/auth", nil)
+		req.Header.Set("Authorization", "Token secret-token")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	<-done
+}
+
+func TestV2WriteBadRequest(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("currentID", uint32(0))
+	})
+	p := &InfluxdbV2{}
+	assert.NoError(t, p.Init(router.Group("influxdb/v2")))
+	for _, url := range []string{
+		"/influxdb/v2/api/v2/write?bucket=test",
+		"/influxdb/v2/api/v2/write?org=o",
+		"/influxdb/v2/api/v2/write?org=o&bucket=test&precision=n",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader("cpu value=1"))
+		req.Header.Set("Authorization", "Token root:taosdata")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+	}
+}
+
+func TestV2Database(t *testing.T) {
+	conf := &Config{Buckets: map[string]string{"metrics": "telegraf"}}
+	assert.Equal(t, "telegraf", conf.database("metrics"))
+	assert.Equal(t, "test", conf.database("test/autogen"))
+	assert.Equal(t, "other", conf.database("other"))
+}
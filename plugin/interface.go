@@ -1,9 +1,15 @@
 package plugin
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sort"
+	"sync"
 
 	"github.com/gin-gonic/gin"
+	"github.com/taosdata/blm3/config"
 	"github.com/taosdata/blm3/log"
 )
 
@@ -17,41 +23,246 @@ type Plugin interface {
 	Version() string
 }
 
-var plugins = map[string]Plugin{}
+// Lifecycle states of a plugin.
+const (
+	StateStopped  = "stopped"
+	StateRunning  = "running"
+	StateFailed   = "failed"
+	StateDisabled = "disabled"
+)
+
+var (
+	ErrNotFound   = errors.New("plugin not found")
+	ErrDisabled   = errors.New("plugin disabled")
+	ErrInitFailed = errors.New("plugin init failed")
+)
+
+// entry holds a registered plugin and its lifecycle state, the lock serializes starting and stopping.
+type entry struct {
+	plugin      Plugin
+	lock        sync.RWMutex
+	state       string
+	err         error
+	initialized bool
+}
+
+var plugins = map[string]*entry{}
 
 func Register(plugin Plugin) {
 	name := fmt.Sprintf("%s/%s", plugin.String(), plugin.Version())
 	if _, ok := plugins[name]; ok {
 		logger.Panicf("duplicate registration of plugin %s", name)
 	}
-	plugins[name] = plugin
+	plugins[name] = &entry{plugin: plugin, state: StateStopped}
 }
 
+// Init initializes the plugins, the routes of a plugin respond 503 unless it is running. When plugin.failFast is
+// disabled a plugin which fails is marked failed instead of panicking.
 func Init(r gin.IRouter) {
-	for name, plugin := range plugins {
+	for name, e := range plugins {
 		logger.Infof("init plugin %s", name)
 		router := r.Group(name)
-		err := plugin.Init(router)
+		router.Use(e.guard(name))
+		err := e.plugin.Init(router)
+		e.lock.Lock()
 		if err != nil {
-			logger.WithError(err).Panicf("init plugin %s", name)
+			e.fail(name, "init", err)
+		} else {
+			e.initialized = true
+			if !e.enabled() {
+				e.state = StateDisabled
+			}
 		}
+		e.lock.Unlock()
 	}
 }
 
 func Start() {
-	for name, plugin := range plugins {
-		err := plugin.Start()
-		if err != nil {
-			logger.WithError(err).Panicf("start plugin %s", name)
+	for name, e := range plugins {
+		e.lock.Lock()
+		if e.initialized && e.state == StateStopped {
+			err := e.plugin.Start()
+			if err != nil {
+				e.fail(name, "start", err)
+			} else {
+				e.state = StateRunning
+			}
 		}
+		e.lock.Unlock()
 	}
 }
 
 func Stop() {
-	for name, plugin := range plugins {
-		err := plugin.Stop()
-		if err != nil {
-			logger.WithError(err).Warnf("stop plugin %s", name)
+	Shutdown(context.Background())
+}
+
+// Shutdown stops the plugins concurrently, a plugin drains its queues into taosd before its Stop returns. It returns
+// the plugins which are still stopping when ctx is done.
+func Shutdown(ctx context.Context) []string {
+	var lock sync.Mutex
+	stopping := map[string]struct{}{}
+	for name := range plugins {
+		stopping[name] = struct{}{}
+	}
+	var wg sync.WaitGroup
+	for name, e := range plugins {
+		wg.Add(1)
+		go func(name string, e *entry) {
+			defer wg.Done()
+			e.lock.Lock()
+			if e.state == StateRunning || (e.state == StateFailed && e.initialized) {
+				logger.Infof("stop plugin %s", name)
+				err := e.plugin.Stop()
+				if err != nil {
+					logger.WithError(err).Warnf("stop plugin %s", name)
+				}
+				e.state = StateStopped
+			}
+			e.lock.Unlock()
+			lock.Lock()
+			delete(stopping, name)
+			lock.Unlock()
+		}(name, e)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	var names []string
+	for name := range stopping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fail marks the plugin failed, or panics when plugin.failFast is enabled.
+func (e *entry) fail(name, action string, err error) {
+	if config.Conf == nil || config.Conf.Plugin.FailFast {
+		logger.WithError(err).Panicf("%s plugin %s", action, name)
+	}
+	logger.WithError(err).Errorf("%s plugin %s failed", action, name)
+	e.state = StateFailed
+	e.err = err
+}
+
+func (e *entry) enabled() bool {
+	if reporter, ok := e.plugin.(StatusReporter); ok {
+		return reporter.Status().Enabled
+	}
+	return true
+}
+
+// guard rejects requests to a plugin which is not running.
+func (e *entry) guard(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		e.lock.RLock()
+		state := e.state
+		e.lock.RUnlock()
+		if state != StateRunning {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"code":    "unavailable",
+				"message": fmt.Sprintf("plugin %s is %s", name, state),
+			})
 		}
 	}
 }
+
+// StartPlugin starts a stopped or failed plugin, starting a running plugin does nothing.
+func StartPlugin(name string) error {
+	e, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.start(name)
+}
+
+// StopPlugin stops a running plugin, a failed plugin is marked stopped.
+func StopPlugin(name string) error {
+	e, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.stop(name)
+}
+
+// RestartPlugin stops and starts a plugin.
+func RestartPlugin(name string) error {
+	e, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	err = e.stop(name)
+	if err != nil {
+		return err
+	}
+	return e.start(name)
+}
+
+func lookup(name string) (*entry, error) {
+	e, exist := plugins[name]
+	if !exist {
+		return nil, ErrNotFound
+	}
+	return e, nil
+}
+
+func (e *entry) start(name string) error {
+	switch {
+	case !e.initialized:
+		return ErrInitFailed
+	case e.state == StateDisabled:
+		return ErrDisabled
+	case e.state == StateRunning:
+		return nil
+	case e.state == StateFailed:
+		// release what the failed start has opened
+		_ = e.plugin.Stop()
+	}
+	logger.Infof("start plugin %s", name)
+	err := e.plugin.Start()
+	if err != nil {
+		logger.WithError(err).Errorf("start plugin %s failed", name)
+		e.state = StateFailed
+		e.err = err
+		return err
+	}
+	e.state = StateRunning
+	e.err = nil
+	return nil
+}
+
+func (e *entry) stop(name string) error {
+	switch e.state {
+	case StateDisabled:
+		return ErrDisabled
+	case StateStopped:
+		return nil
+	case StateFailed:
+		if !e.initialized {
+			return ErrInitFailed
+		}
+		e.state = StateStopped
+		return e.plugin.Stop()
+	}
+	logger.Infof("stop plugin %s", name)
+	err := e.plugin.Stop()
+	e.state = StateStopped
+	if err != nil {
+		logger.WithError(err).Warnf("stop plugin %s", name)
+	}
+	return err
+}
@@ -0,0 +1,185 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/blm3/tools/login"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
+)
+
+type fakePlugin struct {
+	State
+	name     string
+	enable   bool
+	startErr error
+	starts   int
+	stops    int
+}
+
+func (f *fakePlugin) Init(r gin.IRouter) error {
+	if f.enable {
+		f.SetEnabled(true)
+	}
+	r.GET("ping", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return nil
+}
+
+func (f *fakePlugin) Start() error {
+	f.starts += 1
+	if f.startErr != nil {
+		return f.startErr
+	}
+	f.SetListening(true)
+	return nil
+}
+
+func (f *fakePlugin) Stop() error {
+	f.stops += 1
+	f.SetListening(false)
+	return nil
+}
+
+func (f *fakePlugin) String() string {
+	return f.name
+}
+
+func (f *fakePlugin) Version() string {
+	return "v1"
+}
+
+func request(router *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, nil)
+	req.SetBasicAuth("root", "taosdata")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestLifecycle(t *testing.T) {
+	defer func(p map[string]*entry, conf *config.Config, verifyUser func(user, password string) error) {
+		plugins = p
+		config.Conf = conf
+		login.VerifyUser = verifyUser
+	}(plugins, config.Conf, login.VerifyUser)
+	plugins = map[string]*entry{}
+	config.Conf = &config.Config{
+		Plugin: config.Plugin{FailFast: false, AdminUser: "root"},
+		Login:  config.Login{MaxFailures: 2, Lockout: time.Minute, LockUser: true},
+	}
+	login.VerifyUser = func(user, password string) error {
+		if password != "taosdata" {
+			return &tErrors.TaosError{Code: tErrors.RPC_AUTH_FAILURE, ErrStr: "Authentication failure"}
+		}
+		return nil
+	}
+	good := &fakePlugin{name: "good", enable: true}
+	bad := &fakePlugin{name: "bad", enable: true, startErr: errors.New("address already in use")}
+	disabled := &fakePlugin{name: "disabled"}
+	Register(good)
+	Register(bad)
+	Register(disabled)
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	Init(router)
+	RegisterAdmin(router)
+	Start()
+
+	statuses := Statuses()
+	assert.Equal(t, StateRunning, statuses["good/v1"].State)
+	assert.Equal(t, StateFailed, statuses["bad/v1"].State)
+	assert.Equal(t, "address already in use", statuses["bad/v1"].Error)
+	assert.Equal(t, StateDisabled, statuses["disabled/v1"].State)
+	assert.Equal(t, http.StatusNoContent, request(router, http.MethodGet, "/good/v1/ping").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, request(router, http.MethodGet, "/bad/v1/ping").Code)
+
+	w := request(router, http.MethodGet, "/-/plugins")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list map[string]Status
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list, 3)
+
+	assert.Equal(t, http.StatusOK, request(router, http.MethodPost, "/-/plugins/good/v1/stop").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, request(router, http.MethodGet, "/good/v1/ping").Code)
+	assert.Equal(t, http.StatusOK, request(router, http.MethodPost, "/-/plugins/good/v1/start").Code)
+	assert.Equal(t, http.StatusNoContent, request(router, http.MethodGet, "/good/v1/ping").Code)
+	assert.Equal(t, http.StatusOK, request(router, http.MethodPost, "/-/plugins/good/v1/restart").Code)
+	assert.Equal(t, 3, good.starts)
+	assert.Equal(t, 2, good.stops)
+
+	assert.Equal(t, http.StatusInternalServerError, request(router, http.MethodPost, "/-/plugins/bad/v1/restart").Code)
+	bad.startErr = nil
+	w = request(router, http.MethodPost, "/-/plugins/bad/v1/start")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var status Status
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, StateRunning, status.State)
+	assert.Empty(t, status.Error)
+
+	assert.Equal(t, http.StatusConflict, request(router, http.MethodPost, "/-/plugins/disabled/v1/start").Code)
+	assert.Equal(t, http.StatusNotFound, request(router, http.MethodPost, "/-/plugins/unknown/v1/start").Code)
+	assert.Equal(t, http.StatusNotFound, request(router, http.MethodPost, "/-/plugins/good/v1/pause").Code)
+
+	config.Conf.Plugin.AdminUser = "admin"
+	assert.Equal(t, http.StatusForbidden, request(router, http.MethodGet, "/-/plugins").Code)
+	config.Conf.Plugin.AdminUser = "root"
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/-/plugins", nil)
+	req.SetBasicAuth("root", "wrong")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	// the failed logins on the admin api lock out the user like those on /rest/login
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, http.StatusTooManyRequests, request(router, http.MethodGet, "/-/plugins").Code)
+
+	Stop()
+	assert.Equal(t, StateStopped, Statuses()["good/v1"].State)
+}
+
+type slowPlugin struct {
+	fakePlugin
+	release chan struct{}
+}
+
+func (f *slowPlugin) Stop() error {
+	<-f.release
+	return f.fakePlugin.Stop()
+}
+
+func TestShutdown(t *testing.T) {
+	defer func(p map[string]*entry, conf *config.Config) {
+		plugins = p
+		config.Conf = conf
+	}(plugins, config.Conf)
+	plugins = map[string]*entry{}
+	config.Conf = &config.Config{Plugin: config.Plugin{FailFast: false}}
+	fast := &fakePlugin{name: "fast", enable: true}
+	slow := &slowPlugin{fakePlugin: fakePlugin{name: "slow", enable: true}, release: make(chan struct{})}
+	Register(fast)
+	Register(slow)
+	gin.SetMode(gin.ReleaseMode)
+	Init(gin.New())
+	Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, []string{"slow/v1"}, Shutdown(ctx))
+	assert.Equal(t, 1, fast.stops)
+	close(slow.release)
+	assert.Empty(t, Shutdown(context.Background()))
+	assert.Equal(t, 1, slow.stops)
+	assert.Equal(t, StateStopped, Statuses()["slow/v1"].State)
+}
@@ -5,32 +5,37 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
-	"unsafe"
 
 	"github.com/gin-gonic/gin"
 	tmetric "github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/plugins/inputs/prometheus"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/taosdata/blm3/config"
 	"github.com/taosdata/blm3/db/commonpool"
+	"github.com/taosdata/blm3/db/writebuffer"
 	"github.com/taosdata/blm3/log"
 	"github.com/taosdata/blm3/plugin"
 	"github.com/taosdata/blm3/schemaless/capi"
+	"github.com/taosdata/blm3/tools/monitor"
 )
 
 var logger = log.GetLogger("NodeExporter")
 
 type NodeExporter struct {
+	plugin.State
 	conf     Config
 	request  []*Req
 	exitChan chan struct{}
+	wg       sync.WaitGroup
+	writer   *writebuffer.Writer
 }
 type Req struct {
 	req    *http.Request
@@ -44,40 +49,68 @@ func (p *NodeExporter) Init(_ gin.IRouter) error {
 		logger.Info("node_exporter disabled")
 		return nil
 	}
-	err := p.prepareUrls()
-	if err != nil {
-		return err
+	p.SetEnabled(true)
+	return p.prepareUrls()
+}
+
+// Reload reads the configuration, the requests are prepared again for the new urls and credentials.
+func (p *NodeExporter) Reload() ([]string, func() error) {
+	var conf Config
+	conf.setValue()
+	return config.Diff("node_exporter", p.conf, conf), func() error {
+		p.conf = conf
+		p.SetEnabled(conf.Enable)
+		p.request = nil
+		if !conf.Enable {
+			return nil
+		}
+		return p.prepareUrls()
 	}
-	return nil
 }
 
 func (p *NodeExporter) Start() error {
 	if !p.conf.Enable {
 		return nil
 	}
+	var err error
+	p.writer, err = writebuffer.NewWriter(&config.Conf.WriteBuffer, "node_exporter", p.conf.DB, p.insert)
+	if err != nil {
+		return err
+	}
 	p.exitChan = make(chan struct{})
-	ticker := time.NewTicker(p.conf.GatherDuration)
-	go func() {
+	p.SetListening(true)
+	p.wg.Add(1)
+	go func(exitChan chan struct{}) {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.conf.GatherDuration)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
 				p.Gather()
-			case <-p.exitChan:
-				ticker.Stop()
-				ticker = nil
+			case <-exitChan:
 				return
 			}
 		}
-	}()
+	}(p.exitChan)
 	return nil
 }
 
+// Stop releases what Start has opened, it is also called after a failed Start.
 func (p *NodeExporter) Stop() error {
 	if !p.conf.Enable {
 		return nil
 	}
 	if p.exitChan != nil {
 		close(p.exitChan)
+		p.exitChan = nil
+	}
+	p.wg.Wait()
+	p.SetListening(false)
+	if p.writer != nil {
+		err := p.writer.Close()
+		p.writer = nil
+		return err
 	}
 	return nil
 }
@@ -189,54 +222,84 @@ func (p *NodeExporter) prepareUrls() error {
 }
 
 func (p *NodeExporter) Gather() {
-	conn, err := commonpool.GetConnection(p.conf.User, p.conf.Password)
-	if err != nil {
-		logger.WithError(err).Errorln("commonpool.GetConnection error")
-		return
-	}
-	defer conn.Put()
 	for _, req := range p.request {
-		err := p.requestSingle(conn.TaosConnection, req)
+		data, err := p.requestSingle(req)
 		if err != nil {
+			p.WriteFailed(err)
 			logger.WithError(err).Errorln("gather")
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+		lines := monitor.CountLines(data)
+		monitor.AddPoints(p.String(), monitor.PointsReceived, lines)
+		err = p.writer.Write(data)
+		if err != nil {
+			monitor.AddPoints(p.String(), monitor.PointsDropped, lines)
+			p.WriteFailed(err)
+			logger.WithError(err).Errorln("insert lines error")
 		}
 	}
 }
 
-func (p *NodeExporter) requestSingle(conn unsafe.Pointer, req *Req) error {
-	resp, err := req.client.Do(req.req)
+func (p *NodeExporter) insert(data []byte) error {
+	conn, err := commonpool.GetConnection(p.conf.User, p.conf.Password)
 	if err != nil {
+		p.WriteFailed(err)
 		return err
 	}
+	defer conn.Put()
+	result, err := capi.InsertInfluxdb(conn.TaosConnection, data, p.conf.DB, "ns")
+	if err != nil {
+		p.WriteFailed(err)
+		return err
+	}
+	monitor.AddPoints(p.String(), monitor.PointsInserted, result.SuccessCount)
+	monitor.AddPoints(p.String(), monitor.PointsFailed, result.FailCount)
+	if result.SuccessCount != 0 {
+		p.WriteSucceeded()
+	}
+	if result.FailCount != 0 {
+		p.WriteFailed(result.Errors[0])
+		logger.Errorln("insert lines error", strings.Join(result.ErrorList, ","))
+	}
+	return nil
+}
+
+// requestSingle returns the metrics of one url as influxdb lines.
+func (p *NodeExporter) requestSingle(req *Req) ([]byte, error) {
+	resp, err := req.client.Do(req.req)
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("%s returned HTTP status %s", req.req.URL, resp.Status)
+		return nil, fmt.Errorf("%s returned HTTP status %s", req.req.URL, resp.Status)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("error reading body: %s", err)
+		return nil, fmt.Errorf("error reading body: %s", err)
 	}
 	metrics, err := prometheus.Parse(body, resp.Header)
+	if err != nil {
+		return nil, err
+	}
 	serializer := influx.NewSerializer()
+	var data []byte
 	for _, metric := range metrics {
 		metric.AddTag("url", req.url)
 		tags := metric.Tags()
 		m := tmetric.New(metric.Name(), tags, metric.Fields(), metric.Time(), metric.Type())
-		data, err := serializer.Serialize(m)
-		if err != nil {
-			return err
-		}
-		result, err := capi.InsertInfluxdb(conn, data, p.conf.DB, "ns")
+		line, err := serializer.Serialize(m)
 		if err != nil {
-			return err
-		}
-		if result.FailCount != 0 {
-			return errors.New(strings.Join(result.ErrorList, ","))
+			return nil, err
 		}
+		data = append(data, line...)
 	}
-	return nil
+	return data, nil
 }
 
 func init() {
@@ -10,19 +10,25 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"github.com/taosdata/blm3/config"
 	"github.com/taosdata/blm3/db/commonpool"
 	"github.com/taosdata/blm3/log"
 	"github.com/taosdata/blm3/plugin"
 	"github.com/taosdata/blm3/schemaless/capi"
+	"github.com/taosdata/blm3/tools/limit"
+	"github.com/taosdata/blm3/tools/monitor"
 	"github.com/taosdata/blm3/tools/pool"
 	"github.com/taosdata/blm3/tools/web"
 	"github.com/taosdata/driver-go/v2/af"
+	"github.com/valyala/fastjson"
 )
 
 var logger = log.GetLogger("opentsdb")
 
 type Plugin struct {
+	plugin.State
 	conf        Config
+	routed      bool
 	reserveConn *af.Connector
 }
 
@@ -40,19 +46,39 @@ func (p *Plugin) Init(r gin.IRouter) error {
 		logger.Info("opentsdb disabled")
 		return nil
 	}
-	r.POST("put/json/:db", plugin.Auth(p.errorResponse), p.insertJson)
-	r.POST("put/telnet/:db", plugin.Auth(p.errorResponse), p.insertTelnet)
+	p.SetEnabled(true)
+	ipLimiter := limit.IPHandler(p.errorResponse)
+	userLimiter := limit.UserHandler(p.errorResponse)
+	r.POST("put/json/:db", ipLimiter, plugin.Auth(p.errorResponse), userLimiter, p.insertJson)
+	r.POST("put/telnet/:db", ipLimiter, plugin.Auth(p.errorResponse), userLimiter, p.insertTelnet)
+	p.routed = true
 	return nil
 }
 
+// Reload reads the configuration, the routes are only registered by Init so enabling opentsdb may need a restart.
+func (p *Plugin) Reload() ([]string, func() error) {
+	var conf Config
+	conf.setValue()
+	return config.Diff("opentsdb", p.conf, conf), func() error {
+		if conf.Enable && !p.routed {
+			return plugin.ErrRestartRequired
+		}
+		p.conf = conf
+		p.SetEnabled(conf.Enable)
+		return nil
+	}
+}
+
 func (p *Plugin) Start() error {
 	if !p.conf.Enable {
 		return nil
 	}
+	p.SetListening(true)
 	return nil
 }
 
 func (p *Plugin) Stop() error {
+	p.SetListening(false)
 	return nil
 }
 
@@ -72,14 +98,19 @@ func (p *Plugin) insertJson(c *gin.Context) {
 		p.errorResponse(c, http.StatusBadRequest, err)
 		return
 	}
+	points := jsonPoints(data)
+	monitor.AddPoints(p.String(), monitor.PointsReceived, points)
 	user, password, err := plugin.GetAuth(c)
 	if err != nil {
+		monitor.AddPoints(p.String(), monitor.PointsFailed, points)
 		logger.WithError(err).Error("get auth error")
 		p.errorResponse(c, http.StatusBadRequest, err)
 		return
 	}
 	taosConn, err := commonpool.GetConnection(user, password)
 	if err != nil {
+		monitor.AddPoints(p.String(), monitor.PointsFailed, points)
+		p.WriteFailed(err)
 		logger.WithError(err).Error("connect taosd error")
 		p.errorResponse(c, http.StatusInternalServerError, err)
 		return
@@ -98,10 +129,14 @@ func (p *Plugin) insertJson(c *gin.Context) {
 	err = capi.InsertOpentsdbJson(taosConn.TaosConnection, data, db)
 	logger.Debug("insert json payload cost:", time.Now().Sub(start))
 	if err != nil {
+		monitor.AddPoints(p.String(), monitor.PointsFailed, points)
+		p.WriteFailed(err)
 		logger.WithError(err).Error("insert json payload error", string(data))
 		p.errorResponse(c, http.StatusInternalServerError, err)
 		return
 	}
+	monitor.AddPoints(p.String(), monitor.PointsInserted, points)
+	p.WriteSucceeded()
 	p.successResponse(c)
 }
 
@@ -136,14 +171,18 @@ func (p *Plugin) insertTelnet(c *gin.Context) {
 		}
 	}
 
+	monitor.AddPoints(p.String(), monitor.PointsReceived, len(lines))
 	user, password, err := plugin.GetAuth(c)
 	if err != nil {
+		monitor.AddPoints(p.String(), monitor.PointsFailed, len(lines))
 		logger.WithError(err).Error("get auth error")
 		p.errorResponse(c, http.StatusBadRequest, err)
 		return
 	}
 	taosConn, err := commonpool.GetConnection(user, password)
 	if err != nil {
+		monitor.AddPoints(p.String(), monitor.PointsFailed, len(lines))
+		p.WriteFailed(err)
 		logger.WithError(err).Error("connect taosd error")
 		p.errorResponse(c, http.StatusInternalServerError, err)
 		return
@@ -163,10 +202,18 @@ func (p *Plugin) insertTelnet(c *gin.Context) {
 	for _, line := range lines {
 		err := capi.InsertOpentsdbTelnet(taosConn.TaosConnection, line, db)
 		if err != nil {
+			if len(errorList) == 0 {
+				p.WriteFailed(err)
+			}
 			errorList = append(errorList, err.Error())
 		}
 	}
+	if len(errorList) < len(lines) {
+		p.WriteSucceeded()
+	}
 	logger.Debug("insert telnet payload cost:", time.Now().Sub(start))
+	monitor.AddPoints(p.String(), monitor.PointsInserted, len(lines)-len(errorList))
+	monitor.AddPoints(p.String(), monitor.PointsFailed, len(errorList))
 	if len(errorList) != 0 {
 		logger.WithError(errors.New(strings.Join(errorList, ","))).Error("insert telnet payload error", lines)
 		p.errorResponse(c, http.StatusInternalServerError, errors.New(strings.Join(errorList, ",")))
@@ -175,6 +222,18 @@ func (p *Plugin) insertTelnet(c *gin.Context) {
 	p.successResponse(c)
 }
 
+// jsonPoints returns the number of data points of a json payload, which is a point or an array of points.
+func jsonPoints(data []byte) int {
+	v, err := fastjson.ParseBytes(data)
+	if err != nil {
+		return 1
+	}
+	if v.Type() == fastjson.TypeArray {
+		return len(v.GetArray())
+	}
+	return 1
+}
+
 type message struct {
 	Code    int    `json:"code"`
 	Message string `json:"message,omitempty"`
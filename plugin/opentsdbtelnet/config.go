@@ -3,6 +3,7 @@ package opentsdbtelnet
 import (
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"github.com/taosdata/blm3/config"
 	"github.com/taosdata/driver-go/v2/common"
 )
 
@@ -15,6 +16,7 @@ type Config struct {
 	User              string
 	Password          string
 	Worker            int
+	TLS               config.ListenerTLS
 }
 
 func (c *Config) setValue() {
@@ -26,6 +28,7 @@ func (c *Config) setValue() {
 	c.User = viper.GetString("opentsdb_telnet.user")
 	c.Password = viper.GetString("opentsdb_telnet.password")
 	c.Worker = viper.GetInt("opentsdb_telnet.worker")
+	c.TLS.SetValue("opentsdb_telnet")
 }
 func init() {
 	_ = viper.BindEnv("opentsdb_telnet.enable", "BLM_OPENTSDB_TELNET_ENABLE")
@@ -59,4 +62,6 @@ func init() {
 	_ = viper.BindEnv("opentsdb_telnet.worker", "BLM_OPENTSDB_TELNET_WORKER")
 	pflag.Int("opentsdb_telnet.worker", 1000, `opentsdb_telnet write worker. Env "BLM_OPENTSDB_TELNET_WORKER"`)
 	viper.SetDefault("opentsdb_telnet.worker", 1000)
+
+	config.InitListenerTLS("opentsdb_telnet", "BLM_OPENTSDB_TELNET")
 }
@@ -1,6 +1,7 @@
 package opentsdbtelnet
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
@@ -11,54 +12,93 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"github.com/taosdata/blm3/config"
 	"github.com/taosdata/blm3/db/commonpool"
+	"github.com/taosdata/blm3/db/writebuffer"
 	"github.com/taosdata/blm3/log"
 	"github.com/taosdata/blm3/plugin"
+	"github.com/taosdata/blm3/schemaless/batch"
 	"github.com/taosdata/blm3/schemaless/capi"
+	"github.com/taosdata/blm3/tools/certauth"
+	"github.com/taosdata/blm3/tools/monitor"
 )
 
 var logger = log.GetLogger("opentsdb_telnet")
 var versionCommand = "version"
 
 type Plugin struct {
+	plugin.State
 	conf        Config
 	done        chan struct{}
 	id          uint64
 	accept      chan bool
-	in          chan []string
 	wg          sync.WaitGroup
 	cleanup     sync.Mutex
 	TCPListener *net.TCPListener
-	connList    map[uint64]*net.TCPConn
+	tlsConfig   *tls.Config
+	connList    map[uint64]net.Conn
+	writer      *writebuffer.Writer
+	batcher     *batch.Batcher
+	key         batch.Key
 }
 
 func (p *Plugin) Init(_ gin.IRouter) error {
 	p.conf.setValue()
+	p.prepare()
 	if !p.conf.Enable {
 		logger.Info("opentsdb_telnet disabled")
-		return nil
 	}
-	p.accept = make(chan bool, p.conf.MaxTCPConnections)
 	return nil
 }
 
+func (p *Plugin) prepare() {
+	p.SetEnabled(p.conf.Enable)
+	p.key = batch.Key{DB: p.conf.DB, Protocol: "opentsdb_telnet", User: p.conf.User}
+}
+
+// Reload reads the configuration, the tcp listener uses it when the plugin is started again.
+func (p *Plugin) Reload() ([]string, func() error) {
+	var conf Config
+	conf.setValue()
+	return config.Diff("opentsdb_telnet", p.conf, conf), func() error {
+		p.conf = conf
+		p.prepare()
+		return nil
+	}
+}
+
 func (p *Plugin) Start() error {
 	if !p.conf.Enable {
 		return nil
 	}
-	p.connList = make(map[uint64]*net.TCPConn)
+	p.tlsConfig = nil
+	if p.conf.TLS.Enable {
+		tlsConfig, err := certauth.ListenerConfig(&p.conf.TLS, &config.Conf.SSl)
+		if err != nil {
+			return err
+		}
+		p.tlsConfig = tlsConfig
+	}
+	p.done = make(chan struct{})
+	var err error
+	p.writer, err = writebuffer.NewWriter(&config.Conf.WriteBuffer, "opentsdb_telnet", p.conf.DB, p.insert)
+	if err != nil {
+		return err
+	}
+	p.connList = make(map[uint64]net.Conn)
+	p.accept = make(chan bool, p.conf.MaxTCPConnections)
 	for i := 0; i < p.conf.MaxTCPConnections; i++ {
 		p.accept <- true
 	}
-	p.in = make(chan []string, p.conf.Worker*2)
-	p.done = make(chan struct{})
-	err := p.tcp(p.conf.Port)
+	p.batcher = batch.New(&config.Conf.Batch, p.conf.Worker, p.flush)
+	err = p.tcp(p.conf.Port)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// Stop releases what Start has opened, it is also called after a failed Start.
 func (p *Plugin) Stop() error {
 	if !p.conf.Enable {
 		return nil
@@ -70,7 +110,7 @@ func (p *Plugin) Stop() error {
 		p.TCPListener.Close()
 		p.TCPListener = nil
 	}
-	var tcpConnList []*net.TCPConn
+	var tcpConnList []net.Conn
 	p.cleanup.Lock()
 	for _, conn := range p.connList {
 		tcpConnList = append(tcpConnList, conn)
@@ -80,7 +120,15 @@ func (p *Plugin) Stop() error {
 		conn.Close()
 	}
 	p.wg.Wait()
-	close(p.in)
+	if p.batcher != nil {
+		p.batcher.Close()
+		p.batcher = nil
+	}
+	if p.writer != nil {
+		err := p.writer.Close()
+		p.writer = nil
+		return err
+	}
 	return nil
 }
 
@@ -92,7 +140,7 @@ func (p *Plugin) Version() string {
 	return "v1"
 }
 
-func (p *Plugin) handler(conn *net.TCPConn, id uint64) {
+func (p *Plugin) handler(conn net.Conn, id uint64) {
 	defer func() {
 		p.wg.Done()
 		conn.Close()
@@ -130,7 +178,6 @@ func (p *Plugin) handler(conn *net.TCPConn, id uint64) {
 				copy(data, buffer[:customIndex])
 				buffer = buffer[customIndex+1:]
 				lines := strings.Split(string(data), "\n")
-				insertLines := make([]string, 0, len(lines))
 				for _, line := range lines {
 					if len(line) == 0 {
 						continue
@@ -138,14 +185,15 @@ func (p *Plugin) handler(conn *net.TCPConn, id uint64) {
 					if line == versionCommand {
 						conn.Write([]byte{'1'})
 						continue
-					} else {
-						insertLines = append(insertLines, line)
 					}
-				}
-				select {
-				case p.in <- insertLines:
-				default:
-					logger.Errorln("can not handle more message so far. increase opentsdb_telnet.worker")
+					// blocks while the inserts are behind
+					monitor.AddPoints(p.String(), monitor.PointsReceived, 1)
+					err = p.batcher.Add(p.key, []byte(line))
+					if err != nil {
+						monitor.AddPoints(p.String(), monitor.PointsDropped, 1)
+						logger.WithError(err).Error("add telnet payload error")
+						return
+					}
 				}
 
 			}
@@ -163,24 +211,22 @@ func (p *Plugin) tcp(port int) error {
 		return err
 	}
 
-	logger.Infof("TCP listening on %q", listener.Addr().String())
+	if p.tlsConfig != nil {
+		logger.Infof("TCP listening with tls on %q", listener.Addr().String())
+	} else {
+		logger.Infof("TCP listening on %q", listener.Addr().String())
+	}
 	p.TCPListener = listener
+	p.SetListening(true)
 	p.wg.Add(1)
 	go func() {
 		defer p.wg.Done()
+		defer p.SetListening(false)
 		if err := p.tcpListen(listener); err != nil {
 			logger.WithError(err).Panic()
 		}
 	}()
 
-	for i := 1; i <= p.conf.Worker; i++ {
-		// Start the line parser
-		p.wg.Add(1)
-		go func() {
-			defer p.wg.Done()
-			p.parser()
-		}()
-	}
 	return nil
 }
 
@@ -204,10 +250,15 @@ func (p *Plugin) tcpListen(listener *net.TCPListener) error {
 
 			select {
 			case <-p.accept:
+				var c net.Conn = conn
+				if p.tlsConfig != nil {
+					// the handshake runs on the first read of the handler
+					c = tls.Server(conn, p.tlsConfig)
+				}
 				p.wg.Add(1)
 				id := atomic.AddUint64(&p.id, 1)
-				p.remember(id, conn)
-				go p.handler(conn, id)
+				p.remember(id, c)
+				go p.handler(c, id)
 			default:
 				p.refuser(conn)
 			}
@@ -221,7 +272,7 @@ func (p *Plugin) forget(id uint64) {
 	delete(p.connList, id)
 }
 
-func (p *Plugin) remember(id uint64, conn *net.TCPConn) {
+func (p *Plugin) remember(id uint64, conn net.Conn) {
 	p.cleanup.Lock()
 	defer p.cleanup.Unlock()
 	p.connList[id] = conn
@@ -233,22 +284,20 @@ func (p *Plugin) refuser(conn *net.TCPConn) {
 	logger.Warn("Maximum TCP Connections reached")
 }
 
-func (p *Plugin) parser() {
-	for {
-		select {
-		case <-p.done:
-			return
-		case in := <-p.in:
-			p.handleData(in)
-		}
+func (p *Plugin) flush(_ batch.Key, data []byte, lines int) {
+	err := p.writer.Write(data)
+	if err != nil {
+		monitor.AddPoints(p.String(), monitor.PointsDropped, lines)
+		p.WriteFailed(err)
+		logger.WithError(err).Errorf("insert %d telnet payloads error", lines)
 	}
 }
 
-func (p *Plugin) handleData(lines []string) {
+func (p *Plugin) insert(data []byte) error {
 	taosConn, err := commonpool.GetConnection(p.conf.User, p.conf.Password)
 	if err != nil {
-		logger.WithError(err).Error("connect taosd error")
-		return
+		p.WriteFailed(err)
+		return err
 	}
 	defer func() {
 		putErr := taosConn.Put()
@@ -260,14 +309,23 @@ func (p *Plugin) handleData(lines []string) {
 	if logger.Logger.IsLevelEnabled(logrus.DebugLevel) {
 		start = time.Now()
 	}
-	for _, line := range lines {
-		logger.Debug(start, "insert telnet payload", line)
-		err = capi.InsertOpentsdbTelnet(taosConn.TaosConnection, line, p.conf.DB)
-		if err != nil {
-			logger.WithError(err).Error("insert telnet payload error", line)
-		}
-		logger.Debug("insert telnet payload cost:", time.Now().Sub(start))
+	logger.Debug(start, "insert telnet payload", string(data))
+	result, err := capi.InsertOpentsdbTelnetBatch(taosConn.TaosConnection, data, p.conf.DB)
+	logger.Debug("insert telnet payload cost:", time.Now().Sub(start))
+	if err != nil {
+		p.WriteFailed(err)
+		return err
+	}
+	monitor.AddPoints(p.String(), monitor.PointsInserted, result.SuccessCount)
+	monitor.AddPoints(p.String(), monitor.PointsFailed, result.FailCount)
+	if result.SuccessCount != 0 {
+		p.WriteSucceeded()
 	}
+	if result.FailCount != 0 {
+		p.WriteFailed(result.Errors[0])
+		logger.WithField("result", result).Errorln("insert telnet payload error success:", result.SuccessCount, "fail:", result.FailCount, "errors:", strings.Join(result.ErrorList, ","))
+	}
+	return nil
 }
 
 func init() {
@@ -0,0 +1,257 @@
+package prometheus
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/snappy"
+	"github.com/influxdata/influxdb/v2/models"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/sirupsen/logrus"
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/blm3/db/commonpool"
+	"github.com/taosdata/blm3/log"
+	"github.com/taosdata/blm3/plugin"
+	"github.com/taosdata/blm3/schemaless/capi"
+	"github.com/taosdata/blm3/tools/limit"
+	"github.com/taosdata/blm3/tools/monitor"
+	"github.com/taosdata/blm3/tools/pool"
+	"github.com/taosdata/blm3/tools/taoserror"
+	"github.com/taosdata/blm3/tools/web"
+)
+
+var logger = log.GetLogger("prometheus")
+
+const (
+	metricNameLabel = "__name__"
+	valueField      = "value"
+)
+
+type Plugin struct {
+	plugin.State
+	conf   Config
+	routed bool
+}
+
+func (p *Plugin) String() string {
+	return "prometheus"
+}
+
+func (p *Plugin) Version() string {
+	return "v1"
+}
+
+func (p *Plugin) Init(r gin.IRouter) error {
+	p.conf.setValue()
+	if !p.conf.Enable {
+		logger.Info("prometheus disabled")
+		return nil
+	}
+	p.SetEnabled(true)
+	ipLimiter := limit.IPHandler(p.errorResponse)
+	userLimiter := limit.UserHandler(p.errorResponse)
+	r.POST("remote_write", ipLimiter, plugin.Auth(p.errorResponse), userLimiter, p.write)
+	r.POST("remote_read", ipLimiter, plugin.Auth(p.errorResponse), userLimiter, p.read)
+	p.routed = true
+	return nil
+}
+
+// Reload reads the configuration, enabling prometheus needs a restart when its routes were not registered by Init.
+func (p *Plugin) Reload() ([]string, func() error) {
+	var conf Config
+	conf.setValue()
+	return config.Diff("prometheus", p.conf, conf), func() error {
+		if conf.Enable && !p.routed {
+			return plugin.ErrRestartRequired
+		}
+		p.conf = conf
+		p.SetEnabled(conf.Enable)
+		return nil
+	}
+}
+
+func (p *Plugin) Start() error {
+	if !p.conf.Enable {
+		return nil
+	}
+	p.SetListening(true)
+	return nil
+}
+
+func (p *Plugin) Stop() error {
+	p.SetListening(false)
+	return nil
+}
+
+func (p *Plugin) write(c *gin.Context) {
+	id := web.GetRequestID(c)
+	logger := logger.WithField("sessionID", id)
+	isDebug := logger.Logger.IsLevelEnabled(logrus.DebugLevel)
+	db := c.Query("db")
+	if len(db) == 0 {
+		logger.Errorln("db required")
+		p.errorResponse(c, http.StatusBadRequest, errors.New("db required"))
+		return
+	}
+	data, err := c.GetRawData()
+	if err != nil {
+		logger.WithError(err).Error("get request body error")
+		p.errorResponse(c, http.StatusBadRequest, err)
+		return
+	}
+	if !web.IsDecompressed(c) {
+		data, err = snappy.Decode(nil, data)
+		if err != nil {
+			logger.WithError(err).Error("snappy decode error")
+			p.errorResponse(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+	var req prompb.WriteRequest
+	err = req.Unmarshal(data)
+	if err != nil {
+		logger.WithError(err).Error("unmarshal write request error")
+		p.errorResponse(c, http.StatusBadRequest, err)
+		return
+	}
+	lines, skipped := writeRequestToLines(&req)
+	points := monitor.CountLines(lines)
+	monitor.AddPoints(p.String(), monitor.PointsReceived, points+skipped)
+	monitor.AddPoints(p.String(), monitor.PointsDropped, skipped)
+	if skipped != 0 {
+		logger.Debugln("skip samples without metric name or with NaN and Inf values:", skipped)
+	}
+	if len(lines) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	user, password, err := plugin.GetAuth(c)
+	if err != nil {
+		logger.WithError(err).Error("get auth error")
+		monitor.AddPoints(p.String(), monitor.PointsFailed, points)
+		p.errorResponse(c, http.StatusBadRequest, err)
+		return
+	}
+	taosConn, err := commonpool.GetConnection(user, password)
+	if err != nil {
+		monitor.AddPoints(p.String(), monitor.PointsFailed, points)
+		p.WriteFailed(err)
+		logger.WithError(err).Error("connect taosd error")
+		p.errorResponse(c, writeStatus(err), err)
+		return
+	}
+	defer func() {
+		putErr := taosConn.Put()
+		if putErr != nil {
+			logger.WithError(putErr).Errorln("taos connect pool put error")
+		}
+	}()
+	var start time.Time
+	if isDebug {
+		start = time.Now()
+	}
+	logger.Debugln(start, "insert prometheus samples:", len(lines))
+	result, err := capi.InsertInfluxdb(taosConn.TaosConnection, lines, db, "ns")
+	logger.Debugln("insert prometheus samples cost:", time.Now().Sub(start))
+	if err != nil {
+		monitor.AddPoints(p.String(), monitor.PointsFailed, points)
+		p.WriteFailed(err)
+		logger.WithError(err).Error("insert prometheus samples error")
+		p.errorResponse(c, writeStatus(err), err)
+		return
+	}
+	monitor.AddPoints(p.String(), monitor.PointsInserted, result.SuccessCount)
+	monitor.AddPoints(p.String(), monitor.PointsFailed, result.FailCount)
+	if result.SuccessCount != 0 {
+		p.WriteSucceeded()
+	}
+	if result.FailCount != 0 {
+		p.WriteFailed(result.Errors[0])
+		logger.WithField("result", result).Errorln("insert prometheus samples inner error success:", result.SuccessCount, "fail:", result.FailCount)
+		// the good samples are written, prometheus would resend the whole batch forever on a 5xx
+		p.errorResponse(c, http.StatusBadRequest, errors.New(strings.Join(result.ErrorList, ",")))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// writeStatus answers the errors which may go away when prometheus retries the batch with 500 and the refused
+// credentials and samples with 401 and 400, prometheus drops the batch on a 4xx.
+func writeStatus(err error) int {
+	if taoserror.IsUnavailable(err) {
+		return http.StatusInternalServerError
+	}
+	if taoserror.IsAuthFailure(err) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusBadRequest
+}
+
+// writeRequestToLines converts every sample to an influxdb line with the metric name as measurement, the other
+// labels as tags and the sample value as field value. Samples without metric name and NaN or Inf values, which
+// include prometheus stale markers, are skipped and counted.
+func writeRequestToLines(req *prompb.WriteRequest) (lines []byte, skipped int) {
+	b := pool.BytesPoolGet()
+	defer pool.BytesPoolPut(b)
+	var buf []byte
+	for _, ts := range req.Timeseries {
+		name := ""
+		tags := make(models.Tags, 0, len(ts.Labels))
+		for _, label := range ts.Labels {
+			if label.Name == metricNameLabel {
+				name = label.Value
+				continue
+			}
+			if len(label.Value) == 0 {
+				continue
+			}
+			tags = append(tags, models.NewTag([]byte(label.Name), []byte(label.Value)))
+		}
+		if len(name) == 0 {
+			skipped += len(ts.Samples)
+			continue
+		}
+		sort.Sort(tags)
+		for _, sample := range ts.Samples {
+			if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+				skipped += 1
+				continue
+			}
+			point, err := models.NewPoint(name, tags, models.Fields{valueField: sample.Value}, time.Unix(0, sample.Timestamp*int64(time.Millisecond)))
+			if err != nil {
+				skipped += 1
+				continue
+			}
+			buf = point.AppendString(buf[:0])
+			b.Write(buf)
+			b.WriteByte('\n')
+		}
+	}
+	if b.Len() == 0 {
+		return nil, skipped
+	}
+	lines = make([]byte, b.Len())
+	copy(lines, b.Bytes())
+	return lines, skipped
+}
+
+type message struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (p *Plugin) errorResponse(c *gin.Context, code int, err error) {
+	c.JSON(code, message{
+		Code:    code,
+		Message: err.Error(),
+	})
+}
+
+func init() {
+	plugin.Register(&Plugin{})
+}
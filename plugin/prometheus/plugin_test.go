@@ -0,0 +1,99 @@
+package prometheus
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/blm3/tools/login"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
+)
+
+func TestWriteRequestToLines(t *testing.T) {
+	req := &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			{
+				Labels: []prompb.Label{
+					{Name: "__name__", Value: "http_requests_total"},
+					{Name: "method", Value: "GET"},
+					{Name: "instance", Value: "host a,1"},
+					{Name: "empty", Value: ""},
+				},
+				Samples: []prompb.Sample{
+					{Value: 1, Timestamp: 1633046400000},
+					{Value: 2.5, Timestamp: 1633046401000},
+				},
+			},
+			{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "up"}},
+				Samples: []prompb.Sample{{Value: math.NaN(), Timestamp: 1633046400000}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: "job", Value: "node"}},
+				Samples: []prompb.Sample{{Value: 1, Timestamp: 1633046400000}},
+			},
+		},
+	}
+	lines, skipped := writeRequestToLines(req)
+	assert.Equal(t, 2, skipped)
+	assert.Equal(t, "http_requests_total,instance=host\\ a\\,1,method=GET value=1 1633046400000000000\n"+
+		"http_requests_total,instance=host\\ a\\,1,method=GET value=2.5 1633046401000000000\n", string(lines))
+}
+
+func TestWriteBadRequest(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	defer func(conf *config.Config, verifyUser func(user, password string) error) {
+		config.Conf = conf
+		login.VerifyUser = verifyUser
+	}(config.Conf, login.VerifyUser)
+	config.Conf = &config.Config{}
+	login.VerifyUser = func(user, password string) error {
+		if password != "taosdata" {
+			return &tErrors.TaosError{Code: tErrors.RPC_AUTH_FAILURE, ErrStr: "Authentication failure"}
+		}
+		return nil
+	}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("currentID", uint32(0))
+	})
+	p := &Plugin{}
+	assert.NoError(t, p.Init(router.Group("prometheus/v1")))
+	data, err := (&prompb.WriteRequest{}).Marshal()
+	assert.NoError(t, err)
+	tests := []struct {
+		name string
+		url  string
+		body []byte
+		code int
+	}{
+		{name: "no db", url: "/prometheus/v1/remote_write", body: snappy.Encode(nil, data), code: http.StatusBadRequest},
+		{name: "not snappy", url: "/prometheus/v1/remote_write?db=test", body: []byte("abc"), code: http.StatusBadRequest},
+		{name: "not protobuf", url: "/prometheus/v1/remote_write?db=test", body: snappy.Encode(nil, []byte("abc")), code: http.StatusBadRequest},
+		{name: "empty", url: "/prometheus/v1/remote_write?db=test", body: snappy.Encode(nil, data), code: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, tt.url, bytes.NewReader(tt.body))
+			req.SetBasicAuth("root", "taosdata")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+}
+
+func TestWriteStatus(t *testing.T) {
+	assert.Equal(t, http.StatusInternalServerError, writeStatus(errors.New("connection refused")))
+	assert.Equal(t, http.StatusInternalServerError, writeStatus(&tErrors.TaosError{Code: tErrors.RPC_NETWORK_UNAVAIL}))
+	assert.Equal(t, http.StatusUnauthorized, writeStatus(&tErrors.TaosError{Code: tErrors.RPC_AUTH_FAILURE}))
+	assert.Equal(t, http.StatusBadRequest, writeStatus(&tErrors.TaosError{Code: tErrors.TSC_INVALID_VALUE}))
+}
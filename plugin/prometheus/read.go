@@ -0,0 +1,362 @@
+package prometheus
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/taosdata/blm3/db/async"
+	"github.com/taosdata/blm3/db/commonpool"
+	"github.com/taosdata/blm3/httperror"
+	"github.com/taosdata/blm3/plugin"
+	"github.com/taosdata/blm3/tools/pool"
+	"github.com/taosdata/blm3/tools/web"
+	"github.com/taosdata/driver-go/v2/common"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
+)
+
+const timeLayout = "2006-01-02T15:04:05.000Z"
+
+var identifierRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// tableName returns the super table of a metric name, the schemaless interface escapes names which are not
+// identifiers, such as recording rules with ':', with backquotes. ok is false for names with a backquote.
+func tableName(metric string) (name string, ok bool) {
+	if identifierRegexp.MatchString(metric) {
+		return metric, true
+	}
+	if strings.Contains(metric, "`") {
+		return "", false
+	}
+	return "`" + metric + "`", true
+}
+
+func (p *Plugin) read(c *gin.Context) {
+	id := web.GetRequestID(c)
+	logger := logger.WithField("sessionID", id)
+	db := c.Query("db")
+	if len(db) == 0 {
+		logger.Errorln("db required")
+		p.errorResponse(c, http.StatusBadRequest, errors.New("db required"))
+		return
+	}
+	if !identifierRegexp.MatchString(db) {
+		logger.Errorln("unsupported db name", db)
+		p.errorResponse(c, http.StatusBadRequest, fmt.Errorf("unsupported db name %s", db))
+		return
+	}
+	data, err := c.GetRawData()
+	if err != nil {
+		logger.WithError(err).Error("get request body error")
+		p.errorResponse(c, http.StatusBadRequest, err)
+		return
+	}
+	if !web.IsDecompressed(c) {
+		data, err = snappy.Decode(nil, data)
+		if err != nil {
+			logger.WithError(err).Error("snappy decode error")
+			p.errorResponse(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+	var req prompb.ReadRequest
+	err = req.Unmarshal(data)
+	if err != nil {
+		logger.WithError(err).Error("unmarshal read request error")
+		p.errorResponse(c, http.StatusBadRequest, err)
+		return
+	}
+	queries, err := parseReadRequest(&req)
+	if err != nil {
+		logger.WithError(err).Error("invalid read request")
+		p.errorResponse(c, http.StatusBadRequest, err)
+		return
+	}
+	user, password, err := plugin.GetAuth(c)
+	if err != nil {
+		logger.WithError(err).Error("get auth error")
+		p.errorResponse(c, http.StatusBadRequest, err)
+		return
+	}
+	taosConn, err := commonpool.GetConnection(user, password)
+	if err != nil {
+		logger.WithError(err).Error("connect taosd error")
+		p.errorResponse(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer func() {
+		putErr := taosConn.Put()
+		if putErr != nil {
+			logger.WithError(putErr).Errorln("taos connect pool put error")
+		}
+	}()
+	resp := &prompb.ReadResponse{Results: make([]*prompb.QueryResult, len(queries))}
+	for i, q := range queries {
+		series, err := q.exec(taosConn.TaosConnection, db)
+		if err != nil {
+			logger.WithError(err).Error("query prometheus samples error")
+			p.errorResponse(c, http.StatusInternalServerError, err)
+			return
+		}
+		resp.Results[i] = &prompb.QueryResult{Timeseries: series}
+	}
+	data, err = resp.Marshal()
+	if err != nil {
+		logger.WithError(err).Error("marshal read response error")
+		p.errorResponse(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Header("Content-Encoding", "snappy")
+	c.Data(http.StatusOK, "application/x-protobuf", snappy.Encode(nil, data))
+}
+
+type readQuery struct {
+	metric   string
+	table    string
+	start    int64
+	end      int64
+	matchers []*matcher
+}
+
+type matcher struct {
+	*prompb.LabelMatcher
+	re *regexp.Regexp
+}
+
+func (m *matcher) matches(v string) bool {
+	switch m.Type {
+	case prompb.LabelMatcher_EQ:
+		return v == m.Value
+	case prompb.LabelMatcher_NEQ:
+		return v != m.Value
+	case prompb.LabelMatcher_RE:
+		return m.re.MatchString(v)
+	case prompb.LabelMatcher_NRE:
+		return !m.re.MatchString(v)
+	}
+	return false
+}
+
+// parseReadRequest checks every query has an equal matcher on the metric name, which selects the super table,
+// and compiles regular expression matchers the same way prometheus does, anchored at both ends.
+func parseReadRequest(req *prompb.ReadRequest) ([]*readQuery, error) {
+	if len(req.AcceptedResponseTypes) != 0 {
+		supported := false
+		for _, t := range req.AcceptedResponseTypes {
+			if t == prompb.ReadRequest_SAMPLES {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return nil, errors.New("only samples response type is supported")
+		}
+	}
+	queries := make([]*readQuery, len(req.Queries))
+	for i, q := range req.Queries {
+		rq := &readQuery{start: q.StartTimestampMs, end: q.EndTimestampMs}
+		for _, m := range q.Matchers {
+			if m.Name == metricNameLabel && m.Type == prompb.LabelMatcher_EQ {
+				rq.metric = m.Value
+			}
+			mm := &matcher{LabelMatcher: m}
+			if m.Type == prompb.LabelMatcher_RE || m.Type == prompb.LabelMatcher_NRE {
+				re, err := regexp.Compile("^(?:" + m.Value + ")$")
+				if err != nil {
+					return nil, err
+				}
+				mm.re = re
+			}
+			rq.matchers = append(rq.matchers, mm)
+		}
+		if len(rq.metric) == 0 {
+			return nil, fmt.Errorf("query %d: equal matcher on %s required", i, metricNameLabel)
+		}
+		table, ok := tableName(rq.metric)
+		if !ok {
+			return nil, fmt.Errorf("query %d: unsupported metric name %s", i, rq.metric)
+		}
+		rq.table = table
+		queries[i] = rq
+	}
+	return queries, nil
+}
+
+func millisecondTime(ts int64, precision int) driver.Value {
+	return common.TimestampConvertToTime(ts, precision).UnixNano() / int64(time.Millisecond)
+}
+
+func (q *readQuery) exec(conn unsafe.Pointer, db string) ([]*prompb.TimeSeries, error) {
+	result, err := async.GlobalAsync.TaosExec(conn, fmt.Sprintf("describe %s.%s", db, q.table), millisecondTime)
+	if err != nil {
+		tError, ok := err.(*tErrors.TaosError)
+		if ok && tError.Code&0xffff == httperror.TSDB_CODE_MND_INVALID_TABLE {
+			return nil, nil
+		}
+		return nil, err
+	}
+	tsColumn, tags, ok := parseDescribe(result.Data)
+	if !ok {
+		return nil, nil
+	}
+	sql, ok := q.sql(db, tsColumn, tags)
+	if !ok {
+		return nil, nil
+	}
+	result, err = async.GlobalAsync.TaosExec(conn, sql, millisecondTime)
+	if err != nil {
+		return nil, err
+	}
+	return q.series(tags, result.Data), nil
+}
+
+// parseDescribe returns the timestamp column and the tags of a super table created by the schemaless
+// interface, ok is false when the table has no value column so it was not written by remote_write.
+func parseDescribe(rows [][]driver.Value) (tsColumn string, tags []string, ok bool) {
+	if len(rows) == 0 {
+		return "", nil, false
+	}
+	tsColumn, _ = rows[0][0].(string)
+	for _, row := range rows[1:] {
+		name, _ := row[0].(string)
+		note, _ := row[3].(string)
+		if note == "TAG" {
+			tags = append(tags, name)
+		} else if name == valueField {
+			ok = true
+		}
+	}
+	return tsColumn, tags, ok
+}
+
+// sql pushes the time range and the equal and not equal matchers down to TDengine, regular expression
+// matchers are applied to the result. ok is false when the matchers can not match any series.
+func (q *readQuery) sql(db, tsColumn string, tags []string) (sql string, ok bool) {
+	tagSet := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		tagSet[tag] = struct{}{}
+	}
+	b := pool.BytesPoolGet()
+	defer pool.BytesPoolPut(b)
+	b.WriteString("select ")
+	b.WriteString(tsColumn)
+	b.WriteString(",")
+	b.WriteString(valueField)
+	for _, tag := range tags {
+		b.WriteByte(',')
+		b.WriteString(tag)
+	}
+	fmt.Fprintf(b, " from %s.%s where %s >= '%s' and %s <= '%s'", db, q.table, tsColumn,
+		time.Unix(0, q.start*int64(time.Millisecond)).UTC().Format(timeLayout), tsColumn,
+		time.Unix(0, q.end*int64(time.Millisecond)).UTC().Format(timeLayout))
+	for _, m := range q.matchers {
+		if m.Name == metricNameLabel {
+			if !m.matches(q.metric) {
+				return "", false
+			}
+			continue
+		}
+		if _, exist := tagSet[m.Name]; !exist {
+			// the label is absent from every series
+			if !m.matches("") {
+				return "", false
+			}
+			continue
+		}
+		switch m.Type {
+		case prompb.LabelMatcher_EQ:
+			if len(m.Value) == 0 {
+				fmt.Fprintf(b, " and %s is null", m.Name)
+			} else {
+				fmt.Fprintf(b, " and %s = '%s'", m.Name, escapeString(m.Value))
+			}
+		case prompb.LabelMatcher_NEQ:
+			if len(m.Value) == 0 {
+				fmt.Fprintf(b, " and %s is not null", m.Name)
+			} else {
+				fmt.Fprintf(b, " and (%s != '%s' or %s is null)", m.Name, escapeString(m.Value), m.Name)
+			}
+		}
+	}
+	return b.String(), true
+}
+
+func escapeString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
+
+// series groups rows by tag values, rows are columns timestamp, value and then tags in the given order.
+func (q *readQuery) series(tags []string, rows [][]driver.Value) []*prompb.TimeSeries {
+	index := map[string]*prompb.TimeSeries{}
+	var result []*prompb.TimeSeries
+	var key strings.Builder
+	for _, row := range rows {
+		value, ok := row[1].(float64)
+		if !ok {
+			continue
+		}
+		key.Reset()
+		for _, v := range row[2:] {
+			if v != nil {
+				key.WriteString(fmt.Sprint(v))
+			}
+			key.WriteByte(0)
+		}
+		s, exist := index[key.String()]
+		if !exist {
+			labels := []prompb.Label{{Name: metricNameLabel, Value: q.metric}}
+			for i, v := range row[2:] {
+				if v == nil {
+					continue
+				}
+				if str := fmt.Sprint(v); len(str) != 0 {
+					labels = append(labels, prompb.Label{Name: tags[i], Value: str})
+				}
+			}
+			sort.Slice(labels, func(i, j int) bool {
+				return labels[i].Name < labels[j].Name
+			})
+			if q.matches(labels) {
+				s = &prompb.TimeSeries{Labels: labels}
+				result = append(result, s)
+			}
+			index[key.String()] = s
+		}
+		if s == nil {
+			continue
+		}
+		ts, _ := row[0].(int64)
+		s.Samples = append(s.Samples, prompb.Sample{Value: value, Timestamp: ts})
+	}
+	for _, s := range result {
+		sort.Slice(s.Samples, func(i, j int) bool {
+			return s.Samples[i].Timestamp < s.Samples[j].Timestamp
+		})
+	}
+	return result
+}
+
+func (q *readQuery) matches(labels []prompb.Label) bool {
+	for _, m := range q.matchers {
+		v := ""
+		for _, l := range labels {
+			if l.Name == m.Name {
+				v = l.Value
+				break
+			}
+		}
+		if !m.matches(v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package prometheus
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+)
+
+func parseQuery(t *testing.T, matchers ...*prompb.LabelMatcher) *readQuery {
+	queries, err := parseReadRequest(&prompb.ReadRequest{Queries: []*prompb.Query{{
+		StartTimestampMs: 1633046400000,
+		EndTimestampMs:   1633050000000,
+		Matchers:         matchers,
+	}}})
+	assert.NoError(t, err)
+	return queries[0]
+}
+
+func TestParseReadRequest(t *testing.T) {
+	_, err := parseReadRequest(&prompb.ReadRequest{Queries: []*prompb.Query{{
+		Matchers: []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_RE, Name: "__name__", Value: "up.*"}},
+	}}})
+	assert.Error(t, err)
+	_, err = parseReadRequest(&prompb.ReadRequest{Queries: []*prompb.Query{{
+		Matchers: []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "up"}, {Type: prompb.LabelMatcher_RE, Name: "job", Value: "("}},
+	}}})
+	assert.Error(t, err)
+	_, err = parseReadRequest(&prompb.ReadRequest{Queries: []*prompb.Query{{
+		Matchers: []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "a`b"}},
+	}}})
+	assert.Error(t, err)
+	_, err = parseReadRequest(&prompb.ReadRequest{
+		AcceptedResponseTypes: []prompb.ReadRequest_ResponseType{prompb.ReadRequest_STREAMED_XOR_CHUNKS},
+	})
+	assert.Error(t, err)
+}
+
+func TestParseDescribe(t *testing.T) {
+	tsColumn, tags, ok := parseDescribe([][]driver.Value{
+		{"_ts", "TIMESTAMP", int16(8), ""},
+		{"value", "DOUBLE", int16(8), ""},
+		{"instance", "NCHAR", int16(16), "TAG"},
+		{"job", "NCHAR", int16(4), "TAG"},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, "_ts", tsColumn)
+	assert.Equal(t, []string{"instance", "job"}, tags)
+	_, _, ok = parseDescribe([][]driver.Value{{"ts", "TIMESTAMP", int16(8), ""}, {"v", "INT", int16(4), ""}})
+	assert.False(t, ok)
+}
+
+func TestReadQuerySQL(t *testing.T) {
+	tags := []string{"instance", "job"}
+	q := parseQuery(t,
+		&prompb.LabelMatcher{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "up"},
+		&prompb.LabelMatcher{Type: prompb.LabelMatcher_EQ, Name: "job", Value: "it's"},
+		&prompb.LabelMatcher{Type: prompb.LabelMatcher_NEQ, Name: "instance", Value: "a"},
+		&prompb.LabelMatcher{Type: prompb.LabelMatcher_RE, Name: "instance", Value: "b.*"},
+		&prompb.LabelMatcher{Type: prompb.LabelMatcher_NEQ, Name: "absent", Value: "x"},
+	)
+	sql, ok := q.sql("test", "_ts", tags)
+	assert.True(t, ok)
+	assert.Equal(t, "select _ts,value,instance,job from test.up where _ts >= '2021-10-01T00:00:00.000Z' and _ts <= '2021-10-01T01:00:00.000Z'"+
+		" and job = 'it\\'s' and (instance != 'a' or instance is null)", sql)
+
+	q = parseQuery(t,
+		&prompb.LabelMatcher{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "up"},
+		&prompb.LabelMatcher{Type: prompb.LabelMatcher_EQ, Name: "absent", Value: "x"},
+	)
+	_, ok = q.sql("test", "_ts", tags)
+	assert.False(t, ok)
+
+	// recording rules are written to an escaped super table
+	q = parseQuery(t, &prompb.LabelMatcher{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "job:rate5m"})
+	sql, ok = q.sql("test", "_ts", nil)
+	assert.True(t, ok)
+	assert.Equal(t, "select _ts,value from test.`job:rate5m` where _ts >= '2021-10-01T00:00:00.000Z' and _ts <= '2021-10-01T01:00:00.000Z'", sql)
+}
+
+func TestReadQuerySeries(t *testing.T) {
+	q := parseQuery(t,
+		&prompb.LabelMatcher{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "up"},
+		&prompb.LabelMatcher{Type: prompb.LabelMatcher_NRE, Name: "instance", Value: "c|d"},
+	)
+	series := q.series([]string{"instance", "job"}, [][]driver.Value{
+		{int64(2000), float64(1), "a", "node"},
+		{int64(1000), float64(0), "a", "node"},
+		{int64(1000), float64(1), "b", nil},
+		{int64(1000), float64(1), "c", "node"},
+		{int64(3000), nil, "a", "node"},
+	})
+	assert.Equal(t, []*prompb.TimeSeries{
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "instance", Value: "a"}, {Name: "job", Value: "node"}},
+			Samples: []prompb.Sample{{Value: 0, Timestamp: 1000}, {Value: 1, Timestamp: 2000}},
+		},
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "instance", Value: "b"}},
+			Samples: []prompb.Sample{{Value: 1, Timestamp: 1000}},
+		},
+	}, series)
+}
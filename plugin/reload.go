@@ -0,0 +1,79 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrRestartRequired is returned by the apply function of a plugin for changes that need a restart of blm3.
+var ErrRestartRequired = errors.New("restart required")
+
+// Reloadable is implemented by plugins whose configuration can be reloaded. Reload reads the configuration and
+// returns the changed settings and a function which applies them to the stopped plugin.
+type Reloadable interface {
+	Reload() (changes []string, apply func() error)
+}
+
+// Reload applies the reloaded configuration of the plugins, only the plugins whose configuration changed are
+// stopped and started again.
+func Reload() {
+	for name, e := range plugins {
+		reloadable, ok := e.plugin.(Reloadable)
+		if !ok {
+			continue
+		}
+		e.lock.Lock()
+		if e.initialized {
+			e.reload(name, reloadable)
+		}
+		e.lock.Unlock()
+	}
+}
+
+func (e *entry) reload(name string, reloadable Reloadable) {
+	changes, apply, err := readConfig(reloadable)
+	if err != nil {
+		logger.WithError(err).Errorf("reload plugin %s config error, keep the running config", name)
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+	logger.Infof("reload plugin %s: %s", name, strings.Join(changes, ", "))
+	running := e.state == StateRunning
+	if running {
+		_ = e.stop(name)
+	}
+	err = apply()
+	if err == ErrRestartRequired {
+		logger.Warnf("the config of plugin %s takes effect after blm3 restarts", name)
+	} else if err != nil {
+		logger.WithError(err).Errorf("apply plugin %s config error", name)
+		e.state = StateFailed
+		e.err = err
+		return
+	}
+	if !e.enabled() {
+		e.state = StateDisabled
+		return
+	}
+	// plugins stopped by the admin api stay stopped, failed plugins are retried with the new config
+	if running || e.state == StateDisabled || e.state == StateFailed {
+		if e.state == StateDisabled {
+			e.state = StateStopped
+		}
+		_ = e.start(name)
+	}
+}
+
+// readConfig calls Reload of the plugin, the panics of invalid values are returned as errors.
+func readConfig(reloadable Reloadable) (changes []string, apply func() error, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("invalid config: %v", e)
+		}
+	}()
+	changes, apply = reloadable.Reload()
+	return changes, apply, nil
+}
@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/config"
+)
+
+type reloadablePlugin struct {
+	fakePlugin
+	db       string
+	nextDB   string
+	nextOn   bool
+	applyErr error
+}
+
+func (f *reloadablePlugin) Reload() ([]string, func() error) {
+	if f.nextDB == "panic" {
+		panic("invalid db")
+	}
+	var changes []string
+	if f.nextDB != f.db {
+		changes = append(changes, "db: "+f.db+" -> "+f.nextDB)
+	}
+	if f.nextOn != f.enable {
+		changes = append(changes, "enable changed")
+	}
+	return changes, func() error {
+		if f.applyErr != nil {
+			return f.applyErr
+		}
+		f.db = f.nextDB
+		f.enable = f.nextOn
+		f.SetEnabled(f.nextOn)
+		return nil
+	}
+}
+
+func TestReload(t *testing.T) {
+	defer func(p map[string]*entry, conf *config.Config) {
+		plugins = p
+		config.Conf = conf
+	}(plugins, config.Conf)
+	plugins = map[string]*entry{}
+	config.Conf = &config.Config{Plugin: config.Plugin{FailFast: false}}
+	changed := &reloadablePlugin{fakePlugin: fakePlugin{name: "changed", enable: true}, db: "a", nextDB: "a", nextOn: true}
+	same := &reloadablePlugin{fakePlugin: fakePlugin{name: "same", enable: true}, db: "a", nextDB: "a", nextOn: true}
+	enabled := &reloadablePlugin{fakePlugin: fakePlugin{name: "enabled"}, db: "a", nextDB: "a"}
+	Register(changed)
+	Register(same)
+	Register(enabled)
+	gin.SetMode(gin.ReleaseMode)
+	Init(gin.New())
+	Start()
+	assert.Equal(t, StateDisabled, Statuses()["enabled/v1"].State)
+
+	changed.nextDB = "b"
+	enabled.nextOn = true
+	Reload()
+	assert.Equal(t, "b", changed.db)
+	assert.Equal(t, 2, changed.starts)
+	assert.Equal(t, 1, changed.stops)
+	assert.Equal(t, 1, same.starts)
+	assert.Equal(t, 0, same.stops)
+	assert.Equal(t, StateRunning, Statuses()["enabled/v1"].State)
+
+	// an invalid config keeps the plugin running with the old config
+	changed.nextDB = "panic"
+	Reload()
+	assert.Equal(t, "b", changed.db)
+	assert.Equal(t, StateRunning, Statuses()["changed/v1"].State)
+
+	changed.nextDB = "c"
+	changed.applyErr = errors.New("invalid url")
+	Reload()
+	assert.Equal(t, StateFailed, Statuses()["changed/v1"].State)
+	changed.applyErr = nil
+	Reload()
+	assert.Equal(t, StateRunning, Statuses()["changed/v1"].State)
+	assert.Equal(t, "c", changed.db)
+
+	enabled.nextOn = false
+	Reload()
+	assert.Equal(t, StateDisabled, Statuses()["enabled/v1"].State)
+
+	// a plugin stopped by the admin api is not started by a reload
+	assert.NoError(t, StopPlugin("same/v1"))
+	same.nextDB = "b"
+	Reload()
+	assert.Equal(t, "b", same.db)
+	assert.Equal(t, StateStopped, Statuses()["same/v1"].State)
+	Stop()
+}
@@ -1,10 +1,12 @@
 package statsd
 
 import (
+	"strings"
 	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"github.com/taosdata/blm3/config"
 	"github.com/taosdata/driver-go/v2/common"
 )
 
@@ -24,6 +26,7 @@ type Config struct {
 	DeleteGauges           bool
 	DeleteSets             bool
 	DeleteTimings          bool
+	TLS                    config.ListenerTLS
 }
 
 func (c *Config) setValue() {
@@ -42,6 +45,10 @@ func (c *Config) setValue() {
 	c.DeleteGauges = viper.GetBool("statsd.deleteGauges")
 	c.DeleteSets = viper.GetBool("statsd.deleteSets")
 	c.DeleteTimings = viper.GetBool("statsd.deleteTimings")
+	c.TLS.SetValue("statsd")
+	if c.TLS.Enable && strings.HasPrefix(c.Protocol, "udp") {
+		panic("statsd.tls needs statsd.protocol tcp")
+	}
 }
 
 func init() {
@@ -104,4 +111,6 @@ func init() {
 	_ = viper.BindEnv("statsd.deleteTimings", "BLM_STATSD_DELETE_TIMINGS")
 	pflag.Bool("statsd.deleteTimings", true, `statsd delete timing cache after gather. Env "BLM_STATSD_DELETE_TIMINGS"`)
 	viper.SetDefault("statsd.deleteTimings", true)
+
+	config.InitListenerTLS("statsd", "BLM_STATSD")
 }
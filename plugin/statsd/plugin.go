@@ -1,7 +1,9 @@
 package statsd
 
 import (
+	"crypto/tls"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -10,43 +12,95 @@ import (
 	"github.com/influxdata/telegraf/plugins/inputs/statsd"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/sirupsen/logrus"
+	"github.com/taosdata/blm3/config"
 	"github.com/taosdata/blm3/db/commonpool"
+	"github.com/taosdata/blm3/db/writebuffer"
 	"github.com/taosdata/blm3/log"
 	"github.com/taosdata/blm3/plugin"
+	"github.com/taosdata/blm3/schemaless/batch"
 	"github.com/taosdata/blm3/schemaless/capi"
+	"github.com/taosdata/blm3/tools/certauth"
+	"github.com/taosdata/blm3/tools/monitor"
 )
 
 var logger = log.GetLogger("statsd")
 
+// drainTimeout bounds the wait for the statsd input to parse its pending packets.
+const drainTimeout = 5 * time.Second
+
+// drainQuiet is how long the statsd input must stay idle before its pending packets are considered parsed.
+const drainQuiet = 100 * time.Millisecond
+
 type Plugin struct {
+	plugin.State
 	conf       Config
 	ac         telegraf.Accumulator
 	input      *statsd.Statsd
+	proxy      *tlsProxy
 	closeChan  chan struct{}
 	metricChan chan telegraf.Metric
+	wg         sync.WaitGroup
+	workers    sync.WaitGroup
+	writer     *writebuffer.Writer
+	batcher    *batch.Batcher
+	key        batch.Key
 }
 
 func (p *Plugin) Init(_ gin.IRouter) error {
 	p.conf.setValue()
+	p.prepare()
 	if !p.conf.Enable {
 		logger.Info("statsd disabled")
+	}
+	return nil
+}
+
+func (p *Plugin) prepare() {
+	p.SetEnabled(p.conf.Enable)
+	p.key = batch.Key{DB: p.conf.DB, Protocol: "influxdb", User: p.conf.User}
+}
+
+// Reload reads the configuration, the statsd input is created again with it on the next Start.
+func (p *Plugin) Reload() ([]string, func() error) {
+	var conf Config
+	conf.setValue()
+	return config.Diff("statsd", p.conf, conf), func() error {
+		p.conf = conf
+		p.prepare()
 		return nil
 	}
+}
+
+func (p *Plugin) Start() error {
+	if !p.conf.Enable {
+		return nil
+	}
+	var tlsConfig *tls.Config
+	var err error
+	if p.conf.TLS.Enable {
+		tlsConfig, err = certauth.ListenerConfig(&p.conf.TLS, &config.Conf.SSl)
+		if err != nil {
+			return err
+		}
+	}
+	p.writer, err = writebuffer.NewWriter(&config.Conf.WriteBuffer, "statsd", p.conf.DB, p.insert)
+	if err != nil {
+		return err
+	}
+	p.batcher = batch.New(&config.Conf.Batch, p.conf.Worker, p.flush)
+	p.closeChan = make(chan struct{})
 	p.metricChan = make(chan telegraf.Metric, 2*p.conf.Worker)
 	for i := 0; i < p.conf.Worker; i++ {
-		go func() {
+		p.workers.Add(1)
+		go func(metricChan chan telegraf.Metric) {
+			defer p.workers.Done()
 			serializer := influx.NewSerializer()
-			for {
-				select {
-				case metric := <-p.metricChan:
-					p.HandleMetrics(serializer, metric)
-				case <-p.closeChan:
-					return
-				}
+			for metric := range metricChan {
+				p.HandleMetrics(serializer, metric)
 			}
-		}()
+		}(p.metricChan)
 	}
-	p.input = &statsd.Statsd{
+	input := &statsd.Statsd{
 		Protocol:               p.conf.Protocol,
 		ServiceAddress:         fmt.Sprintf(":%d", p.conf.Port),
 		MaxTCPConnections:      p.conf.MaxTCPConnections,
@@ -58,45 +112,85 @@ func (p *Plugin) Init(_ gin.IRouter) error {
 		DeleteTimings:          p.conf.DeleteTimings,
 		Log:                    logger,
 	}
-	p.ac = agent.NewAccumulator(&MetricMaker{logger: logger}, p.metricChan)
-	return nil
-}
-
-func (p *Plugin) Start() error {
-	if !p.conf.Enable {
-		return nil
+	if tlsConfig != nil {
+		// the input listens on the loopback behind the tls proxy
+		input.ServiceAddress = "127.0.0.1:0"
 	}
-	err := p.input.Start(p.ac)
+	p.ac = agent.NewAccumulator(&MetricMaker{logger: logger}, p.metricChan)
+	err = input.Start(p.ac)
 	if err != nil {
 		return err
 	}
-	p.closeChan = make(chan struct{})
-	ticker := time.NewTicker(p.conf.GatherInterval)
-	go func() {
+	p.input = input
+	if tlsConfig != nil {
+		target := input.TCPlistener.Addr().String()
+		p.proxy, err = newTLSProxy(fmt.Sprintf(":%d", p.conf.Port), target, tlsConfig, p.conf.TCPKeepAlive)
+		if err != nil {
+			return err
+		}
+		logger.Warnf("statsd input listens in plaintext on %q, local processes can write to it without tls", target)
+	}
+	p.SetListening(true)
+	p.wg.Add(1)
+	go func(closeChan chan struct{}) {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.conf.GatherInterval)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
-				err := p.input.Gather(p.ac)
+				err := input.Gather(p.ac)
 				if err != nil {
 					logger.WithError(err).Error("gather error")
 				}
-			case <-p.closeChan:
-				ticker.Stop()
-				ticker = nil
+			case <-closeChan:
 				return
 			}
 		}
-
-	}()
+	}(p.closeChan)
 	return nil
 }
 
+// Stop stops listening and writes the aggregated and queued metrics before it returns, it is also called after a
+// failed Start.
 func (p *Plugin) Stop() error {
 	if !p.conf.Enable {
 		return nil
 	}
-	p.input.Stop()
-	close(p.closeChan)
+	if p.closeChan != nil {
+		close(p.closeChan)
+		p.closeChan = nil
+	}
+	p.wg.Wait()
+	if p.proxy != nil {
+		p.proxy.Close()
+		p.proxy = nil
+	}
+	if p.input != nil {
+		drainInput(p.input)
+		p.input.Stop()
+		// the last interval has not been gathered yet
+		err := p.input.Gather(p.ac)
+		if err != nil {
+			logger.WithError(err).Error("gather error")
+		}
+		p.input = nil
+	}
+	p.SetListening(false)
+	if p.metricChan != nil {
+		close(p.metricChan)
+		p.metricChan = nil
+	}
+	p.workers.Wait()
+	if p.batcher != nil {
+		p.batcher.Close()
+		p.batcher = nil
+	}
+	if p.writer != nil {
+		err := p.writer.Close()
+		p.writer = nil
+		return err
+	}
 	return nil
 }
 
@@ -114,10 +208,29 @@ func (p *Plugin) HandleMetrics(serializer *influx.Serializer, metric telegraf.Me
 		logger.WithError(err).Error("serialize statsd error")
 		return
 	}
+	lines := monitor.CountLines(data)
+	monitor.AddPoints(p.String(), monitor.PointsReceived, lines)
+	err = p.batcher.Add(p.key, data)
+	if err != nil {
+		monitor.AddPoints(p.String(), monitor.PointsDropped, lines)
+		logger.WithError(err).Errorln("add lines error", string(data))
+	}
+}
+
+func (p *Plugin) flush(_ batch.Key, data []byte, lines int) {
+	err := p.writer.Write(data)
+	if err != nil {
+		monitor.AddPoints(p.String(), monitor.PointsDropped, lines)
+		p.WriteFailed(err)
+		logger.WithError(err).Errorf("insert %d lines error", lines)
+	}
+}
+
+func (p *Plugin) insert(data []byte) error {
 	taosConn, err := commonpool.GetConnection(p.conf.User, p.conf.Password)
 	if err != nil {
-		logger.WithError(err).Errorln("connect taosd error")
-		return
+		p.WriteFailed(err)
+		return err
 	}
 	defer func() {
 		putErr := taosConn.Put()
@@ -130,12 +243,54 @@ func (p *Plugin) HandleMetrics(serializer *influx.Serializer, metric telegraf.Me
 	logger.Debugln(start, "insert line", string(data))
 	result, err := capi.InsertInfluxdb(taosConn.TaosConnection, data, p.conf.DB, "ns")
 	logger.Debugln("insert line finish cost:", time.Now().Sub(start), string(data))
-	if err != nil || result.FailCount != 0 {
-		logger.WithError(err).WithField("result", result).Errorln("insert lines error", string(data))
-		return
+	if err != nil {
+		p.WriteFailed(err)
+		return err
+	}
+	monitor.AddPoints(p.String(), monitor.PointsInserted, result.SuccessCount)
+	monitor.AddPoints(p.String(), monitor.PointsFailed, result.FailCount)
+	if result.SuccessCount != 0 {
+		p.WriteSucceeded()
+	}
+	if result.FailCount != 0 {
+		p.WriteFailed(result.Errors[0])
+		logger.WithField("result", result).Errorln("insert lines error", string(data))
+	}
+	return nil
+}
+
+// drainInput closes the listener of the statsd input and waits until it has parsed the received packets, the input
+// discards its pending packets when it is stopped. The queue of the input is not exported, the wait ends when the tcp
+// connections are closed and the receive and parse stats of the input have not changed for drainQuiet.
+func drainInput(input *statsd.Statsd) {
+	if input.UDPlistener != nil {
+		_ = input.UDPlistener.Close()
+	}
+	if input.TCPlistener != nil {
+		_ = input.TCPlistener.Close()
+	}
+	deadline := time.Now().Add(drainTimeout)
+	last := inputActivity(input)
+	quietSince := time.Now()
+	for time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		activity := inputActivity(input)
+		if activity != last || input.CurrentConnections.Get() != 0 {
+			last = activity
+			quietSince = time.Now()
+			continue
+		}
+		if time.Since(quietSince) >= drainQuiet {
+			return
+		}
 	}
 }
 
+// inputActivity sums the exported stats of the statsd input which change while it receives and parses packets.
+func inputActivity(input *statsd.Statsd) int64 {
+	return input.TCPPacketsRecv.Get() + input.UDPPacketsRecv.Get() + input.UDPPacketsDrop.Get() + input.ParseTimeNS.Get()
+}
+
 type MetricMaker struct {
 	logger logrus.FieldLogger
 }
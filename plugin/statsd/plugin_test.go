@@ -0,0 +1,46 @@
+package statsd
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/agent"
+	"github.com/influxdata/telegraf/plugins/inputs/statsd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainInput(t *testing.T) {
+	input := &statsd.Statsd{
+		Protocol:               "tcp",
+		ServiceAddress:         "127.0.0.1:0",
+		MaxTCPConnections:      10,
+		AllowedPendingMessages: 10000,
+		DeleteCounters:         true,
+		Log:                    logger,
+	}
+	metricChan := make(chan telegraf.Metric, 10)
+	ac := agent.NewAccumulator(&MetricMaker{logger: logger}, metricChan)
+	assert.NoError(t, input.Start(ac))
+	conn, err := net.Dial("tcp", input.TCPlistener.Addr().String())
+	assert.NoError(t, err)
+	for i := 0; i < 5000; i++ {
+		_, err = fmt.Fprintf(conn, "requests:1|c\n")
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, conn.Close())
+
+	drainInput(input)
+	input.Stop()
+	assert.NoError(t, input.Gather(ac))
+	close(metricChan)
+	var count interface{}
+	for metric := range metricChan {
+		if metric.Name() == "requests" {
+			count, _ = metric.GetField("value")
+		}
+	}
+	// the packets queued before the stop are parsed
+	assert.Equal(t, int64(5000), count)
+}
@@ -0,0 +1,105 @@
+package statsd
+
+import (
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+)
+
+// tlsProxy terminates tls on the statsd port and forwards the plain stream to the statsd input listening on the
+// loopback, the input only accepts tcp connections of its own listener. The loopback port is not protected by tls,
+// local processes can write to it without a client certificate.
+type tlsProxy struct {
+	listener  *net.TCPListener
+	config    *tls.Config
+	target    string
+	keepAlive bool
+	wg        sync.WaitGroup
+	lock      sync.Mutex
+	closed    bool
+	conns     map[net.Conn]struct{}
+}
+
+func newTLSProxy(address, target string, config *tls.Config, keepAlive bool) (*tlsProxy, error) {
+	addr, err := net.ResolveTCPAddr("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.ListenTCP("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("TCP listening with tls on %q", listener.Addr().String())
+	p := &tlsProxy{
+		listener:  listener,
+		config:    config,
+		target:    target,
+		keepAlive: keepAlive,
+		conns:     map[net.Conn]struct{}{},
+	}
+	p.wg.Add(1)
+	go p.serve()
+	return p, nil
+}
+
+func (p *tlsProxy) serve() {
+	defer p.wg.Done()
+	for {
+		conn, err := p.listener.AcceptTCP()
+		if err != nil {
+			return
+		}
+		if p.keepAlive {
+			_ = conn.SetKeepAlive(true)
+		}
+		client := tls.Server(conn, p.config)
+		p.lock.Lock()
+		p.conns[client] = struct{}{}
+		p.lock.Unlock()
+		p.wg.Add(1)
+		go p.forward(client)
+	}
+}
+
+func (p *tlsProxy) forward(client net.Conn) {
+	defer func() {
+		client.Close()
+		p.lock.Lock()
+		delete(p.conns, client)
+		p.lock.Unlock()
+		p.wg.Done()
+	}()
+	backend, err := net.Dial("tcp", p.target)
+	if err != nil {
+		logger.WithError(err).Error("connect statsd input error")
+		return
+	}
+	defer backend.Close()
+	go func() {
+		// the input closes refused connections, which ends the client
+		_, _ = io.Copy(ioutil.Discard, backend)
+		client.Close()
+	}()
+	_, err = io.Copy(backend, client)
+	p.lock.Lock()
+	closed := p.closed
+	p.lock.Unlock()
+	if err != nil && !closed {
+		logger.WithError(err).Warn("statsd tls connection error")
+	}
+}
+
+// Close stops accepting connections and closes the open ones, the data read from them has been forwarded when it
+// returns.
+func (p *tlsProxy) Close() {
+	_ = p.listener.Close()
+	p.lock.Lock()
+	p.closed = true
+	for conn := range p.conns {
+		conn.Close()
+	}
+	p.lock.Unlock()
+	p.wg.Wait()
+}
@@ -0,0 +1,77 @@
+package statsd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTLSProxy(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer backend.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := backend.Accept()
+		if err != nil {
+			return
+		}
+		data, _ := ioutil.ReadAll(conn)
+		conn.Close()
+		received <- string(data)
+	}()
+
+	proxy, err := newTLSProxy("127.0.0.1:0", backend.Addr().String(), &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}, false)
+	assert.NoError(t, err)
+	defer proxy.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	conn, err := tls.Dial("tcp", proxy.listener.Addr().String(), &tls.Config{RootCAs: roots, ServerName: "localhost"})
+	assert.NoError(t, err)
+	_, err = conn.Write([]byte("requests:1|c\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, conn.Close())
+	select {
+	case data := <-received:
+		assert.Equal(t, "requests:1|c\n", data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no data forwarded")
+	}
+
+	// a plain connection fails the handshake and forwards nothing
+	plain, err := net.Dial("tcp", proxy.listener.Addr().String())
+	assert.NoError(t, err)
+	_, _ = plain.Write([]byte("requests:1|c\n"))
+	_ = plain.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = ioutil.ReadAll(plain)
+	assert.NoError(t, err)
+	plain.Close()
+}
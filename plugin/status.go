@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"sync"
+	"time"
+)
+
+// Status is the state of a plugin reported by the health check.
+type Status struct {
+	// State is the lifecycle state and Error the error of the last failed init or start
+	State         string     `json:"state"`
+	Error         string     `json:"error,omitempty"`
+	Enabled       bool       `json:"enabled"`
+	Listening     bool       `json:"listening"`
+	LastWrite     *time.Time `json:"lastWrite,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorTime *time.Time `json:"lastErrorTime,omitempty"`
+}
+
+// StatusReporter is implemented by the plugins which report their Status.
+type StatusReporter interface {
+	Status() Status
+}
+
+// State records the Status of a plugin, plugins embed it to implement StatusReporter.
+type State struct {
+	lock   sync.RWMutex
+	status Status
+}
+
+func (s *State) SetEnabled(enabled bool) {
+	s.lock.Lock()
+	s.status.Enabled = enabled
+	s.lock.Unlock()
+}
+
+// SetListening records whether the plugin accepts data, plugins served by the http server listen once enabled.
+func (s *State) SetListening(listening bool) {
+	s.lock.Lock()
+	s.status.Listening = listening
+	s.lock.Unlock()
+}
+
+// WriteSucceeded records a write of at least one point.
+func (s *State) WriteSucceeded() {
+	now := time.Now()
+	s.lock.Lock()
+	s.status.LastWrite = &now
+	s.lock.Unlock()
+}
+
+// WriteFailed records the error of a write which lost points.
+func (s *State) WriteFailed(err error) {
+	now := time.Now()
+	s.lock.Lock()
+	s.status.LastError = err.Error()
+	s.status.LastErrorTime = &now
+	s.lock.Unlock()
+}
+
+func (s *State) Status() Status {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.status
+}
+
+// Statuses returns the Status of the registered plugins by name.
+func Statuses() map[string]Status {
+	result := make(map[string]Status, len(plugins))
+	for name, e := range plugins {
+		var status Status
+		if reporter, ok := e.plugin.(StatusReporter); ok {
+			status = reporter.Status()
+		}
+		e.lock.RLock()
+		status.State = e.state
+		if e.err != nil {
+			status.Error = e.err.Error()
+		}
+		e.lock.RUnlock()
+		result[name] = status
+	}
+	return result
+}
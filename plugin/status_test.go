@@ -0,0 +1,23 @@
+package plugin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestState(t *testing.T) {
+	var s State
+	assert.Equal(t, Status{}, s.Status())
+	s.SetEnabled(true)
+	s.SetListening(true)
+	s.WriteSucceeded()
+	s.WriteFailed(errors.New("invalid line"))
+	status := s.Status()
+	assert.True(t, status.Enabled)
+	assert.True(t, status.Listening)
+	assert.NotNil(t, status.LastWrite)
+	assert.Equal(t, "invalid line", status.LastError)
+	assert.NotNil(t, status.LastErrorTime)
+}
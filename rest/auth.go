@@ -1,8 +1,6 @@
 package rest
 
 import (
-	"crypto/des"
-	"encoding/base64"
 	"errors"
 	"net/http"
 	"strings"
@@ -12,105 +10,41 @@ import (
 	"github.com/patrickmn/go-cache"
 	"github.com/taosdata/blm3/httperror"
 	"github.com/taosdata/blm3/tools"
-	"github.com/taosdata/blm3/tools/pool"
+	"github.com/taosdata/blm3/tools/certauth"
+	"github.com/taosdata/blm3/tools/login"
+	"github.com/taosdata/blm3/tools/token"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
 )
 
 var authCache = cache.New(30*time.Minute, time.Hour)
-var tokenCache = cache.New(30*time.Minute, time.Hour)
 
 type authInfo struct {
 	User     string
 	Password string
 }
 
-var desKey = []byte{
-	64,
-	182,
-	122,
-	48,
-	86,
-	115,
-	253,
-	68,
-}
-
-func DecodeDes(auth string) (user, password string, err error) {
-	d, err := base64.StdEncoding.DecodeString(auth)
-	if err != nil {
-		return "", "", err
-	}
-	if len(d) != 48 {
-		return "", "", errors.New("wrong des length")
-	}
-	block, err := des.NewCipher(desKey)
-	if err != nil {
-		return "", "", err
-	}
-	b := pool.BytesPoolGet()
-	defer pool.BytesPoolPut(b)
-	for i := 0; i < 6; i++ {
-		origData := make([]byte, 8)
-		block.Decrypt(origData, d[i*8:+(i+1)*8])
-		b.Write(origData)
-		if i == 2 {
-			user, err = b.ReadString(0)
-			if err == nil {
-				user = user[:len(user)-1]
-			}
-			b.Reset()
-		}
-	}
-	password, err = b.ReadString(0)
-	if err == nil {
-		password = password[:len(password)-1]
-	}
-	return user, password, nil
-}
-
-func EncodeDes(user, password string) (string, error) {
-	if len(user) > 24 || len(password) > 24 {
-		return "", errors.New("wrong user or password length")
-	}
-
-	b := make([]byte, 48)
-	for i := 0; i < len(user); i++ {
-		b[i] = user[i]
-	}
-	for i := 0; i < len(password); i++ {
-		b[i+24] = password[i]
-	}
-	v, exist := tokenCache.Get(string(b))
-	if exist {
-		return v.(string), nil
-	}
-	block, err := des.NewCipher(desKey)
-	if err != nil {
-		return "", err
-	}
-	buf := pool.BytesPoolGet()
-	defer pool.BytesPoolPut(buf)
-	for i := 0; i < 6; i++ {
-		d := make([]byte, 8)
-		block.Encrypt(d, b[i*8:(i+1)*8])
-		buf.Write(d)
-	}
-	data := base64.StdEncoding.EncodeToString(buf.Bytes())
-	tokenCache.SetDefault(string(b), data)
-	return data, nil
-}
-
 const (
 	UserKey     = "user"
 	PasswordKey = "password"
+	claimsKey   = "claims"
 )
 
 func checkAuth(c *gin.Context) {
 	auth := c.GetHeader("Authorization")
 	if len(auth) == 0 {
+		if user, password, ok := certauth.User(c.Request); ok {
+			c.Set(UserKey, user)
+			c.Set(PasswordKey, password)
+			return
+		}
 		errorResponse(c, httperror.HTTP_NO_AUTH_INFO)
 		return
 	}
 	auth = strings.TrimSpace(auth)
+	if strings.HasPrefix(auth, "Taosd") {
+		checkToken(c, strings.TrimSpace(auth[5:]))
+		return
+	}
 	v, exist := authCache.Get(auth)
 	if exist {
 		info := v.(*authInfo)
@@ -128,20 +62,10 @@ func checkAuth(c *gin.Context) {
 			errorResponse(c, httperror.HTTP_INVALID_BASIC_AUTH)
 			return
 		}
-		authCache.SetDefault(auth, &authInfo{
-			User:     user,
-			Password: password,
-		})
-		c.Set(UserKey, user)
-		c.Set(PasswordKey, password)
-	} else if strings.HasPrefix(auth, "Taosd") {
-		user, password, err := DecodeDes(auth[6:])
+		// only credentials accepted by taosd are cached
+		err = login.Authenticate(c, "basic auth", user, password)
 		if err != nil {
-			errorResponse(c, httperror.HTTP_INVALID_BASIC_AUTH)
-			return
-		}
-		if len(user) == 0 || len(password) == 0 {
-			errorResponse(c, httperror.HTTP_INVALID_BASIC_AUTH)
+			authFailedResponse(c, err)
 			return
 		}
 		authCache.SetDefault(auth, &authInfo{
@@ -156,6 +80,40 @@ func checkAuth(c *gin.Context) {
 	}
 }
 
+// checkToken checks a signed token or with token.legacy a legacy token, signed tokens are never cached as they can
+// expire or be revoked, the legacy tokens are checked before the cache as token.legacy can be turned off by a reload.
+func checkToken(c *gin.Context, auth string) {
+	user, password, claims, err := token.Decode(auth, token.ScopeRest)
+	if err != nil {
+		errorResponseWithMsg(c, httperror.HTTP_INVALID_TAOSD_AUTH, err.Error())
+		return
+	}
+	if claims != nil {
+		c.Set(UserKey, user)
+		c.Set(PasswordKey, password)
+		c.Set(claimsKey, claims)
+		return
+	}
+	key := "Taosd " + auth
+	if v, exist := authCache.Get(key); exist {
+		info := v.(*authInfo)
+		c.Set(UserKey, info.User)
+		c.Set(PasswordKey, info.Password)
+		return
+	}
+	err = login.Authenticate(c, "taosd auth", user, password)
+	if err != nil {
+		authFailedResponse(c, err)
+		return
+	}
+	authCache.SetDefault(key, &authInfo{
+		User:     user,
+		Password: password,
+	})
+	c.Set(UserKey, user)
+	c.Set(PasswordKey, password)
+}
+
 type Message struct {
 	Status string `json:"status"`
 	Code   int    `json:"code"`
@@ -174,6 +132,15 @@ func errorResponse(c *gin.Context, code int) {
 	})
 }
 
+// limitErrorResponse answers a request rejected by the rate limits with the http status of the limiter.
+func limitErrorResponse(c *gin.Context, code int, err error) {
+	c.AbortWithStatusJSON(code, &Message{
+		Status: "error",
+		Code:   httperror.HTTP_RATE_LIMITED,
+		Desc:   err.Error(),
+	})
+}
+
 func errorResponseWithMsg(c *gin.Context, code int, msg string) {
 	c.AbortWithStatusJSON(http.StatusOK, &Message{
 		Status: "error",
@@ -181,3 +148,21 @@ func errorResponseWithMsg(c *gin.Context, code int, msg string) {
 		Desc:   msg,
 	})
 }
+
+// authFailedResponse answers a request whose credentials login.Authenticate refused or could not check.
+func authFailedResponse(c *gin.Context, err error) {
+	switch err {
+	case login.ErrLockedOut:
+		errorResponseWithMsg(c, httperror.HTTP_LOGIN_FAILED, err.Error())
+		return
+	case login.ErrRejected:
+		errorResponse(c, httperror.TSDB_CODE_RPC_AUTH_FAILURE)
+		return
+	}
+	var tError *tErrors.TaosError
+	if errors.As(err, &tError) {
+		errorResponseWithMsg(c, int(tError.Code), tError.ErrStr)
+	} else {
+		errorResponseWithMsg(c, 0xffff, err.Error())
+	}
+}
@@ -1,101 +1,110 @@
 package rest
 
 import (
-	"fmt"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/blm3/httperror"
+	"github.com/taosdata/blm3/tools/login"
+	"github.com/taosdata/blm3/tools/token"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
 )
 
-func TestEncodeDes(t *testing.T) {
-	type args struct {
-		user     string
-		password string
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    string
-		wantErr bool
-	}{
-		{
-			name: "test",
-			args: args{
-				user:     "root",
-				password: "taosdata",
-			},
-			want:    "/KfeAzX/f9na8qdtNZmtONryp201ma04bEl8LcvLUd7a8qdtNZmtONryp201ma04",
-			wantErr: false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := EncodeDes(tt.args.user, tt.args.password)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("EncodeDes() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("EncodeDes() got = %v, want %v", got, tt.want)
-			}
-		})
+func authRequest(method, path, auth string) *Message {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, nil)
+	if len(auth) != 0 {
+		req.Header.Set("Authorization", auth)
 	}
+	router.ServeHTTP(w, req)
+	var msg Message
+	_ = json.Unmarshal(w.Body.Bytes(), &msg)
+	return &msg
 }
 
-func TestDecodeDes(t *testing.T) {
-	type args struct {
-		auth string
-	}
-	tests := []struct {
-		name         string
-		args         args
-		wantUser     string
-		wantPassword string
-		wantErr      bool
-	}{
-		{
-			name: "test",
-			args: args{
-				auth: "/KfeAzX/f9na8qdtNZmtONryp201ma04bEl8LcvLUd7a8qdtNZmtONryp201ma04",
-			},
-			wantUser:     "root",
-			wantPassword: "taosdata",
-			wantErr:      false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			gotUser, gotPassword, err := DecodeDes(tt.args.auth)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("DecodeDes() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if gotUser != tt.wantUser {
-				fmt.Println(len(gotUser))
-				t.Errorf("DecodeDes() gotUser = %v, want %v", gotUser, tt.wantUser)
-			}
-			if gotPassword != tt.wantPassword {
-				t.Errorf("DecodeDes() gotPassword = %v, want %v", gotPassword, tt.wantPassword)
-			}
-		})
-	}
+func TestLoginLogout(t *testing.T) {
+	msg := authRequest(http.MethodGet, "/rest/login/root/wrong", "")
+	assert.Equal(t, httperror.TSDB_CODE_RPC_AUTH_FAILURE, msg.Code)
+	msg = authRequest(http.MethodGet, "/rest/login/root/taosdata?scope=admin", "")
+	assert.Equal(t, httperror.HTTP_GEN_TAOSD_TOKEN_ERR, msg.Code)
+
+	msg = authRequest(http.MethodGet, "/rest/login/root/taosdata?scope=plugin", "")
+	assert.Equal(t, "succ", msg.Status)
+	msg = authRequest(http.MethodPost, "/rest/logout", "Taosd "+msg.Desc)
+	assert.Equal(t, httperror.HTTP_INVALID_TAOSD_AUTH, msg.Code)
+	assert.Equal(t, "token scope not allowed", msg.Desc)
+
+	msg = authRequest(http.MethodGet, "/rest/login/root/taosdata", "")
+	assert.Equal(t, "succ", msg.Status)
+	auth := "Taosd " + msg.Desc
+	assert.Equal(t, "succ", authRequest(http.MethodPost, "/rest/logout", auth).Status)
+	msg = authRequest(http.MethodPost, "/rest/logout", auth)
+	assert.Equal(t, "token revoked", msg.Desc)
+
+	legacy, err := token.EncodeDes("root", "taosdata")
+	assert.NoError(t, err)
+	msg = authRequest(http.MethodPost, "/rest/sql", "Taosd "+legacy)
+	assert.Equal(t, httperror.HTTP_INVALID_TAOSD_AUTH, msg.Code)
+	config.Conf.Token.Legacy = true
+	defer func() {
+		config.Conf.Token.Legacy = false
+	}()
+	msg = authRequest(http.MethodGet, "/rest/login/root/taosdata", "")
+	assert.Equal(t, legacy, msg.Desc)
+	msg = authRequest(http.MethodPost, "/rest/logout", "Taosd "+legacy)
+	assert.Equal(t, "logout needs a token", msg.Desc)
 }
 
-func BenchmarkEncodeDes(b *testing.B) {
-	for i := 0; i < b.N; i++ {
-		s, err := EncodeDes("root", "taosdata")
-		if err != nil {
-			b.Error(err)
-			return
+func TestLockout(t *testing.T) {
+	defer func(f func(user, password string) error) {
+		login.VerifyUser = f
+	}(login.VerifyUser)
+	calls := 0
+	login.VerifyUser = func(user, password string) error {
+		calls += 1
+		if password == "unavailable" {
+			return &tErrors.TaosError{Code: tErrors.RPC_NETWORK_UNAVAIL, ErrStr: "Unable to establish connection"}
+		}
+		if password != "taosdata" {
+			return &tErrors.TaosError{Code: tErrors.RPC_AUTH_FAILURE, ErrStr: "Authentication failure"}
 		}
-		_ = s
+		return nil
 	}
-}
-
-func BenchmarkDecodeDes(b *testing.B) {
-	for i := 0; i < b.N; i++ {
-		_, _, err := DecodeDes("/KfeAzX/f9na8qdtNZmtONryp201ma04bEl8LcvLUd7a8qdtNZmtONryp201ma04")
-		if err != nil {
-			b.Error(err)
-			return
+	request := func(method, path, ip, auth string) *Message {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":6041"
+		if len(auth) != 0 {
+			req.Header.Set("Authorization", auth)
 		}
+		router.ServeHTTP(w, req)
+		var msg Message
+		_ = json.Unmarshal(w.Body.Bytes(), &msg)
+		return &msg
+	}
+	for i := 0; i < config.Conf.Login.MaxFailures; i++ {
+		assert.Equal(t, httperror.TSDB_CODE_RPC_AUTH_FAILURE, request(http.MethodGet, "/rest/login/lock/wrong", "10.0.0.1", "").Code)
+	}
+	// the refused credentials are not sent to taosd again
+	assert.Equal(t, 1, calls)
+	msg := request(http.MethodGet, "/rest/login/other/taosdata", "10.0.0.1", "")
+	assert.Equal(t, httperror.HTTP_LOGIN_FAILED, msg.Code)
+	assert.Equal(t, login.ErrLockedOut.Error(), msg.Desc)
+	assert.Equal(t, "succ", request(http.MethodGet, "/rest/login/lock/taosdata", "10.0.0.2", "").Status)
+
+	// errors other than refused credentials are returned as they are
+	msg = request(http.MethodGet, "/rest/login/lock/unavailable", "10.0.0.4", "")
+	assert.Equal(t, int(tErrors.RPC_NETWORK_UNAVAIL), msg.Code)
+
+	// invalid basic auth is not cached and counts as a failure
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("basic:wrong"))
+	for i := 0; i < config.Conf.Login.MaxFailures; i++ {
+		assert.Equal(t, httperror.TSDB_CODE_RPC_AUTH_FAILURE, request(http.MethodPost, "/rest/sql", "10.0.0.3", basic).Code)
 	}
+	assert.Equal(t, httperror.HTTP_LOGIN_FAILED, request(http.MethodGet, "/rest/login/basic/taosdata", "10.0.0.3", "").Code)
 }
@@ -0,0 +1,185 @@
+package rest
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taosdata/blm3/db/async"
+	"github.com/taosdata/blm3/httperror"
+	"github.com/taosdata/blm3/tools/web"
+	"github.com/taosdata/driver-go/v2/wrapper"
+)
+
+type BatchResp struct {
+	Status  string        `json:"status"`
+	Results []interface{} `json:"results"`
+}
+
+// batch executes a json array of sql statements in order on one connection. With query parameter
+// stopOnError=true the statements after the first failed one are not executed.
+func (ctl *Restful) batch(c *gin.Context) {
+	db := c.Param("db")
+	timeFunc, ok := timeFuncOrError(c)
+	if !ok {
+		return
+	}
+	ctl.doBatch(c, db, timeFunc)
+}
+
+func (ctl *Restful) doBatch(c *gin.Context, db string, timeFunc wrapper.FormatTimeFunc) {
+	id := web.GetRequestID(c)
+	logger := logger.WithField("sessionID", id)
+	b, err := c.GetRawData()
+	if err != nil {
+		logger.WithError(err).Error("get request body error")
+		errorResponse(c, httperror.HTTP_INVALID_CONTENT_LENGTH)
+		return
+	}
+	if len(b) == 0 {
+		logger.Errorln("no msg got")
+		errorResponse(c, httperror.HTTP_NO_MSG_INPUT)
+		return
+	}
+	var sqls []string
+	err = json.Unmarshal(b, &sqls)
+	if err != nil {
+		logger.WithError(err).Errorln("unmarshal batch request error")
+		errorResponse(c, httperror.HTTP_REQUEST_JSON_ERROR)
+		return
+	}
+	if len(sqls) == 0 {
+		logger.Errorln("no sql got")
+		errorResponse(c, httperror.HTTP_INVALID_MULTI_REQUEST)
+		return
+	}
+	stopOnError := false
+	if s := c.Query("stopOnError"); len(s) != 0 {
+		stopOnError, err = strconv.ParseBool(s)
+		if err != nil {
+			logger.WithError(err).Errorln("invalid stopOnError")
+			errorResponseWithMsg(c, httperror.HTTP_INVALID_URL, "invalid stopOnError "+s)
+			return
+		}
+	}
+	maxRows, timeout, err := queryLimit(c)
+	if err != nil {
+		logger.WithError(err).Errorln("invalid query limit")
+		errorResponseWithMsg(c, httperror.HTTP_INVALID_QUERY_LIMIT, err.Error())
+		return
+	}
+	taosConnect := prepareConnection(c, logger, db)
+	if taosConnect == nil {
+		return
+	}
+	// a statement abandoned on the timeout returns the connection once its result is freed
+	var running sync.WaitGroup
+	release := releaseConnection(logger, taosConnect)
+	defer func() {
+		go func() {
+			running.Wait()
+			release()
+		}()
+	}()
+	ctx := c.Request.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	resp := &BatchResp{
+		Status:  "succ",
+		Results: make([]interface{}, 0, len(sqls)),
+	}
+	for i, sql := range sqls {
+		if ctx.Err() == context.Canceled {
+			// the client has gone away, nobody reads the results of the remaining statements
+			logger.Warnf("request canceled, %d statements not executed", len(sqls)-i)
+			return
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			// the statements after the deadline are not sent to taosd
+			resp.Status = "error"
+			for range sqls[i:] {
+				resp.Results = append(resp.Results, &Message{
+					Status: "error",
+					Code:   httperror.HTTP_NOT_EXECUTED,
+					Desc:   httperror.ErrorMsgMap[httperror.HTTP_NOT_EXECUTED],
+				})
+			}
+			break
+		}
+		sql = strings.TrimSpace(sql)
+		if len(sql) == 0 {
+			resp.Status = "error"
+			resp.Results = append(resp.Results, &Message{
+				Status: "error",
+				Code:   httperror.HTTP_NO_SQL_INPUT,
+				Desc:   httperror.ErrorMsgMap[httperror.HTTP_NO_SQL_INPUT],
+			})
+			if stopOnError {
+				break
+			}
+			continue
+		}
+		startExec := time.Now()
+		logger.Debugln(startExec, "start execute sql:", sql)
+		var data [][]driver.Value
+		running.Add(1)
+		result, err := async.GlobalAsync.TaosExecStream(ctx, taosConnect.TaosConnection, sql, timeFunc, maxRows, nil, func(rows [][]driver.Value) error {
+			data = append(data, rows...)
+			return nil
+		}, running.Done)
+		logger.Debugln("execute sql cost:", time.Now().Sub(startExec))
+		if err != nil {
+			logger.WithError(err).Errorln("execute sql error:", sql)
+			code, desc := queryError(err)
+			resp.Status = "error"
+			resp.Results = append(resp.Results, &Message{
+				Status: "error",
+				Code:   code & 0xffff,
+				Desc:   desc,
+			})
+			if stopOnError {
+				break
+			}
+			continue
+		}
+		result.Data = data
+		resp.Results = append(resp.Results, newRestfulResp(result))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func newRestfulResp(result *async.ExecResult) *TDEngineRestfulResp {
+	if result.FieldCount == 0 {
+		return &TDEngineRestfulResp{
+			Status:     "succ",
+			Head:       []string{"affected_rows"},
+			Data:       [][]driver.Value{{result.AffectedRows}},
+			ColumnMeta: [][]interface{}{{"affected_rows", 4, 4}},
+			Rows:       1,
+		}
+	}
+	var columnMeta [][]interface{}
+	for i := 0; i < len(result.Header.ColNames); i++ {
+		columnMeta = append(columnMeta, []interface{}{
+			result.Header.ColNames[i],
+			result.Header.ColTypes[i],
+			result.Header.ColLength[i],
+		})
+	}
+	return &TDEngineRestfulResp{
+		Status:     "succ",
+		Head:       result.Header.ColNames,
+		Data:       result.Data,
+		ColumnMeta: columnMeta,
+		Rows:       len(result.Data),
+	}
+}
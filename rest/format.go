@@ -0,0 +1,442 @@
+package rest
+
+import (
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/gin-gonic/gin"
+	"github.com/taosdata/blm3/tools/pool"
+	"github.com/taosdata/driver-go/v2/common"
+	"github.com/taosdata/driver-go/v2/wrapper"
+)
+
+const (
+	FormatJSON   = "json"
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatArrow  = "arrow"
+)
+
+const (
+	MIMECSV    = "text/csv"
+	MIMENDJSON = "application/x-ndjson"
+	MIMEArrow  = "application/vnd.apache.arrow.stream"
+)
+
+// column types of TDengine
+const (
+	typeBool      = 1
+	typeTinyint   = 2
+	typeSmallint  = 3
+	typeInt       = 4
+	typeBigint    = 5
+	typeFloat     = 6
+	typeDouble    = 7
+	typeBinary    = 8
+	typeTimestamp = 9
+	typeNchar     = 10
+	typeUTinyint  = 11
+	typeUSmallint = 12
+	typeUInt      = 13
+	typeUBigint   = 14
+)
+
+// Formats other than json can not carry the status in the body, so it is sent in http trailers after the data.
+const (
+	TrailerStatus = "Taos-Status"
+	TrailerRows   = "Taos-Rows"
+	TrailerCode   = "Taos-Code"
+	TrailerDesc   = "Taos-Desc"
+)
+
+type resultWriter interface {
+	writeHeader(header *wrapper.RowsHeader) error
+	writeRows(rows [][]driver.Value) error
+	finish() error
+	finishWithError(code int, desc string) error
+	isStarted() bool
+}
+
+// resultFormat returns the result format of a query, the format query parameter takes precedence over
+// the Accept header. Without either of them the result is json.
+func resultFormat(c *gin.Context) (string, error) {
+	if f := c.Query("format"); len(f) != 0 {
+		switch f {
+		case FormatJSON, FormatCSV, FormatNDJSON, FormatArrow:
+			return f, nil
+		}
+		return "", fmt.Errorf("unsupported format %s", f)
+	}
+	if len(c.GetHeader("Accept")) == 0 {
+		return FormatJSON, nil
+	}
+	switch c.NegotiateFormat(gin.MIMEJSON, MIMECSV, MIMENDJSON, "application/ndjson", MIMEArrow) {
+	case MIMECSV:
+		return FormatCSV, nil
+	case MIMENDJSON, "application/ndjson":
+		return FormatNDJSON, nil
+	case MIMEArrow:
+		return FormatArrow, nil
+	}
+	return FormatJSON, nil
+}
+
+func newResultWriter(c *gin.Context, format string) resultWriter {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(c)
+	case FormatNDJSON:
+		return newNDJSONWriter(c)
+	case FormatArrow:
+		return newArrowWriter(c)
+	}
+	return newStreamWriter(c)
+}
+
+// writeAffectedRows writes the affected rows of a statement without result set as one int column
+// affected_rows, the same as the json result.
+func writeAffectedRows(w resultWriter, affectedRows int) error {
+	err := w.writeHeader(&wrapper.RowsHeader{
+		ColNames:  []string{"affected_rows"},
+		ColTypes:  []uint8{typeInt},
+		ColLength: []uint16{4},
+	})
+	if err != nil {
+		return err
+	}
+	err = w.writeRows([][]driver.Value{{int32(affectedRows)}})
+	if err != nil {
+		return err
+	}
+	return w.finish()
+}
+
+// arrowTime keeps timestamps as time.Time so that the arrow writer can store them as arrow timestamps.
+func arrowTime(ts int64, precision int) driver.Value {
+	return common.TimestampConvertToTime(ts, precision)
+}
+
+type trailerWriter struct {
+	c       *gin.Context
+	started bool
+	rows    int
+}
+
+func (w *trailerWriter) start(contentType string) {
+	w.c.Header("Content-Type", contentType)
+	w.c.Header("Trailer", TrailerStatus+", "+TrailerRows+", "+TrailerCode+", "+TrailerDesc)
+	w.c.Status(http.StatusOK)
+	w.started = true
+}
+
+func (w *trailerWriter) isStarted() bool {
+	return w.started
+}
+
+func (w *trailerWriter) setTrailer(status string, code int, desc string) {
+	h := w.c.Writer.Header()
+	h.Set(TrailerStatus, status)
+	h.Set(TrailerRows, strconv.Itoa(w.rows))
+	if status != "succ" {
+		h.Set(TrailerCode, strconv.Itoa(code&0xffff))
+		h.Set(TrailerDesc, desc)
+	}
+}
+
+func (w *trailerWriter) finish() error {
+	w.setTrailer("succ", 0, "")
+	return nil
+}
+
+func (w *trailerWriter) finishWithError(code int, desc string) error {
+	w.setTrailer("error", code, desc)
+	return nil
+}
+
+// csvWriter writes column names as the first record followed by one record per row, null is written as an empty field.
+type csvWriter struct {
+	trailerWriter
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVWriter(c *gin.Context) *csvWriter {
+	return &csvWriter{trailerWriter: trailerWriter{c: c}}
+}
+
+func (w *csvWriter) writeHeader(header *wrapper.RowsHeader) error {
+	w.start(MIMECSV + "; charset=utf-8")
+	w.w = csv.NewWriter(w.c.Writer)
+	w.record = make([]string, len(header.ColNames))
+	return w.w.Write(header.ColNames)
+}
+
+func (w *csvWriter) writeRows(rows [][]driver.Value) error {
+	for _, row := range rows {
+		for i, v := range row {
+			w.record[i] = formatValue(v)
+		}
+		err := w.w.Write(w.record)
+		if err != nil {
+			return err
+		}
+		w.rows += 1
+	}
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		return err
+	}
+	w.c.Writer.Flush()
+	return nil
+}
+
+func formatValue(v driver.Value) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int8:
+		return strconv.FormatInt(int64(v), 10)
+	case int16:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// ndjsonWriter writes every row as a json object keyed by column name on its own line.
+type ndjsonWriter struct {
+	trailerWriter
+	keys [][]byte
+}
+
+func newNDJSONWriter(c *gin.Context) *ndjsonWriter {
+	return &ndjsonWriter{trailerWriter: trailerWriter{c: c}}
+}
+
+func (w *ndjsonWriter) writeHeader(header *wrapper.RowsHeader) error {
+	w.keys = make([][]byte, len(header.ColNames))
+	for i, name := range header.ColNames {
+		k, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		w.keys[i] = append(k, ':')
+	}
+	w.start(MIMENDJSON)
+	return nil
+}
+
+func (w *ndjsonWriter) writeRows(rows [][]driver.Value) error {
+	b := pool.BytesPoolGet()
+	defer pool.BytesPoolPut(b)
+	for _, row := range rows {
+		b.WriteByte('{')
+		for i, v := range row {
+			if i != 0 {
+				b.WriteByte(',')
+			}
+			b.Write(w.keys[i])
+			d, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			b.Write(d)
+		}
+		b.WriteString("}\n")
+		w.rows += 1
+	}
+	_, err := w.c.Writer.Write(b.Bytes())
+	if err != nil {
+		return err
+	}
+	w.c.Writer.Flush()
+	return nil
+}
+
+// arrowWriter writes an arrow ipc stream with one record batch per fetched block. Timestamps are expected
+// to be time.Time, see arrowTime.
+type arrowWriter struct {
+	trailerWriter
+	builder *array.RecordBuilder
+	w       *ipc.Writer
+}
+
+func newArrowWriter(c *gin.Context) *arrowWriter {
+	return &arrowWriter{trailerWriter: trailerWriter{c: c}}
+}
+
+func arrowType(colType uint8) arrow.DataType {
+	switch colType {
+	case typeBool:
+		return arrow.FixedWidthTypes.Boolean
+	case typeTinyint:
+		return arrow.PrimitiveTypes.Int8
+	case typeSmallint:
+		return arrow.PrimitiveTypes.Int16
+	case typeInt:
+		return arrow.PrimitiveTypes.Int32
+	case typeBigint:
+		return arrow.PrimitiveTypes.Int64
+	case typeFloat:
+		return arrow.PrimitiveTypes.Float32
+	case typeDouble:
+		return arrow.PrimitiveTypes.Float64
+	case typeTimestamp:
+		return arrow.FixedWidthTypes.Timestamp_ns
+	case typeUTinyint:
+		return arrow.PrimitiveTypes.Uint8
+	case typeUSmallint:
+		return arrow.PrimitiveTypes.Uint16
+	case typeUInt:
+		return arrow.PrimitiveTypes.Uint32
+	case typeUBigint:
+		return arrow.PrimitiveTypes.Uint64
+	}
+	return arrow.BinaryTypes.String
+}
+
+func (w *arrowWriter) writeHeader(header *wrapper.RowsHeader) error {
+	fields := make([]arrow.Field, len(header.ColNames))
+	for i, name := range header.ColNames {
+		fields[i] = arrow.Field{Name: name, Type: arrowType(header.ColTypes[i]), Nullable: true}
+	}
+	schema := arrow.NewSchema(fields, nil)
+	w.builder = array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	w.start(MIMEArrow)
+	w.w = ipc.NewWriter(w.c.Writer, ipc.WithSchema(schema))
+	return nil
+}
+
+func (w *arrowWriter) writeRows(rows [][]driver.Value) error {
+	for _, row := range rows {
+		for i, v := range row {
+			err := appendArrowValue(w.builder.Field(i), v)
+			if err != nil {
+				return err
+			}
+		}
+		w.rows += 1
+	}
+	record := w.builder.NewRecord()
+	defer record.Release()
+	err := w.w.Write(record)
+	if err != nil {
+		return err
+	}
+	w.c.Writer.Flush()
+	return nil
+}
+
+func appendArrowValue(b array.Builder, v driver.Value) error {
+	if v == nil {
+		b.AppendNull()
+		return nil
+	}
+	ok := true
+	switch b := b.(type) {
+	case *array.BooleanBuilder:
+		var x bool
+		x, ok = v.(bool)
+		b.Append(x)
+	case *array.Int8Builder:
+		var x int8
+		x, ok = v.(int8)
+		b.Append(x)
+	case *array.Int16Builder:
+		var x int16
+		x, ok = v.(int16)
+		b.Append(x)
+	case *array.Int32Builder:
+		var x int32
+		x, ok = v.(int32)
+		b.Append(x)
+	case *array.Int64Builder:
+		var x int64
+		x, ok = v.(int64)
+		b.Append(x)
+	case *array.Uint8Builder:
+		var x uint8
+		x, ok = v.(uint8)
+		b.Append(x)
+	case *array.Uint16Builder:
+		var x uint16
+		x, ok = v.(uint16)
+		b.Append(x)
+	case *array.Uint32Builder:
+		var x uint32
+		x, ok = v.(uint32)
+		b.Append(x)
+	case *array.Uint64Builder:
+		var x uint64
+		x, ok = v.(uint64)
+		b.Append(x)
+	case *array.Float32Builder:
+		var x float32
+		x, ok = v.(float32)
+		b.Append(x)
+	case *array.Float64Builder:
+		var x float64
+		x, ok = v.(float64)
+		b.Append(x)
+	case *array.TimestampBuilder:
+		var x time.Time
+		x, ok = v.(time.Time)
+		b.Append(arrow.Timestamp(x.UnixNano()))
+	case *array.StringBuilder:
+		b.Append(formatValue(v))
+	default:
+		ok = false
+	}
+	if !ok {
+		return fmt.Errorf("unexpected value %v of type %T", v, v)
+	}
+	return nil
+}
+
+func (w *arrowWriter) finish() error {
+	defer w.builder.Release()
+	err := w.w.Close()
+	if err != nil {
+		return err
+	}
+	return w.trailerWriter.finish()
+}
+
+func (w *arrowWriter) finishWithError(code int, desc string) error {
+	defer w.builder.Release()
+	err := w.w.Close()
+	if err != nil {
+		return err
+	}
+	return w.trailerWriter.finishWithError(code, desc)
+}
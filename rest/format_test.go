@@ -0,0 +1,132 @@
+package rest
+
+import (
+	"database/sql/driver"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/httperror"
+	"github.com/taosdata/driver-go/v2/wrapper"
+)
+
+func TestResultFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		accept  string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", url: "/", want: FormatJSON},
+		{name: "param", url: "/?format=csv", accept: MIMEArrow, want: FormatCSV},
+		{name: "invalid param", url: "/?format=xml", wantErr: true},
+		{name: "accept csv", url: "/", accept: "text/csv", want: FormatCSV},
+		{name: "accept ndjson", url: "/", accept: "application/x-ndjson", want: FormatNDJSON},
+		{name: "accept arrow", url: "/", accept: MIMEArrow, want: FormatArrow},
+		{name: "accept any", url: "/", accept: "*/*", want: FormatJSON},
+		{name: "accept unknown", url: "/", accept: "text/html", want: FormatJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("POST", tt.url, nil)
+			if len(tt.accept) != 0 {
+				c.Request.Header.Set("Accept", tt.accept)
+			}
+			got, err := resultFormat(c)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+var formatTestHeader = &wrapper.RowsHeader{
+	ColNames:  []string{"ts", "v", "s"},
+	ColTypes:  []uint8{9, 4, 8},
+	ColLength: []uint16{8, 4, 10},
+}
+
+func TestCSVWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	rw := newResultWriter(c, FormatCSV)
+	assert.NoError(t, rw.writeHeader(formatTestHeader))
+	assert.NoError(t, rw.writeRows([][]driver.Value{{"2021-10-01 00:00:00.000", int32(1), "a,b"}, {"2021-10-01 00:00:01.000", nil, nil}}))
+	assert.NoError(t, rw.finish())
+	assert.Equal(t, "ts,v,s\n2021-10-01 00:00:00.000,1,\"a,b\"\n2021-10-01 00:00:01.000,,\n", w.Body.String())
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), MIMECSV))
+	trailer := w.Result().Trailer
+	assert.Equal(t, "succ", trailer.Get(TrailerStatus))
+	assert.Equal(t, "2", trailer.Get(TrailerRows))
+}
+
+func TestWriteAffectedRows(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	assert.NoError(t, writeAffectedRows(newResultWriter(c, FormatCSV), 3))
+	assert.Equal(t, "affected_rows\n3\n", w.Body.String())
+	assert.Equal(t, "1", w.Result().Trailer.Get(TrailerRows))
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	rw := newResultWriter(c, FormatNDJSON)
+	assert.NoError(t, rw.writeHeader(formatTestHeader))
+	assert.NoError(t, rw.writeRows([][]driver.Value{{int64(1633046400000), int32(1), "a"}}))
+	assert.NoError(t, rw.writeRows([][]driver.Value{{int64(1633046401000), nil, "b"}}))
+	assert.NoError(t, rw.finishWithError(httperror.HTTP_QUERY_ROWS_EXCEEDED, "query result rows exceed limit"))
+	assert.Equal(t, "{\"ts\":1633046400000,\"v\":1,\"s\":\"a\"}\n{\"ts\":1633046401000,\"v\":null,\"s\":\"b\"}\n", w.Body.String())
+	trailer := w.Result().Trailer
+	assert.Equal(t, "error", trailer.Get(TrailerStatus))
+	assert.Equal(t, "7938", trailer.Get(TrailerCode))
+	assert.Equal(t, "query result rows exceed limit", trailer.Get(TrailerDesc))
+}
+
+func TestArrowWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	rw := newResultWriter(c, FormatArrow)
+	ts := time.Unix(1633046400, 0)
+	assert.NoError(t, rw.writeHeader(formatTestHeader))
+	assert.NoError(t, rw.writeRows([][]driver.Value{{ts, int32(1), "a"}, {ts.Add(time.Second), nil, "b"}}))
+	assert.NoError(t, rw.writeRows([][]driver.Value{{ts.Add(2 * time.Second), int32(3), nil}}))
+	assert.NoError(t, rw.finish())
+	assert.Equal(t, MIMEArrow, w.Header().Get("Content-Type"))
+	reader, err := ipc.NewReader(w.Body)
+	assert.NoError(t, err)
+	defer reader.Release()
+	assert.Equal(t, "ts", reader.Schema().Field(0).Name)
+	var rows int64
+	var records int
+	for reader.Next() {
+		record := reader.Record()
+		if records == 0 {
+			assert.Equal(t, ts.UnixNano(), int64(record.Column(0).(*array.Timestamp).Value(0)))
+			assert.Equal(t, int32(1), record.Column(1).(*array.Int32).Value(0))
+			assert.True(t, record.Column(1).IsNull(1))
+			assert.Equal(t, "b", record.Column(2).(*array.String).Value(1))
+		}
+		rows += record.NumRows()
+		records += 1
+	}
+	assert.Equal(t, 2, records)
+	assert.Equal(t, int64(3), rows)
+}
+
+func TestArrowWriterTypeError(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	rw := newResultWriter(c, FormatArrow)
+	assert.NoError(t, rw.writeHeader(formatTestHeader))
+	assert.Error(t, rw.writeRows([][]driver.Value{{"2021-10-01 00:00:00.000", int32(1), "a"}}))
+}
@@ -1,19 +1,26 @@
 package rest
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"github.com/taosdata/blm3/config"
 	"github.com/taosdata/blm3/db/async"
 	"github.com/taosdata/blm3/db/commonpool"
 	"github.com/taosdata/blm3/httperror"
 	"github.com/taosdata/blm3/log"
 	"github.com/taosdata/blm3/thread"
+	"github.com/taosdata/blm3/tools/limit"
+	"github.com/taosdata/blm3/tools/login"
+	"github.com/taosdata/blm3/tools/token"
 	"github.com/taosdata/blm3/tools/web"
 	"github.com/taosdata/driver-go/v2/common"
 	tErrors "github.com/taosdata/driver-go/v2/errors"
@@ -31,30 +38,43 @@ type Restful struct {
 
 func (ctl *Restful) Init(r gin.IRouter) error {
 	api := r.Group("rest")
-	api.POST("sql", checkAuth, ctl.sql)
-	api.POST("sqlt", checkAuth, ctl.sqlt)
-	api.POST("sqlutc", checkAuth, ctl.sqlutc)
-	api.POST("sql/:db", checkAuth, ctl.sql)
-	api.POST("sqlt/:db", checkAuth, ctl.sqlt)
-	api.POST("sqlutc/:db", checkAuth, ctl.sqlutc)
-	api.GET("login/:user/:password", ctl.des)
+	ipLimiter := limit.IPHandler(limitErrorResponse)
+	userLimiter := limit.UserHandler(limitErrorResponse)
+	api.POST("sql", ipLimiter, checkAuth, userLimiter, ctl.sql)
+	api.POST("sqlt", ipLimiter, checkAuth, userLimiter, ctl.sqlt)
+	api.POST("sqlutc", ipLimiter, checkAuth, userLimiter, ctl.sqlutc)
+	api.POST("sql/:db", ipLimiter, checkAuth, userLimiter, ctl.sql)
+	api.POST("sqlt/:db", ipLimiter, checkAuth, userLimiter, ctl.sqlt)
+	api.POST("sqlutc/:db", ipLimiter, checkAuth, userLimiter, ctl.sqlutc)
+	api.POST("batch", ipLimiter, checkAuth, userLimiter, ctl.batch)
+	api.POST("batch/:db", ipLimiter, checkAuth, userLimiter, ctl.batch)
+	api.POST("stmt", ipLimiter, checkAuth, userLimiter, ctl.stmt)
+	api.POST("stmt/:db", ipLimiter, checkAuth, userLimiter, ctl.stmt)
+	api.GET("login/:user/:password", ipLimiter, ctl.login)
+	api.POST("logout", checkAuth, ctl.logout)
 	return nil
 }
 
 func (ctl *Restful) sql(c *gin.Context) {
 	db := c.Param("db")
-	ctl.doQuery(c, db, func(ts int64, precision int) driver.Value {
-		switch precision {
-		case common.PrecisionMilliSecond:
-			return common.TimestampConvertToTime(ts, precision).Local().Format(LayoutMillSecond)
-		case common.PrecisionMicroSecond:
-			return common.TimestampConvertToTime(ts, precision).Local().Format(LayoutMicroSecond)
-		case common.PrecisionNanoSecond:
-			return common.TimestampConvertToTime(ts, precision).Local().Format(LayoutNanoSecond)
-		}
-		panic("unsupported precision")
-	})
+	timeFunc, ok := timeFuncOrError(c)
+	if !ok {
+		return
+	}
+	ctl.doQuery(c, db, timeFunc)
 }
+
+// timeFuncOrError returns the timestamp format function of the request, writing the error response on failure.
+func timeFuncOrError(c *gin.Context) (wrapper.FormatTimeFunc, bool) {
+	timeFunc, err := requestTimeFunc(c)
+	if err != nil {
+		logger.WithField("sessionID", web.GetRequestID(c)).WithError(err).Errorln("invalid time format")
+		errorResponseWithMsg(c, httperror.HTTP_INVALID_TIME_FORMAT, err.Error())
+		return nil, false
+	}
+	return timeFunc, true
+}
+
 func (ctl *Restful) sqlt(c *gin.Context) {
 	db := c.Param("db")
 	ctl.doQuery(c, db, func(ts int64, precision int) driver.Value {
@@ -78,8 +98,6 @@ type TDEngineRestfulResp struct {
 }
 
 func (ctl *Restful) doQuery(c *gin.Context, db string, timeFunc wrapper.FormatTimeFunc) {
-	var s time.Time
-	isDebug := logger.Logger.IsLevelEnabled(logrus.DebugLevel)
 	id := web.GetRequestID(c)
 	logger := logger.WithField("sessionID", id)
 	b, err := c.GetRawData()
@@ -99,6 +117,88 @@ func (ctl *Restful) doQuery(c *gin.Context, db string, timeFunc wrapper.FormatTi
 		errorResponse(c, httperror.HTTP_NO_SQL_INPUT)
 		return
 	}
+	format, err := resultFormat(c)
+	if err != nil {
+		logger.WithError(err).Errorln("invalid result format")
+		errorResponseWithMsg(c, httperror.HTTP_UNSUPPORTED_FORMAT, err.Error())
+		return
+	}
+	if format == FormatArrow {
+		timeFunc = arrowTime
+	}
+	maxRows, timeout, err := queryLimit(c)
+	if err != nil {
+		logger.WithError(err).Errorln("invalid query limit")
+		errorResponseWithMsg(c, httperror.HTTP_INVALID_QUERY_LIMIT, err.Error())
+		return
+	}
+	taosConnect := prepareConnection(c, logger, db)
+	if taosConnect == nil {
+		return
+	}
+	// the connection goes back to the pool when the query result is freed, which is after the response of a timed
+	// out query
+	release := releaseConnection(logger, taosConnect)
+
+	ctx := c.Request.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// the result is streamed while the query runs, it gets the query timeout and 90s more to be written instead of
+	// the write timeout of the server
+	writeTimeout := time.Duration(0)
+	if timeout > 0 {
+		writeTimeout = timeout + 90*time.Second
+	}
+	web.SetWriteDeadline(c.Request, writeTimeout)
+
+	startExec := time.Now()
+
+	logger.Debugln(startExec, "start execute sql:", sql)
+	w := newResultWriter(c, format)
+	result, err := async.GlobalAsync.TaosExecStream(ctx, taosConnect.TaosConnection, sql, timeFunc, maxRows, w.writeHeader, w.writeRows, release)
+	logger.Debugln("execute sql cost:", time.Now().Sub(startExec))
+	if err != nil {
+		logger.WithError(err).Errorln("execute sql error:", sql)
+		code, desc := queryError(err)
+		if w.isStarted() {
+			err = w.finishWithError(code, desc)
+			if err != nil {
+				logger.WithError(err).Errorln("write response error")
+			}
+			return
+		}
+		errorResponseWithMsg(c, code, desc)
+		return
+	}
+	if result.FieldCount == 0 {
+		logger.Debugln("execute sql success affected rows:", result.AffectedRows)
+		if format == FormatJSON {
+			c.JSON(http.StatusOK, newRestfulResp(result))
+			return
+		}
+		err = writeAffectedRows(w, result.AffectedRows)
+		if err != nil {
+			logger.WithError(err).Errorln("write response error")
+		}
+	} else {
+		err = w.finish()
+		if err != nil {
+			logger.WithError(err).Errorln("write response error")
+			return
+		}
+		logger.Debugln("execute sql success return data rows:", result.Rows)
+	}
+}
+
+// prepareConnection gets a connection of the request user from the pool and selects db when given.
+// On failure the error response is written and nil is returned.
+func prepareConnection(c *gin.Context, logger *logrus.Entry, db string) *commonpool.Conn {
+	var s time.Time
+	isDebug := logger.Logger.IsLevelEnabled(logrus.DebugLevel)
 	user := c.MustGet(UserKey).(string)
 	password := c.MustGet(PasswordKey).(string)
 	if isDebug {
@@ -112,23 +212,11 @@ func (ctl *Restful) doQuery(c *gin.Context, db string, timeFunc wrapper.FormatTi
 		var tError *tErrors.TaosError
 		if errors.As(err, &tError) {
 			errorResponseWithMsg(c, int(tError.Code), tError.ErrStr)
-			return
 		} else {
 			errorResponseWithMsg(c, 0xffff, err.Error())
-			return
 		}
+		return nil
 	}
-	defer func() {
-		if isDebug {
-			s = time.Now()
-		}
-		err := taosConnect.Put()
-		if err != nil {
-			panic(err)
-		}
-		logger.Debugln("taos put connect cost:", time.Now().Sub(s))
-	}()
-
 	if len(db) > 0 {
 		if isDebug {
 			s = time.Now()
@@ -144,86 +232,142 @@ func (ctl *Restful) doQuery(c *gin.Context, db string, timeFunc wrapper.FormatTi
 			}
 			errorMsg := tErrors.GetError(code)
 			logger.Debugln("taos select db get error string cost:", time.Now().Sub(s))
-			logger.Errorln("taos select db error:", sql, code&0xffff, errorMsg.Error())
+			logger.Errorln("taos select db error:", db, code&0xffff, errorMsg.Error())
 			errorResponseWithMsg(c, code, errorMsg.Error())
-			return
+			putConnection(logger, taosConnect)
+			return nil
 		}
 	}
+	return taosConnect
+}
 
-	startExec := time.Now()
-
-	logger.Debugln(startExec, "start execute sql:", sql)
-	result, err := async.GlobalAsync.TaosExec(taosConnect.TaosConnection, sql, timeFunc)
-	logger.Debugln("execute sql cost:", time.Now().Sub(startExec))
+func putConnection(logger *logrus.Entry, taosConnect *commonpool.Conn) {
+	var s time.Time
+	if logger.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		s = time.Now()
+	}
+	err := taosConnect.Put()
 	if err != nil {
-		tError, ok := err.(*tErrors.TaosError)
-		if ok {
-			errorResponseWithMsg(c, int(tError.Code), tError.ErrStr)
-		} else {
-			errorResponseWithMsg(c, 0xffff, err.Error())
-		}
-		return
+		panic(err)
 	}
-	if isDebug {
-		s = time.Now()
+	logger.Debugln("taos put connect cost:", time.Now().Sub(s))
+}
+
+// releaseConnection returns the done function of a query on taosConnect which puts the connection back to the pool,
+// it may run after the request has finished so the error is logged instead of panicking.
+func releaseConnection(logger *logrus.Entry, taosConnect *commonpool.Conn) func() {
+	return func() {
+		err := taosConnect.Put()
+		if err != nil {
+			logger.WithError(err).Errorln("taos connect pool put error")
+		}
 	}
-	if result.FieldCount == 0 {
-		logger.Debugln("execute sql success affected rows:", result.AffectedRows)
-		c.JSON(http.StatusOK, &TDEngineRestfulResp{
-			Status:     "succ",
-			Head:       []string{"affected_rows"},
-			Data:       [][]driver.Value{{result.AffectedRows}},
-			ColumnMeta: [][]interface{}{{"affected_rows", 4, 4}},
-			Rows:       1,
-		})
-	} else {
-		if isDebug {
-			s = time.Now()
+}
+
+// queryLimit returns the max rows and timeout of a query, the request can lower the configured limits
+// with the maxRows and timeout query parameters but never raise them. 0 means no limit.
+func queryLimit(c *gin.Context) (maxRows int, timeout time.Duration, err error) {
+	maxRows = config.Conf.Restful.MaxRows
+	timeout = config.Conf.Restful.QueryTimeout
+	if s := c.Query("maxRows"); len(s) != 0 {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("invalid maxRows %s", s)
 		}
-		if isDebug {
-			s = time.Now()
+		if maxRows <= 0 || n < maxRows {
+			maxRows = n
+		}
+	}
+	if s := c.Query("timeout"); len(s) != 0 {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return 0, 0, fmt.Errorf("invalid timeout %s", s)
 		}
-		logger.Debugln("execute sql success return data rows:", len(result.Data), ",cost:", time.Now().Sub(s))
-		var columnMeta [][]interface{}
-		for i := 0; i < len(result.Header.ColNames); i++ {
-			columnMeta = append(columnMeta, []interface{}{
-				result.Header.ColNames[i],
-				result.Header.ColTypes[i],
-				result.Header.ColLength[i],
-			})
+		if timeout <= 0 || d < timeout {
+			timeout = d
 		}
-		c.JSON(http.StatusOK, &TDEngineRestfulResp{
-			Status:     "succ",
-			Head:       result.Header.ColNames,
-			Data:       result.Data,
-			ColumnMeta: columnMeta,
-			Rows:       len(result.Data),
-		})
 	}
+	return maxRows, timeout, nil
+}
+
+func queryError(err error) (code int, desc string) {
+	switch err {
+	case async.QueryTimeoutError:
+		return httperror.HTTP_QUERY_TIMEOUT, httperror.ErrorMsgMap[httperror.HTTP_QUERY_TIMEOUT]
+	case async.MaxRowsError:
+		return httperror.HTTP_QUERY_ROWS_EXCEEDED, httperror.ErrorMsgMap[httperror.HTTP_QUERY_ROWS_EXCEEDED]
+	}
+	tError, ok := err.(*tErrors.TaosError)
+	if ok {
+		return int(tError.Code), tError.ErrStr
+	}
+	return 0xffff, err.Error()
 }
 
-func (ctl *Restful) des(c *gin.Context) {
+// login issues a token of the user after checking the password with taosd, the scope query parameter is a comma
+// separated subset of rest and plugin and defaults to both.
+func (ctl *Restful) login(c *gin.Context) {
 	user := c.Param("user")
 	password := c.Param("password")
 	if len(user) < 0 || len(user) > 24 || len(password) < 0 || len(password) > 24 {
 		errorResponse(c, httperror.HTTP_GEN_TAOSD_TOKEN_ERR)
 		return
 	}
-	//conn, err := commonpool.GetConnection(user, password)
-	//if err != nil {
-	//	errorResponse(c, httperror.TSDB_CODE_RPC_AUTH_FAILURE)
-	//	return
-	//}
-	//conn.Put()
-	token, err := EncodeDes(user, password)
+	scopes := token.Scopes
+	if s := c.Query("scope"); len(s) != 0 {
+		scopes = strings.Split(s, ",")
+		for _, scope := range scopes {
+			if scope != token.ScopeRest && scope != token.ScopePlugin {
+				errorResponseWithMsg(c, httperror.HTTP_GEN_TAOSD_TOKEN_ERR, "unknown scope "+scope)
+				return
+			}
+		}
+	}
+	err := login.Authenticate(c, "login", user, password)
 	if err != nil {
+		authFailedResponse(c, err)
+		return
+	}
+	var t string
+	if config.GetToken().Legacy {
+		t, err = token.EncodeDes(user, password)
+	} else {
+		t, _, err = token.Issue(user, password, scopes)
+	}
+	if err != nil {
+		logger.WithError(err).Error("issue token error")
 		errorResponse(c, httperror.HTTP_GEN_TAOSD_TOKEN_ERR)
 		return
 	}
 	c.JSON(http.StatusOK, &Message{
 		Status: "succ",
 		Code:   0,
-		Desc:   token,
+		Desc:   t,
+	})
+}
+
+// logout revokes the token of the request, or every token of its user issued so far with all=true.
+func (ctl *Restful) logout(c *gin.Context) {
+	v, exist := c.Get(claimsKey)
+	if !exist {
+		errorResponseWithMsg(c, httperror.HTTP_INVALID_TAOSD_AUTH, "logout needs a token")
+		return
+	}
+	claims := v.(*token.Claims)
+	var err error
+	if c.Query("all") == "true" {
+		err = token.RevokeUser(claims.User)
+	} else {
+		err = token.Revoke(claims)
+	}
+	if err != nil {
+		logger.WithError(err).Error("revoke token error")
+		errorResponseWithMsg(c, httperror.HTTP_INVALID_TAOSD_AUTH, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, &Message{
+		Status: "succ",
+		Code:   0,
 	})
 }
 
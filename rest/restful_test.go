@@ -5,12 +5,16 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/taosdata/blm3/config"
 	"github.com/taosdata/blm3/db"
+	"github.com/taosdata/blm3/tools/login"
+	"github.com/taosdata/blm3/tools/token"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
 )
 
 var router *gin.Engine
@@ -20,6 +24,17 @@ func TestMain(m *testing.M) {
 	viper.Set("pool.maxIdle", 10000)
 	config.Init()
 	db.PrepareConnection()
+	config.Conf.Token.RevokedFile = ""
+	err := token.Init(&config.Conf.Token)
+	if err != nil {
+		panic(err)
+	}
+	login.VerifyUser = func(user, password string) error {
+		if password != "taosdata" {
+			return &tErrors.TaosError{Code: tErrors.RPC_AUTH_FAILURE, ErrStr: "Authentication failure"}
+		}
+		return nil
+	}
 	gin.SetMode(gin.ReleaseMode)
 	router = gin.New()
 	router.Use(func(context *gin.Context) {
@@ -40,3 +55,38 @@ func BenchmarkRestful(b *testing.B) {
 		assert.Equal(b, 200, w.Code)
 	}
 }
+
+func TestQueryLimit(t *testing.T) {
+	config.Conf.Restful.MaxRows = 100
+	config.Conf.Restful.QueryTimeout = time.Minute
+	defer func() {
+		config.Conf.Restful.MaxRows = 0
+		config.Conf.Restful.QueryTimeout = 0
+	}()
+	tests := []struct {
+		name        string
+		query       string
+		wantMaxRows int
+		wantTimeout time.Duration
+		wantErr     bool
+	}{
+		{name: "default", query: "", wantMaxRows: 100, wantTimeout: time.Minute},
+		{name: "lower", query: "?maxRows=10&timeout=5s", wantMaxRows: 10, wantTimeout: 5 * time.Second},
+		{name: "higher", query: "?maxRows=1000&timeout=1h", wantMaxRows: 100, wantTimeout: time.Minute},
+		{name: "invalid rows", query: "?maxRows=-1", wantErr: true},
+		{name: "invalid timeout", query: "?timeout=abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request, _ = http.NewRequest(http.MethodPost, "/rest/sql"+tt.query, nil)
+			maxRows, timeout, err := queryLimit(c)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("queryLimit() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.wantMaxRows, maxRows)
+			assert.Equal(t, tt.wantTimeout, timeout)
+		})
+	}
+}
@@ -0,0 +1,424 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taosdata/blm3/db/async"
+	"github.com/taosdata/blm3/httperror"
+	"github.com/taosdata/blm3/tools/web"
+	"github.com/taosdata/driver-go/v2/common"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
+	taosTypes "github.com/taosdata/driver-go/v2/types"
+	"github.com/taosdata/driver-go/v2/wrapper"
+)
+
+// stmtSlots bounds the stmt executions which run at the same time, including those still waiting for taosd after
+// their request timed out.
+var stmtSlots = make(chan struct{}, runtime.NumCPU())
+
+// StmtReq is the body of /rest/stmt. Sql contains `?` placeholders, Types holds the type of each
+// placeholder and every element of Params is one row of values bound to them.
+type StmtReq struct {
+	Sql       string          `json:"sql"`
+	TableName string          `json:"tableName"`
+	Precision string          `json:"precision"`
+	Types     []string        `json:"types"`
+	Params    [][]interface{} `json:"params"`
+}
+
+func (ctl *Restful) stmt(c *gin.Context) {
+	db := c.Param("db")
+	timeFunc, ok := timeFuncOrError(c)
+	if !ok {
+		return
+	}
+	ctl.doStmt(c, db, timeFunc)
+}
+
+func (ctl *Restful) doStmt(c *gin.Context, db string, timeFunc wrapper.FormatTimeFunc) {
+	id := web.GetRequestID(c)
+	logger := logger.WithField("sessionID", id)
+	b, err := c.GetRawData()
+	if err != nil {
+		logger.WithError(err).Error("get request body error")
+		errorResponse(c, httperror.HTTP_INVALID_CONTENT_LENGTH)
+		return
+	}
+	if len(b) == 0 {
+		logger.Errorln("no msg got")
+		errorResponse(c, httperror.HTTP_NO_MSG_INPUT)
+		return
+	}
+	var req StmtReq
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	err = d.Decode(&req)
+	if err != nil {
+		logger.WithError(err).Errorln("unmarshal stmt request error")
+		errorResponse(c, httperror.HTTP_REQUEST_JSON_ERROR)
+		return
+	}
+	req.Sql = strings.TrimSpace(req.Sql)
+	if len(req.Sql) == 0 {
+		logger.Errorln("no sql got")
+		errorResponse(c, httperror.HTTP_NO_SQL_INPUT)
+		return
+	}
+	params, err := convertParams(req.Types, req.Params, req.Precision)
+	if err != nil {
+		logger.WithError(err).Errorln("convert stmt params error")
+		errorResponseWithMsg(c, httperror.HTTP_INVALID_STMT_PARAM, err.Error())
+		return
+	}
+	maxRows, timeout, err := queryLimit(c)
+	if err != nil {
+		logger.WithError(err).Errorln("invalid query limit")
+		errorResponseWithMsg(c, httperror.HTTP_INVALID_QUERY_LIMIT, err.Error())
+		return
+	}
+	taosConnect := prepareConnection(c, logger, db)
+	if taosConnect == nil {
+		return
+	}
+	ctx := c.Request.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	startExec := time.Now()
+	logger.Debugln(startExec, "start execute stmt:", req.Sql)
+	// the stmt api is synchronous, the request returns at the deadline while the stmt keeps the connection until
+	// taosd answers
+	var result, execResult *async.ExecResult
+	var execErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		execResult, execErr = stmtExec(ctx, taosConnect.TaosConnection, req.Sql, req.TableName, params, timeFunc, maxRows)
+	}()
+	select {
+	case <-done:
+		putConnection(logger, taosConnect)
+		result, err = execResult, execErr
+	case <-ctx.Done():
+		go func() {
+			<-done
+			putConnection(logger, taosConnect)
+		}()
+		err = ctxError(ctx)
+	}
+	logger.Debugln("execute stmt cost:", time.Now().Sub(startExec))
+	if err != nil {
+		logger.WithError(err).Errorln("execute stmt error:", req.Sql)
+		code, desc := queryError(err)
+		if err == async.MaxRowsError && result != nil {
+			// the rows up to maxRows are returned like /rest/sql
+			w := newStreamWriter(c)
+			err = w.writeHeader(result.Header)
+			if err == nil {
+				err = w.writeRows(result.Data)
+			}
+			if err == nil {
+				err = w.finishWithError(code, desc)
+			}
+			if err != nil {
+				logger.WithError(err).Errorln("write response error")
+			}
+			return
+		}
+		errorResponseWithMsg(c, code, desc)
+		return
+	}
+	c.JSON(http.StatusOK, newRestfulResp(result))
+}
+
+// stmtExec prepares sql and binds every row of params. Insert statements add all rows into one batch,
+// other statements are executed with the first row and their result is fetched. It stops before the execution and
+// between the fetched rows once ctx is done, the rows up to maxRows are returned with async.MaxRowsError.
+// The stmt api blocks while taosd answers, the stmt calls take one of stmtSlots instead of the thread lock so that
+// a slow stmt does not hold up the other cgo calls. The query of a stmt whose ctx is done while it fetches is stopped.
+func stmtExec(ctx context.Context, taosConnect unsafe.Pointer, sql, tableName string, params [][]interface{}, timeFunc wrapper.FormatTimeFunc, maxRows int) (*async.ExecResult, error) {
+	select {
+	case stmtSlots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctxError(ctx)
+	}
+	defer func() {
+		<-stmtSlots
+	}()
+	stmt := wrapper.TaosStmtInit(taosConnect)
+	if stmt == nil {
+		return nil, &tErrors.TaosError{Code: 0xffff, ErrStr: "failed to init stmt"}
+	}
+	defer wrapper.TaosStmtClose(stmt)
+	code := wrapper.TaosStmtPrepare(stmt, sql)
+	if err := tErrors.GetError(code); err != nil {
+		return nil, err
+	}
+	isInsert, code := wrapper.TaosStmtIsInsert(stmt)
+	if err := tErrors.GetError(code); err != nil {
+		return nil, err
+	}
+	numParams, code := wrapper.TaosStmtNumParams(stmt)
+	if err := tErrors.GetError(code); err != nil {
+		return nil, err
+	}
+	if len(tableName) != 0 {
+		code = wrapper.TaosStmtSetTBName(stmt, tableName)
+		if err := tErrors.GetError(code); err != nil {
+			return nil, err
+		}
+	}
+	if !isInsert && len(params) > 1 {
+		return nil, errors.New("only insert statement supports multiple rows of params")
+	}
+	if len(params) == 0 {
+		params = [][]interface{}{nil}
+	}
+	for i, row := range params {
+		if len(row) != numParams {
+			return nil, fmt.Errorf("row %d param count error: expect %d got %d", i, numParams, len(row))
+		}
+		code = wrapper.TaosStmtBindParam(stmt, row)
+		if err := tErrors.GetError(code); err != nil {
+			return nil, err
+		}
+		if isInsert {
+			code = wrapper.TaosStmtAddBatch(stmt)
+			if err := tErrors.GetError(code); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := ctxError(ctx); err != nil {
+		return nil, err
+	}
+	code = wrapper.TaosStmtExecute(stmt)
+	if err := tErrors.GetError(code); err != nil {
+		return nil, err
+	}
+	res := wrapper.TaosStmtUseResult(stmt)
+	if res == nil {
+		return nil, &tErrors.TaosError{Code: 0xffff, ErrStr: "failed to get stmt result"}
+	}
+	defer wrapper.TaosFreeResult(res)
+	stopped := make(chan struct{})
+	var watcher sync.WaitGroup
+	watcher.Add(1)
+	go func() {
+		defer watcher.Done()
+		select {
+		case <-ctx.Done():
+			// the blocked fetch returns once the query is stopped
+			async.TaosStopQuery(res)
+		case <-stopped:
+		}
+	}()
+	// res is freed after the watcher has finished
+	defer func() {
+		close(stopped)
+		watcher.Wait()
+	}()
+	fieldsCount := wrapper.TaosNumFields(res)
+	execResult := &async.ExecResult{FieldCount: fieldsCount}
+	if fieldsCount == 0 {
+		execResult.AffectedRows = wrapper.TaosAffectedRows(res)
+		return execResult, nil
+	}
+	rowsHeader, err := wrapper.ReadColumn(res, fieldsCount)
+	if err != nil {
+		return nil, err
+	}
+	execResult.Header = rowsHeader
+	precision := wrapper.TaosResultPrecision(res)
+	for {
+		if err := ctxError(ctx); err != nil {
+			return nil, err
+		}
+		row := wrapper.TaosFetchRow(res)
+		if row == nil {
+			break
+		}
+		if maxRows > 0 && len(execResult.Data) >= maxRows {
+			execResult.Rows = len(execResult.Data)
+			return execResult, async.MaxRowsError
+		}
+		values := make([]driver.Value, fieldsCount)
+		for j := range rowsHeader.ColTypes {
+			values[j] = wrapper.FetchRow(row, j, rowsHeader.ColTypes[j], precision, timeFunc)
+		}
+		execResult.Data = append(execResult.Data, values)
+	}
+	execResult.Rows = len(execResult.Data)
+	return execResult, nil
+}
+
+func ctxError(ctx context.Context) error {
+	switch ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return async.QueryTimeoutError
+	}
+	return ctx.Err()
+}
+
+func convertParams(types []string, rows [][]interface{}, precision string) ([][]interface{}, error) {
+	p, err := parsePrecision(precision)
+	if err != nil {
+		return nil, err
+	}
+	result := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		if len(row) != len(types) {
+			return nil, fmt.Errorf("row %d value count error: expect %d got %d", i, len(types), len(row))
+		}
+		result[i] = make([]interface{}, len(row))
+		for j, v := range row {
+			result[i][j], err = convertParam(types[j], v, p)
+			if err != nil {
+				return nil, fmt.Errorf("row %d column %d: %s", i, j, err)
+			}
+		}
+	}
+	return result, nil
+}
+
+func parsePrecision(precision string) (int, error) {
+	switch precision {
+	case "", "ms":
+		return common.PrecisionMilliSecond, nil
+	case "us", "u":
+		return common.PrecisionMicroSecond, nil
+	case "ns":
+		return common.PrecisionNanoSecond, nil
+	}
+	return 0, fmt.Errorf("unsupported precision %s", precision)
+}
+
+// convertParam converts a json value to the driver type of a placeholder, json numbers are expected to be json.Number.
+func convertParam(typ string, v interface{}, precision int) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch strings.ToLower(typ) {
+	case "bool":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%v is not bool", v)
+		}
+		return taosTypes.TaosBool(b), nil
+	case "tinyint":
+		i, err := intParam(v, math.MinInt8, math.MaxInt8)
+		return taosTypes.TaosTinyint(i), err
+	case "smallint":
+		i, err := intParam(v, math.MinInt16, math.MaxInt16)
+		return taosTypes.TaosSmallint(i), err
+	case "int":
+		i, err := intParam(v, math.MinInt32, math.MaxInt32)
+		return taosTypes.TaosInt(i), err
+	case "bigint":
+		i, err := intParam(v, math.MinInt64, math.MaxInt64)
+		return taosTypes.TaosBigint(i), err
+	case "tinyint unsigned", "utinyint":
+		i, err := uintParam(v, math.MaxUint8)
+		return taosTypes.TaosUTinyint(i), err
+	case "smallint unsigned", "usmallint":
+		i, err := uintParam(v, math.MaxUint16)
+		return taosTypes.TaosUSmallint(i), err
+	case "int unsigned", "uint":
+		i, err := uintParam(v, math.MaxUint32)
+		return taosTypes.TaosUInt(i), err
+	case "bigint unsigned", "ubigint":
+		i, err := uintParam(v, math.MaxUint64)
+		return taosTypes.TaosUBigint(i), err
+	case "float":
+		f, err := floatParam(v)
+		return taosTypes.TaosFloat(f), err
+	case "double":
+		f, err := floatParam(v)
+		return taosTypes.TaosDouble(f), err
+	case "binary":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v is not string", v)
+		}
+		return taosTypes.TaosBinary(s), nil
+	case "nchar":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v is not string", v)
+		}
+		return taosTypes.TaosNchar(s), nil
+	case "timestamp":
+		switch ts := v.(type) {
+		case json.Number:
+			i, err := ts.Int64()
+			if err != nil {
+				return nil, err
+			}
+			return taosTypes.TaosTimestamp{T: common.TimestampConvertToTime(i, precision), Precision: precision}, nil
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, ts)
+			if err != nil {
+				return nil, err
+			}
+			return taosTypes.TaosTimestamp{T: t, Precision: precision}, nil
+		}
+		return nil, fmt.Errorf("%v is not timestamp", v)
+	}
+	return nil, fmt.Errorf("unsupported type %s", typ)
+}
+
+func intParam(v interface{}, min, max int64) (int64, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("%v is not integer", v)
+	}
+	i, err := strconv.ParseInt(n.String(), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if i < min || i > max {
+		return 0, fmt.Errorf("%d out of range", i)
+	}
+	return i, nil
+}
+
+func uintParam(v interface{}, max uint64) (uint64, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("%v is not integer", v)
+	}
+	i, err := strconv.ParseUint(n.String(), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if i > max {
+		return 0, fmt.Errorf("%d out of range", i)
+	}
+	return i, nil
+}
+
+func floatParam(v interface{}) (float64, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("%v is not number", v)
+	}
+	return n.Float64()
+}
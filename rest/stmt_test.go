@@ -0,0 +1,51 @@
+package rest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/driver-go/v2/common"
+	taosTypes "github.com/taosdata/driver-go/v2/types"
+)
+
+func TestConvertParams(t *testing.T) {
+	types := []string{"timestamp", "int", "float", "binary", "bool", "bigint unsigned"}
+	rows := [][]interface{}{
+		{json.Number("1633017600000"), json.Number("1"), json.Number("1.5"), "a", true, json.Number("18446744073709551615")},
+		{"2021-10-01T00:00:00Z", nil, json.Number("2"), "b", false, json.Number("0")},
+	}
+	got, err := convertParams(types, rows, "")
+	assert.NoError(t, err)
+	ts := time.Unix(1633046400, 0)
+	assert.Equal(t, taosTypes.TaosTimestamp{T: common.TimestampConvertToTime(1633017600000, common.PrecisionMilliSecond), Precision: common.PrecisionMilliSecond}, got[0][0])
+	assert.Equal(t, taosTypes.TaosInt(1), got[0][1])
+	assert.Equal(t, taosTypes.TaosFloat(1.5), got[0][2])
+	assert.Equal(t, taosTypes.TaosBinary("a"), got[0][3])
+	assert.Equal(t, taosTypes.TaosBool(true), got[0][4])
+	assert.Equal(t, taosTypes.TaosUBigint(18446744073709551615), got[0][5])
+	assert.True(t, ts.Equal(got[1][0].(taosTypes.TaosTimestamp).T))
+	assert.Nil(t, got[1][1])
+}
+
+func TestConvertParamsError(t *testing.T) {
+	tests := []struct {
+		name      string
+		types     []string
+		rows      [][]interface{}
+		precision string
+	}{
+		{name: "count", types: []string{"int"}, rows: [][]interface{}{{json.Number("1"), json.Number("2")}}},
+		{name: "overflow", types: []string{"tinyint"}, rows: [][]interface{}{{json.Number("128")}}},
+		{name: "type", types: []string{"int"}, rows: [][]interface{}{{"1"}}},
+		{name: "unknown type", types: []string{"json"}, rows: [][]interface{}{{"1"}}},
+		{name: "precision", types: []string{"int"}, rows: [][]interface{}{{json.Number("1")}}, precision: "s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := convertParams(tt.types, tt.rows, tt.precision)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taosdata/blm3/tools/pool"
+	"github.com/taosdata/driver-go/v2/wrapper"
+)
+
+// streamWriter writes a TDEngineRestfulResp to the client batch by batch so that large result sets
+// never have to be held in memory. Status is written last, after the data, so that an error which
+// happens once the response has started can still be reported together with code and desc.
+type streamWriter struct {
+	c       *gin.Context
+	started bool
+	rows    int
+}
+
+func newStreamWriter(c *gin.Context) *streamWriter {
+	return &streamWriter{c: c}
+}
+
+func (w *streamWriter) isStarted() bool {
+	return w.started
+}
+
+func (w *streamWriter) writeHeader(header *wrapper.RowsHeader) error {
+	columnMeta := make([][]interface{}, 0, len(header.ColNames))
+	for i := 0; i < len(header.ColNames); i++ {
+		columnMeta = append(columnMeta, []interface{}{
+			header.ColNames[i],
+			header.ColTypes[i],
+			header.ColLength[i],
+		})
+	}
+	head, err := json.Marshal(header.ColNames)
+	if err != nil {
+		return err
+	}
+	meta, err := json.Marshal(columnMeta)
+	if err != nil {
+		return err
+	}
+	b := pool.BytesPoolGet()
+	defer pool.BytesPoolPut(b)
+	b.WriteString(`{"head":`)
+	b.Write(head)
+	b.WriteString(`,"column_meta":`)
+	b.Write(meta)
+	b.WriteString(`,"data":[`)
+	w.c.Header("Content-Type", "application/json; charset=utf-8")
+	w.c.Status(http.StatusOK)
+	w.started = true
+	_, err = w.c.Writer.Write(b.Bytes())
+	return err
+}
+
+func (w *streamWriter) writeRows(rows [][]driver.Value) error {
+	b := pool.BytesPoolGet()
+	defer pool.BytesPoolPut(b)
+	for _, row := range rows {
+		d, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		if w.rows != 0 {
+			b.WriteByte(',')
+		}
+		b.Write(d)
+		w.rows += 1
+	}
+	_, err := w.c.Writer.Write(b.Bytes())
+	if err != nil {
+		return err
+	}
+	w.c.Writer.Flush()
+	return nil
+}
+
+func (w *streamWriter) finish() error {
+	b := pool.BytesPoolGet()
+	defer pool.BytesPoolPut(b)
+	b.WriteString(`],"rows":`)
+	b.WriteString(strconv.Itoa(w.rows))
+	b.WriteString(`,"status":"succ"}`)
+	_, err := w.c.Writer.Write(b.Bytes())
+	return err
+}
+
+func (w *streamWriter) finishWithError(code int, desc string) error {
+	d, err := json.Marshal(desc)
+	if err != nil {
+		return err
+	}
+	b := pool.BytesPoolGet()
+	defer pool.BytesPoolPut(b)
+	b.WriteString(`],"rows":`)
+	b.WriteString(strconv.Itoa(w.rows))
+	b.WriteString(`,"status":"error","code":`)
+	b.WriteString(strconv.Itoa(code & 0xffff))
+	b.WriteString(`,"desc":`)
+	b.Write(d)
+	b.WriteByte('}')
+	_, err = w.c.Writer.Write(b.Bytes())
+	return err
+}
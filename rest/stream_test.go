@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/httperror"
+	"github.com/taosdata/driver-go/v2/wrapper"
+)
+
+func TestStreamWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	sw := newStreamWriter(c)
+	err := sw.writeHeader(&wrapper.RowsHeader{
+		ColNames:  []string{"ts", "v"},
+		ColTypes:  []uint8{9, 4},
+		ColLength: []uint16{8, 4},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, sw.writeRows([][]driver.Value{{"2021-10-01 00:00:00.000", int32(1)}}))
+	assert.NoError(t, sw.writeRows([][]driver.Value{{"2021-10-01 00:00:01.000", nil}, {"2021-10-01 00:00:02.000", int32(3)}}))
+	assert.NoError(t, sw.finish())
+	assert.Equal(t, 200, w.Code)
+	var resp TDEngineRestfulResp
+	err = json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, "succ", resp.Status)
+	assert.Equal(t, []string{"ts", "v"}, resp.Head)
+	assert.Equal(t, 3, resp.Rows)
+	assert.Equal(t, 3, len(resp.Data))
+	assert.Equal(t, []interface{}{"v", float64(4), float64(4)}, resp.ColumnMeta[1])
+	assert.Nil(t, resp.Data[1][1])
+}
+
+func TestStreamWriterError(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	sw := newStreamWriter(c)
+	err := sw.writeHeader(&wrapper.RowsHeader{
+		ColNames:  []string{"v"},
+		ColTypes:  []uint8{4},
+		ColLength: []uint16{4},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, sw.writeRows([][]driver.Value{{int32(1)}}))
+	assert.NoError(t, sw.finishWithError(httperror.HTTP_QUERY_ROWS_EXCEEDED, "query result rows exceed limit"))
+	var resp map[string]interface{}
+	err = json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, "error", resp["status"])
+	assert.Equal(t, float64(httperror.HTTP_QUERY_ROWS_EXCEEDED), resp["code"])
+	assert.Equal(t, float64(1), resp["rows"])
+}
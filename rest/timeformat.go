@@ -0,0 +1,96 @@
+package rest
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/driver-go/v2/common"
+	"github.com/taosdata/driver-go/v2/wrapper"
+)
+
+const TimezoneHeader = "Taos-Timezone"
+
+// requestTimeFunc returns the timestamp format function of a request. The timezone is taken from the tz query
+// parameter, then the Taos-Timezone header, then restful.timezone. The layout is taken from the timeLayout
+// query parameter, then restful.timeLayout.
+func requestTimeFunc(c *gin.Context) (wrapper.FormatTimeFunc, error) {
+	loc := config.Conf.Restful.Timezone
+	tz := c.Query("tz")
+	if len(tz) == 0 {
+		tz = c.GetHeader(TimezoneHeader)
+	}
+	if len(tz) != 0 {
+		var err error
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %s", tz)
+		}
+	}
+	layout := config.Conf.Restful.TimeLayout
+	if l := c.Query("timeLayout"); len(l) != 0 {
+		if !config.IsTimeLayout(l) {
+			return nil, fmt.Errorf("invalid timeLayout %s", l)
+		}
+		layout = l
+	}
+	return newTimeFunc(loc, layout), nil
+}
+
+func newTimeFunc(loc *time.Location, layout string) wrapper.FormatTimeFunc {
+	switch layout {
+	case "rfc3339":
+		return func(ts int64, precision int) driver.Value {
+			return common.TimestampConvertToTime(ts, precision).In(loc).Format(time.RFC3339Nano)
+		}
+	case "epoch":
+		return func(ts int64, precision int) driver.Value {
+			return ts
+		}
+	case "epoch_s":
+		return func(ts int64, precision int) driver.Value {
+			return common.TimestampConvertToTime(ts, precision).Unix()
+		}
+	case "epoch_ms":
+		return func(ts int64, precision int) driver.Value {
+			return epochIn(ts, precision, common.PrecisionMilliSecond)
+		}
+	case "epoch_us":
+		return func(ts int64, precision int) driver.Value {
+			return epochIn(ts, precision, common.PrecisionMicroSecond)
+		}
+	case "epoch_ns":
+		return func(ts int64, precision int) driver.Value {
+			return epochIn(ts, precision, common.PrecisionNanoSecond)
+		}
+	}
+	return func(ts int64, precision int) driver.Value {
+		t := common.TimestampConvertToTime(ts, precision).In(loc)
+		switch precision {
+		case common.PrecisionMilliSecond:
+			return t.Format(LayoutMillSecond)
+		case common.PrecisionMicroSecond:
+			return t.Format(LayoutMicroSecond)
+		case common.PrecisionNanoSecond:
+			return t.Format(LayoutNanoSecond)
+		}
+		panic("unsupported precision")
+	}
+}
+
+// epochIn converts ts from precision to target, rounding towards negative infinity like time.Unix.
+func epochIn(ts int64, precision, target int) int64 {
+	for ; precision < target; precision++ {
+		ts *= 1000
+	}
+	for ; precision > target; precision-- {
+		if ts < 0 && ts%1000 != 0 {
+			ts = ts/1000 - 1
+		} else {
+			ts /= 1000
+		}
+	}
+	return ts
+}
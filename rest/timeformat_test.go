@@ -0,0 +1,63 @@
+package rest
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/driver-go/v2/common"
+)
+
+func TestRequestTimeFunc(t *testing.T) {
+	config.Conf.Restful.Timezone = time.UTC
+	defer func() {
+		config.Conf.Restful.Timezone = time.Local
+	}()
+	// 2021-10-01 00:00:00.123 UTC
+	const ts = int64(1633046400123)
+	tests := []struct {
+		name    string
+		url     string
+		header  string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "default", url: "/", want: "2021-10-01 00:00:00.123"},
+		{name: "tz param", url: "/?tz=Asia/Shanghai", want: "2021-10-01 08:00:00.123"},
+		{name: "tz header", url: "/", header: "America/New_York", want: "2021-09-30 20:00:00.123"},
+		{name: "param over header", url: "/?tz=Asia/Shanghai", header: "America/New_York", want: "2021-10-01 08:00:00.123"},
+		{name: "rfc3339", url: "/?tz=Asia/Shanghai&timeLayout=rfc3339", want: "2021-10-01T08:00:00.123+08:00"},
+		{name: "epoch", url: "/?timeLayout=epoch", want: ts},
+		{name: "epoch_s", url: "/?timeLayout=epoch_s", want: int64(1633046400)},
+		{name: "epoch_us", url: "/?timeLayout=epoch_us", want: ts * 1000},
+		{name: "invalid tz", url: "/?tz=Mars/Olympus", wantErr: true},
+		{name: "invalid layout", url: "/?timeLayout=iso", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("POST", tt.url, nil)
+			if len(tt.header) != 0 {
+				c.Request.Header.Set(TimezoneHeader, tt.header)
+			}
+			timeFunc, err := requestTimeFunc(c)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, timeFunc(ts, common.PrecisionMilliSecond))
+		})
+	}
+}
+
+func TestEpochIn(t *testing.T) {
+	assert.Equal(t, int64(1500), epochIn(1500, common.PrecisionMicroSecond, common.PrecisionMicroSecond))
+	assert.Equal(t, int64(1), epochIn(1500, common.PrecisionMicroSecond, common.PrecisionMilliSecond))
+	assert.Equal(t, int64(-2), epochIn(-1500, common.PrecisionMicroSecond, common.PrecisionMilliSecond))
+	assert.Equal(t, int64(-1), epochIn(-1000000, common.PrecisionNanoSecond, common.PrecisionMilliSecond))
+	assert.Equal(t, int64(1500000000), epochIn(1500, common.PrecisionMilliSecond, common.PrecisionNanoSecond))
+}
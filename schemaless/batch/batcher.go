@@ -0,0 +1,165 @@
+package batch
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/taosdata/blm3/config"
+)
+
+var ErrClosed = errors.New("batcher closed")
+
+// Key identifies the lines which can be written by one schemaless insert.
+type Key struct {
+	DB       string
+	Protocol string
+	User     string
+}
+
+// FlushFunc writes a batch of newline separated lines.
+type FlushFunc func(key Key, data []byte, lines int)
+
+type buffer struct {
+	data  []byte
+	lines int
+	start time.Time
+}
+
+type batch struct {
+	key   Key
+	data  []byte
+	lines int
+}
+
+// Batcher accumulates lines per Key and hands a batch to the flush workers when it reaches the maximum lines or
+// bytes or when its oldest line has waited for the flush interval. Add blocks while every worker is busy and the
+// queue of batches is full, so slow inserts slow down the producers instead of dropping lines.
+type Batcher struct {
+	maxLines  int
+	maxBytes  int
+	interval  time.Duration
+	flush     FlushFunc
+	lock      sync.Mutex
+	buffers   map[Key]*buffer
+	out       chan *batch
+	sendLock  sync.RWMutex
+	closed    bool
+	closeChan chan struct{}
+	wg        sync.WaitGroup
+}
+
+// New starts a batcher with workers flush goroutines.
+func New(conf *config.Batch, workers int, flush FlushFunc) *Batcher {
+	if workers <= 0 {
+		workers = 1
+	}
+	b := &Batcher{
+		maxLines:  conf.MaxLines,
+		maxBytes:  int(conf.MaxBytes),
+		interval:  conf.FlushInterval,
+		flush:     flush,
+		buffers:   map[Key]*buffer{},
+		out:       make(chan *batch, workers),
+		closeChan: make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			for item := range b.out {
+				b.flush(item.key, item.data, item.lines)
+			}
+		}()
+	}
+	go b.tick()
+	return b
+}
+
+// Add appends data, one or more lines, to the batch of key.
+func (b *Batcher) Add(key Key, data []byte) error {
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return nil
+	}
+	b.sendLock.RLock()
+	defer b.sendLock.RUnlock()
+	if b.closed {
+		return ErrClosed
+	}
+	b.lock.Lock()
+	buf, exist := b.buffers[key]
+	if !exist {
+		buf = &buffer{start: time.Now()}
+		b.buffers[key] = buf
+	}
+	if len(buf.data) != 0 {
+		buf.data = append(buf.data, '\n')
+	}
+	buf.data = append(buf.data, data...)
+	buf.lines += bytes.Count(data, []byte{'\n'}) + 1
+	var full *batch
+	if buf.lines >= b.maxLines || len(buf.data) >= b.maxBytes {
+		delete(b.buffers, key)
+		full = &batch{key: key, data: buf.data, lines: buf.lines}
+	}
+	b.lock.Unlock()
+	if full != nil {
+		b.out <- full
+	}
+	return nil
+}
+
+func (b *Batcher) tick() {
+	ticker := time.NewTicker(b.interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.closeChan:
+			return
+		case now := <-ticker.C:
+			b.flushBuffers(func(buf *buffer) bool {
+				return now.Sub(buf.start) >= b.interval
+			})
+		}
+	}
+}
+
+func (b *Batcher) flushBuffers(expired func(buf *buffer) bool) {
+	b.sendLock.RLock()
+	defer b.sendLock.RUnlock()
+	if b.closed {
+		return
+	}
+	var batches []*batch
+	b.lock.Lock()
+	for key, buf := range b.buffers {
+		if expired(buf) {
+			delete(b.buffers, key)
+			batches = append(batches, &batch{key: key, data: buf.data, lines: buf.lines})
+		}
+	}
+	b.lock.Unlock()
+	for _, item := range batches {
+		b.out <- item
+	}
+}
+
+// Close flushes the buffered lines and waits for the workers.
+func (b *Batcher) Close() {
+	b.sendLock.Lock()
+	if b.closed {
+		b.sendLock.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.closeChan)
+	for key, buf := range b.buffers {
+		delete(b.buffers, key)
+		b.out <- &batch{key: key, data: buf.data, lines: buf.lines}
+	}
+	close(b.out)
+	b.sendLock.Unlock()
+	b.wg.Wait()
+}
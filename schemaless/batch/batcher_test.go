@@ -0,0 +1,88 @@
+package batch
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/config"
+)
+
+type recorder struct {
+	lock    sync.Mutex
+	batches []string
+	lines   []int
+}
+
+func (r *recorder) flush(key Key, data []byte, lines int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.batches = append(r.batches, key.DB+":"+string(data))
+	r.lines = append(r.lines, lines)
+}
+
+func (r *recorder) get() ([]string, []int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.batches...), append([]int(nil), r.lines...)
+}
+
+func TestBatcherMaxLines(t *testing.T) {
+	r := &recorder{}
+	b := New(&config.Batch{MaxLines: 3, MaxBytes: 1 << 20, FlushInterval: time.Hour}, 1, r.flush)
+	key := Key{DB: "a", Protocol: "influxdb", User: "root"}
+	assert.NoError(t, b.Add(key, []byte("l1\n")))
+	assert.NoError(t, b.Add(key, []byte("l2\nl3\n")))
+	assert.NoError(t, b.Add(Key{DB: "b"}, []byte("l4")))
+	assert.Eventually(t, func() bool {
+		batches, _ := r.get()
+		return len(batches) == 1
+	}, time.Second, time.Millisecond)
+	b.Close()
+	batches, lines := r.get()
+	assert.Equal(t, []string{"a:l1\nl2\nl3", "b:l4"}, batches)
+	assert.Equal(t, []int{3, 1}, lines)
+	assert.Equal(t, ErrClosed, b.Add(key, []byte("l5")))
+}
+
+func TestBatcherInterval(t *testing.T) {
+	r := &recorder{}
+	b := New(&config.Batch{MaxLines: 100, MaxBytes: 1 << 20, FlushInterval: 20 * time.Millisecond}, 2, r.flush)
+	defer b.Close()
+	assert.NoError(t, b.Add(Key{DB: "a"}, []byte("l1")))
+	assert.Eventually(t, func() bool {
+		batches, _ := r.get()
+		return len(batches) == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestBatcherBackpressure(t *testing.T) {
+	release := make(chan struct{})
+	var lock sync.Mutex
+	flushed := 0
+	b := New(&config.Batch{MaxLines: 1, MaxBytes: 1 << 20, FlushInterval: time.Hour}, 1, func(Key, []byte, int) {
+		<-release
+		lock.Lock()
+		flushed += 1
+		lock.Unlock()
+	})
+	added := make(chan struct{})
+	go func() {
+		// one batch in the worker, one in the queue, the third blocks
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, b.Add(Key{}, []byte(strings.Repeat("x", i+1))))
+		}
+		close(added)
+	}()
+	select {
+	case <-added:
+		t.Fatal("add should block while the worker is busy")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-added
+	b.Close()
+	assert.Equal(t, 3, flushed)
+}
@@ -1,7 +1,6 @@
 package capi
 
 import (
-	"strings"
 	"unsafe"
 
 	"github.com/taosdata/blm3/httperror"
@@ -9,35 +8,15 @@ import (
 	"github.com/taosdata/driver-go/v2/wrapper"
 )
 
-type Result struct {
-	SuccessCount int
-	FailCount    int
-	ErrorList    []string
-}
-
+// InsertInfluxdb writes the lines of data in one call, when it fails the lines are split in halves until the failed
+// lines are found so the other lines are still written. The error is returned when more than one line is sent and
+// every line fails with the same error, such as a lost connection.
 func InsertInfluxdb(taosConnect unsafe.Pointer, data []byte, db, precision string) (*Result, error) {
 	code := wrapper.TaosSelectDB(taosConnect, db)
 	if code != httperror.SUCCESS {
 		return nil, tErrors.GetError(code)
 	}
-	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
-	result := wrapper.TaosSchemalessInsert(taosConnect, lines, wrapper.InfluxDBLineProtocol, precision)
-	code = wrapper.TaosError(result)
-	if code != 0 {
-		errStr := wrapper.TaosErrorStr(result)
-		wrapper.TaosFreeResult(result)
-		return nil, &tErrors.TaosError{
-			Code:   int32(code) & 0xffff,
-			ErrStr: errStr,
-		}
-	}
-	successCount := wrapper.TaosAffectedRows(result)
-	failCount := len(lines) - successCount
-	r := &Result{
-		SuccessCount: successCount,
-		FailCount:    failCount,
-		ErrorList:    nil,
-	}
-	wrapper.TaosFreeResult(result)
-	return r, nil
+	return insertLines(splitLines(data), func(lines []string) (int, error) {
+		return schemalessInsert(taosConnect, lines, wrapper.InfluxDBLineProtocol, precision)
+	})
 }
@@ -0,0 +1,103 @@
+package capi
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"github.com/taosdata/blm3/tools/taoserror"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
+	"github.com/taosdata/driver-go/v2/wrapper"
+)
+
+type Result struct {
+	SuccessCount int
+	FailCount    int
+	ErrorList    []string
+	// Errors holds the failed lines in order, ErrorList holds the same errors formatted
+	Errors []*LineError
+}
+
+// LineError is the error of one line, Line is 1-based and counts every line of the request.
+type LineError struct {
+	Line    int
+	Content string
+	Err     error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err.Error())
+}
+
+type line struct {
+	number  int
+	content string
+}
+
+// splitLines drops empty and comment lines and keeps the line numbers.
+func splitLines(data []byte) []*line {
+	var lines []*line
+	for i, l := range strings.Split(string(data), "\n") {
+		l = strings.TrimSpace(l)
+		if len(l) == 0 || l[0] == '#' {
+			continue
+		}
+		lines = append(lines, &line{number: i + 1, content: l})
+	}
+	return lines
+}
+
+// insertLines inserts lines and bisects a failed batch down to the failed lines. Network, connection, server
+// unavailable and auth errors fail the whole batch and are returned instead of a result.
+func insertLines(lines []*line, insert func([]string) (int, error)) (*Result, error) {
+	r := &Result{}
+	if len(lines) == 0 {
+		return r, nil
+	}
+	err := bisect(lines, insert, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func bisect(lines []*line, insert func([]string) (int, error), r *Result) error {
+	contents := make([]string, len(lines))
+	for i, l := range lines {
+		contents[i] = l.content
+	}
+	affected, err := insert(contents)
+	if err == nil {
+		r.SuccessCount += affected
+		return nil
+	}
+	if taoserror.IsUnavailable(err) || taoserror.IsAuthFailure(err) {
+		return err
+	}
+	if len(lines) == 1 {
+		e := &LineError{Line: lines[0].number, Content: lines[0].content, Err: err}
+		r.FailCount += 1
+		r.Errors = append(r.Errors, e)
+		r.ErrorList = append(r.ErrorList, e.Error())
+		return nil
+	}
+	half := len(lines) / 2
+	err = bisect(lines[:half], insert, r)
+	if err != nil {
+		return err
+	}
+	return bisect(lines[half:], insert, r)
+}
+
+func schemalessInsert(taosConnect unsafe.Pointer, lines []string, protocol int, precision string) (int, error) {
+	result := wrapper.TaosSchemalessInsert(taosConnect, lines, protocol, precision)
+	defer wrapper.TaosFreeResult(result)
+	code := wrapper.TaosError(result)
+	if code != 0 {
+		return 0, &tErrors.TaosError{
+			Code:   int32(code) & 0xffff,
+			ErrStr: wrapper.TaosErrorStr(result),
+		}
+	}
+	return wrapper.TaosAffectedRows(result), nil
+}
@@ -0,0 +1,57 @@
+package capi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
+)
+
+func syntaxError(l string) error {
+	return &tErrors.TaosError{Code: tErrors.TSC_LINE_SYNTAX_ERROR, ErrStr: "syntax error " + l}
+}
+
+func TestInsertLines(t *testing.T) {
+	lines := splitLines([]byte("cpu value=1\n\n# comment\ncpu value=bad\ncpu value=3\r\nmem value=\"x\nmem value=5\n"))
+	var calls int
+	insert := func(lines []string) (int, error) {
+		calls += 1
+		for _, l := range lines {
+			if strings.Contains(l, "bad") || strings.Contains(l, `"`) {
+				return 0, syntaxError(l)
+			}
+		}
+		return len(lines), nil
+	}
+	r, err := insertLines(lines, insert)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, r.SuccessCount)
+	assert.Equal(t, 2, r.FailCount)
+	assert.Equal(t, []string{"line 4: [0x21b] syntax error cpu value=bad", `line 6: [0x21b] syntax error mem value="x`}, r.ErrorList)
+	assert.Equal(t, 4, r.Errors[0].Line)
+	assert.Equal(t, "cpu value=bad", r.Errors[0].Content)
+
+	calls = 0
+	r, err = insertLines(splitLines([]byte("cpu value=1\ncpu value=2")), insert)
+	assert.NoError(t, err)
+	assert.Equal(t, &Result{SuccessCount: 2}, r)
+	assert.Equal(t, 1, calls)
+}
+
+func TestInsertLinesAllFailed(t *testing.T) {
+	lost := &tErrors.TaosError{Code: tErrors.RPC_NETWORK_UNAVAIL, ErrStr: "Unable to establish connection"}
+	var calls int
+	_, err := insertLines(splitLines([]byte("cpu value=1\ncpu value=2\ncpu value=3")), func([]string) (int, error) {
+		calls += 1
+		return 0, lost
+	})
+	assert.Equal(t, lost, err)
+	assert.Equal(t, 1, calls)
+	// the same data error on every line is reported per line
+	r, err := insertLines(splitLines([]byte("cpu value=bad\ncpu value=bad")), func(lines []string) (int, error) {
+		return 0, syntaxError(lines[0])
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, r.FailCount)
+}
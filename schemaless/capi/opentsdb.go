@@ -46,3 +46,15 @@ func InsertOpentsdbTelnet(taosConnect unsafe.Pointer, data, db string) error {
 	wrapper.TaosFreeResult(result)
 	return nil
 }
+
+// InsertOpentsdbTelnetBatch writes the telnet lines of data in one call, the failed lines are found like
+// InsertInfluxdb does.
+func InsertOpentsdbTelnetBatch(taosConnect unsafe.Pointer, data []byte, db string) (*Result, error) {
+	code := wrapper.TaosSelectDB(taosConnect, db)
+	if code != httperror.SUCCESS {
+		return nil, tErrors.GetError(code)
+	}
+	return insertLines(splitLines(data), func(lines []string) (int, error) {
+		return schemalessInsert(taosConnect, lines, wrapper.OpenTSDBTelnetLineProtocol, "")
+	})
+}
@@ -2,12 +2,32 @@ package thread
 
 import (
 	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 var c chan struct{}
 
+var (
+	waiting     int64
+	lockWaits   = prometheus.NewCounter(prometheus.CounterOpts{Namespace: "blm3", Subsystem: "thread", Name: "lock_waits_total", Help: "Number of locks which waited for a free slot."})
+	lockWaitSec = prometheus.NewCounter(prometheus.CounterOpts{Namespace: "blm3", Subsystem: "thread", Name: "lock_wait_seconds_total", Help: "Time spent waiting for a free slot."})
+)
+
 func Lock() {
+	select {
+	case c <- struct{}{}:
+		return
+	default:
+	}
+	atomic.AddInt64(&waiting, 1)
+	start := time.Now()
 	c <- struct{}{}
+	atomic.AddInt64(&waiting, -1)
+	lockWaits.Inc()
+	lockWaitSec.Add(time.Since(start).Seconds())
 }
 
 func Unlock() {
@@ -16,4 +36,14 @@ func Unlock() {
 
 func init() {
 	c = make(chan struct{}, runtime.NumCPU())
+	prometheus.MustRegister(
+		lockWaits,
+		lockWaitSec,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{Namespace: "blm3", Subsystem: "thread", Name: "lock_waiting", Help: "Number of goroutines waiting for a lock."}, func() float64 {
+			return float64(atomic.LoadInt64(&waiting))
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{Namespace: "blm3", Subsystem: "thread", Name: "lock_held", Help: "Number of locks held."}, func() float64 {
+			return float64(len(c))
+		}),
+	)
 }
@@ -0,0 +1,100 @@
+package certauth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path"
+
+	"github.com/taosdata/blm3/config"
+)
+
+// TLSConfig returns the tls config of the server which verifies client certificates against ssl.clientCAFile, nil
+// when client certificates are not enabled.
+func TLSConfig(conf *config.SSl) (*tls.Config, error) {
+	if len(conf.ClientCAFile) == 0 {
+		return nil, nil
+	}
+	pool, err := loadCAs(conf.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	clientAuth := tls.VerifyClientCertIfGiven
+	if conf.ClientAuth == config.ClientAuthRequire {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+	return &tls.Config{ClientCAs: pool, ClientAuth: clientAuth}, nil
+}
+
+// ListenerConfig returns the tls config of the tcp listener of a plugin, a client certificate is required when the
+// listener has a client CA bundle.
+func ListenerConfig(conf *config.ListenerTLS, ssl *config.SSl) (*tls.Config, error) {
+	certFile, keyFile := conf.CertFile, conf.KeyFile
+	if len(certFile) == 0 {
+		certFile, keyFile = ssl.CertFile, ssl.KeyFile
+	}
+	if len(certFile) == 0 || len(keyFile) == 0 {
+		return nil, errors.New("tls needs a certificate and key, of the plugin or of ssl")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if len(conf.ClientCAFile) != 0 {
+		tlsConfig.ClientCAs, err = loadCAs(conf.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+func loadCAs(file string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("no certificate found in " + file)
+	}
+	return pool, nil
+}
+
+// User returns the TDengine user of the verified client certificate of r, false when r has no verified certificate
+// or ssl.clientUsers has no match.
+func User(r *http.Request) (user, password string, ok bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 || config.Conf == nil {
+		return "", "", false
+	}
+	u := Match(config.Conf.SSl.ClientUsers, r.TLS.VerifiedChains[0][0])
+	if u == nil {
+		return "", "", false
+	}
+	return u.User, u.Password, true
+}
+
+// Match returns the first of users matching cert.
+func Match(users []*config.CertUser, cert *x509.Certificate) *config.CertUser {
+	for _, u := range users {
+		var values []string
+		switch u.Field {
+		case "cn":
+			values = []string{cert.Subject.CommonName}
+		case "dns":
+			values = cert.DNSNames
+		case "email":
+			values = cert.EmailAddresses
+		}
+		for _, v := range values {
+			if matched, _ := path.Match(u.Pattern, v); matched {
+				return u
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package certauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/config"
+)
+
+func newCert(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return cert, key, der
+}
+
+func TestUser(t *testing.T) {
+	defer func(conf *config.Config) {
+		config.Conf = conf
+	}(config.Conf)
+	config.Conf = &config.Config{SSl: config.SSl{ClientUsers: []*config.CertUser{
+		{Field: "dns", Pattern: "edge-*.example.com", User: "edge", Password: "edge_pass"},
+		{Field: "cn", Pattern: "collector", User: "collector", Password: "collector_pass"},
+	}}}
+	ca, caKey, caDer := newCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "blm3 test ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDer}), 0600))
+	tlsConfig, err := TLSConfig(&config.SSl{ClientCAFile: caFile, ClientAuth: config.ClientAuthOptional})
+	assert.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, ok := User(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(user))
+	}))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(template *x509.Certificate) (int, string) {
+		// a new transport for each certificate as connections are reused
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		client := &http.Client{Transport: transport}
+		if template != nil {
+			_, key, der := newCert(t, template, ca, caKey)
+			transport.TLSClientConfig.Certificates = []tls.Certificate{{
+				Certificate: [][]byte{der},
+				PrivateKey:  key,
+			}}
+		}
+		defer transport.CloseIdleConnections()
+		resp, err := client.Get(server.URL)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	clientUsage := []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	code, user := get(&x509.Certificate{Subject: pkix.Name{CommonName: "device"}, DNSNames: []string{"edge-01.example.com"}, ExtKeyUsage: clientUsage})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "edge", user)
+	code, user = get(&x509.Certificate{Subject: pkix.Name{CommonName: "collector"}, ExtKeyUsage: clientUsage})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "collector", user)
+	code, _ = get(&x509.Certificate{Subject: pkix.Name{CommonName: "unknown"}, ExtKeyUsage: clientUsage})
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = get(nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestListenerConfig(t *testing.T) {
+	dir := t.TempDir()
+	cert, key, der := newCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "blm3"}}, nil, nil)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	certFile := filepath.Join(dir, "blm3.crt")
+	keyFile := filepath.Join(dir, "blm3.key")
+	assert.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	_, err = ListenerConfig(&config.ListenerTLS{Enable: true}, &config.SSl{})
+	assert.Error(t, err)
+	tlsConfig, err := ListenerConfig(&config.ListenerTLS{Enable: true}, &config.SSl{CertFile: certFile, KeyFile: keyFile})
+	assert.NoError(t, err)
+	assert.Equal(t, der, tlsConfig.Certificates[0].Certificate[0])
+	assert.Equal(t, tls.NoClientCert, tlsConfig.ClientAuth)
+
+	tlsConfig, err = ListenerConfig(&config.ListenerTLS{Enable: true, CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile}, &config.SSl{})
+	assert.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+	_, err = cert.Verify(x509.VerifyOptions{Roots: tlsConfig.ClientCAs})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,138 @@
+package limit
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taosdata/blm3/config"
+)
+
+// userKey is the context key of the user set by the auth handlers of rest and the plugins.
+const userKey = "user"
+
+// lock guards the limiters which Reset replaces while requests are served.
+var (
+	lock  sync.RWMutex
+	users *Limiter
+	ips   *Limiter
+)
+
+func limiters() (*Limiter, *Limiter) {
+	lock.RLock()
+	u, i := users, ips
+	lock.RUnlock()
+	if u != nil {
+		return u, i
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if users == nil {
+		conf := config.GetLimit()
+		users = New(&conf.User)
+		ips = New(&conf.IP)
+	}
+	return users, ips
+}
+
+// Reset replaces the limiters with limiters of the rules of conf, the counts start again and the running requests
+// release the limiter they were admitted by. limit.enable only takes effect after a restart.
+func Reset(conf *config.Limit) {
+	u, i := New(&conf.User), New(&conf.IP)
+	lock.Lock()
+	users, ips = u, i
+	lock.Unlock()
+}
+
+// IPHandler limits the requests of the client ip, it runs before the auth handler so that requests with bad
+// credentials are limited as well. A rejected request is answered 429 by errHandler with a Retry-After header. It does
+// nothing unless limit.enable is set.
+func IPHandler(errHandler func(c *gin.Context, code int, err error)) gin.HandlerFunc {
+	if config.Conf == nil || !config.Conf.Limit.Enable {
+		return func(c *gin.Context) {}
+	}
+	return handler(func() *Limiter {
+		_, ips := limiters()
+		return ips
+	}, "ip", (*gin.Context).ClientIP, errHandler)
+}
+
+// UserHandler limits the requests of the user resolved by the preceding auth handler like IPHandler.
+func UserHandler(errHandler func(c *gin.Context, code int, err error)) gin.HandlerFunc {
+	if config.Conf == nil || !config.Conf.Limit.Enable {
+		return func(c *gin.Context) {}
+	}
+	return handler(func() *Limiter {
+		users, _ := limiters()
+		return users
+	}, "user", func(c *gin.Context) string {
+		return c.GetString(userKey)
+	}, errHandler)
+}
+
+func handler(limiter func() *Limiter, kind string, key func(c *gin.Context) string, errHandler func(c *gin.Context, code int, err error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k := key(c)
+		if len(k) == 0 {
+			return
+		}
+		release, retryAfter, err := limiter().Acquire(k, c.Request.ContentLength)
+		if err != nil {
+			rejected.WithLabelValues(kind, reason(err)).Inc()
+			c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+			errHandler(c, http.StatusTooManyRequests, err)
+			c.Abort()
+			return
+		}
+		var body *countingBody
+		if c.Request.ContentLength < 0 && c.Request.Body != nil {
+			body = &countingBody{ReadCloser: c.Request.Body}
+			c.Request.Body = body
+		}
+		defer func() {
+			var read int64
+			if body != nil {
+				read = atomic.LoadInt64(&body.n)
+			}
+			release(read)
+		}()
+		c.Next()
+	}
+}
+
+func reason(err error) string {
+	switch err {
+	case ErrBytesRate:
+		return "bytes"
+	case ErrConcurrency:
+		return "concurrency"
+	default:
+		return "rate"
+	}
+}
+
+// countingBody counts the bytes read from a body of unknown size.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(&b.n, int64(n))
+	return n, err
+}
+
+// retryAfterSeconds rounds d up to the whole seconds of a Retry-After header.
+func retryAfterSeconds(d time.Duration) int {
+	s := int(math.Ceil(d.Seconds()))
+	if s < 1 {
+		return 1
+	}
+	return s
+}
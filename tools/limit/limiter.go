@@ -0,0 +1,132 @@
+package limit
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/taosdata/blm3/config"
+	"golang.org/x/time/rate"
+)
+
+var (
+	ErrRate        = errors.New("request rate limit exceeded")
+	ErrBytesRate   = errors.New("request bytes rate limit exceeded")
+	ErrConcurrency = errors.New("concurrent request limit exceeded")
+)
+
+const (
+	// buckets idle for idleTimeout are removed
+	idleTimeout   = 10 * time.Minute
+	sweepInterval = time.Minute
+)
+
+// Limiter limits the requests of each key with token buckets of requests and of request bytes per second and with a
+// cap of concurrent requests.
+type Limiter struct {
+	rate        rate.Limit
+	burst       int
+	bytesRate   rate.Limit
+	bytesBurst  int
+	concurrency int
+	lock        sync.Mutex
+	buckets     map[string]*bucket
+	lastSweep   time.Time
+}
+
+type bucket struct {
+	requests *rate.Limiter
+	bytes    *rate.Limiter
+	running  int
+	lastUsed time.Time
+}
+
+func New(rule *config.LimitRule) *Limiter {
+	l := &Limiter{
+		rate:        rate.Inf,
+		bytesRate:   rate.Inf,
+		concurrency: rule.Concurrency,
+		buckets:     map[string]*bucket{},
+		lastSweep:   time.Now(),
+	}
+	if rule.Rate > 0 {
+		l.rate = rate.Limit(rule.Rate)
+		l.burst = rule.Burst
+		if l.burst == 0 {
+			l.burst = int(math.Ceil(rule.Rate))
+		}
+	}
+	if rule.BytesRate > 0 {
+		l.bytesRate = rate.Limit(rule.BytesRate)
+		l.bytesBurst = int(rule.BytesRate)
+	}
+	return l
+}
+
+// Acquire admits a request of key with a body of size bytes, -1 when the size is unknown. The returned release
+// function must be called with the bytes read from a body of unknown size when the request finishes. A rejected
+// request returns the error and the time to wait before retrying.
+func (l *Limiter) Acquire(key string, size int64) (release func(read int64), retryAfter time.Duration, err error) {
+	now := time.Now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	b := l.get(key, now)
+	if l.concurrency > 0 && b.running >= l.concurrency {
+		return nil, time.Second, ErrConcurrency
+	}
+	request := b.requests.ReserveN(now, 1)
+	if delay := request.DelayFrom(now); delay > 0 {
+		request.CancelAt(now)
+		return nil, delay, ErrRate
+	}
+	if size > 0 {
+		body := b.bytes.ReserveN(now, l.bodyTokens(size))
+		if delay := body.DelayFrom(now); delay > 0 {
+			body.CancelAt(now)
+			request.CancelAt(now)
+			return nil, delay, ErrBytesRate
+		}
+	}
+	b.running += 1
+	return func(read int64) {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		b.running -= 1
+		now := time.Now()
+		b.lastUsed = now
+		if size < 0 && read > 0 {
+			// the bytes of a body of unknown size are charged afterwards and delay the next requests
+			b.bytes.ReserveN(now, l.bodyTokens(read))
+		}
+	}, 0, nil
+}
+
+// bodyTokens returns the tokens of size bytes, a body larger than the burst takes all of the bucket.
+func (l *Limiter) bodyTokens(size int64) int {
+	if l.bytesRate == rate.Inf || size > int64(l.bytesBurst) {
+		return l.bytesBurst
+	}
+	return int(size)
+}
+
+func (l *Limiter) get(key string, now time.Time) *bucket {
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.lastSweep = now
+		for k, b := range l.buckets {
+			if b.running == 0 && now.Sub(b.lastUsed) >= idleTimeout {
+				delete(l.buckets, k)
+			}
+		}
+	}
+	b, exist := l.buckets[key]
+	if !exist {
+		b = &bucket{
+			requests: rate.NewLimiter(l.rate, l.burst),
+			bytes:    rate.NewLimiter(l.bytesRate, l.bytesBurst),
+		}
+		l.buckets[key] = b
+	}
+	b.lastUsed = now
+	return b
+}
@@ -0,0 +1,123 @@
+package limit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/config"
+)
+
+func TestRate(t *testing.T) {
+	l := New(&config.LimitRule{Rate: 1, Burst: 2})
+	for i := 0; i < 2; i++ {
+		release, _, err := l.Acquire("root", -1)
+		assert.NoError(t, err)
+		release(0)
+	}
+	_, retryAfter, err := l.Acquire("root", -1)
+	assert.Equal(t, ErrRate, err)
+	assert.Greater(t, int64(retryAfter), int64(0))
+	// keys have their own buckets
+	_, _, err = l.Acquire("other", -1)
+	assert.NoError(t, err)
+}
+
+func TestConcurrency(t *testing.T) {
+	l := New(&config.LimitRule{Concurrency: 1})
+	release, _, err := l.Acquire("root", -1)
+	assert.NoError(t, err)
+	_, _, err = l.Acquire("root", -1)
+	assert.Equal(t, ErrConcurrency, err)
+	release(0)
+	_, _, err = l.Acquire("root", -1)
+	assert.NoError(t, err)
+}
+
+func TestBytesRate(t *testing.T) {
+	l := New(&config.LimitRule{BytesRate: 100})
+	release, _, err := l.Acquire("root", 80)
+	assert.NoError(t, err)
+	release(0)
+	_, _, err = l.Acquire("root", 80)
+	assert.Equal(t, ErrBytesRate, err)
+	// a rejected request does not take tokens
+	_, _, err = l.Acquire("root", 20)
+	assert.NoError(t, err)
+
+	// bodies of unknown size are charged when they finish
+	release, _, err = l.Acquire("unknown", -1)
+	assert.NoError(t, err)
+	release(1000)
+	_, _, err = l.Acquire("unknown", 1)
+	assert.Equal(t, ErrBytesRate, err)
+}
+
+func TestHandler(t *testing.T) {
+	defer func(conf *config.Config) {
+		config.Conf = conf
+		users, ips = nil, nil
+	}(config.Conf)
+	config.Conf = &config.Config{Limit: config.Limit{
+		Enable: true,
+		User:   config.LimitRule{Rate: 1, Burst: 1},
+		IP:     config.LimitRule{BytesRate: 12},
+	}}
+	users, ips = nil, nil
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	errHandler := func(c *gin.Context, code int, err error) {
+		c.JSON(code, gin.H{"message": err.Error()})
+	}
+	router.POST("write", IPHandler(errHandler), func(c *gin.Context) {
+		c.Set(userKey, c.Query("u"))
+	}, UserHandler(errHandler), func(c *gin.Context) {
+		_, _ = ioutil.ReadAll(c.Request.Body)
+		c.Status(http.StatusNoContent)
+	})
+	post := func(user, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/write?u="+user, bytes.NewBufferString(body))
+		req.RemoteAddr = "192.0.2.1:6041"
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusNoContent, post("a", "1234").Code)
+	w := post("a", "1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), ErrRate.Error())
+	// the rejected request has been counted by the ip limit
+	assert.Equal(t, http.StatusNoContent, post("b", "1234").Code)
+	w = post("c", "1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), ErrBytesRate.Error())
+
+	// a reload replaces the rules
+	Reset(&config.Limit{Enable: true, User: config.LimitRule{Rate: 100}})
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusNoContent, post("a", "1234").Code)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	defer func(conf *config.Config) {
+		config.Conf = conf
+	}(config.Conf)
+	config.Conf = &config.Config{}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.GET("ping", IPHandler(nil), UserHandler(nil), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/ping", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	}
+}
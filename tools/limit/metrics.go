@@ -0,0 +1,13 @@
+package limit
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var rejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "blm3",
+	Name:      "rate_limited_requests_total",
+	Help:      "Number of requests rejected by the rate limits by key, user or ip, and limit, rate, bytes or concurrency.",
+}, []string{"key", "limit"})
+
+func init() {
+	prometheus.MustRegister(rejected)
+}
@@ -0,0 +1,148 @@
+package login
+
+import (
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+	"github.com/sirupsen/logrus"
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/blm3/db/commonpool"
+	"github.com/taosdata/blm3/log"
+	"github.com/taosdata/blm3/tools/taoserror"
+)
+
+var auditLogger = log.GetLogger("audit")
+
+var (
+	ErrLockedOut = errors.New("too many failed logins, try again later")
+	ErrRejected  = errors.New("Authentication failure")
+)
+
+var (
+	// rejected keeps the hash of the credentials taosd refused for login.failureCache
+	rejected = cache.New(time.Minute, time.Minute)
+	// failures counts the failed logins of each client ip and with login.lockUser of each user for login.lockout
+	failures = cache.New(time.Minute, time.Minute)
+	// accepted keeps the hash of the credentials taosd accepted for Check
+	accepted = cache.New(30*time.Minute, time.Hour)
+)
+
+// VerifyUser checks the password of user by connecting to taosd.
+var VerifyUser = func(user, password string) error {
+	conn, err := commonpool.GetConnection(user, password)
+	if err != nil {
+		return err
+	}
+	return conn.Put()
+}
+
+func credentialKey(user, password string) string {
+	sum := sha256.Sum256([]byte(user + "\x00" + password))
+	return string(sum[:])
+}
+
+// Authenticate checks user and password with taosd unless they have been refused recently or the client ip or with
+// login.lockUser the user is locked out, each attempt is written to the audit log. Only the credentials refused by
+// taosd are cached and counted, other errors such as an unreachable taosd are returned as they are.
+func Authenticate(c *gin.Context, action, user, password string) error {
+	conf := config.GetLogin()
+	ip := c.ClientIP()
+	entry := auditLogger.WithFields(logrus.Fields{"action": action, "user": user, "clientIP": ip})
+	var keys []string
+	if len(ip) != 0 {
+		keys = append(keys, "ip:"+ip)
+	}
+	if conf.LockUser {
+		keys = append(keys, "user:"+user)
+	}
+	if conf.MaxFailures > 0 && lockedOut(keys, conf.MaxFailures) {
+		entry.Warn("login rejected, locked out")
+		return ErrLockedOut
+	}
+	key := credentialKey(user, password)
+	var err error
+	if _, exist := rejected.Get(key); exist {
+		err = ErrRejected
+	} else {
+		err = VerifyUser(user, password)
+	}
+	if err != nil {
+		if err != ErrRejected && !taoserror.IsAuthFailure(err) {
+			entry.WithError(err).Warn("login not checked")
+			return err
+		}
+		if conf.FailureCache > 0 {
+			rejected.Set(key, struct{}{}, conf.FailureCache)
+		}
+		if conf.MaxFailures > 0 {
+			for _, k := range keys {
+				addFailure(k, &conf)
+			}
+		}
+		entry.WithError(err).Warn("login failed")
+		return ErrRejected
+	}
+	for _, k := range keys {
+		failures.Delete(k)
+	}
+	entry.Info("login succeeded")
+	return nil
+}
+
+// Check is Authenticate for the apis authenticating every request, the credentials taosd accepted are not checked
+// again for 30 minutes.
+func Check(c *gin.Context, action, user, password string) error {
+	key := credentialKey(user, password)
+	if _, exist := accepted.Get(key); exist {
+		return nil
+	}
+	err := Authenticate(c, action, user, password)
+	if err != nil {
+		return err
+	}
+	accepted.SetDefault(key, struct{}{})
+	return nil
+}
+
+// StatusCode returns the http status of an error returned by Authenticate for the apis answering with http status.
+func StatusCode(err error) int {
+	switch err {
+	case ErrRejected:
+		return http.StatusUnauthorized
+	case ErrLockedOut:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusServiceUnavailable
+}
+
+func lockedOut(keys []string, maxFailures int) bool {
+	for _, key := range keys {
+		if failureCount(key) >= maxFailures {
+			return true
+		}
+	}
+	return false
+}
+
+func failureCount(key string) int {
+	v, exist := failures.Get(key)
+	if !exist {
+		return 0
+	}
+	return v.(int)
+}
+
+func addFailure(key string, conf *config.Login) {
+	if failures.Add(key, 1, conf.Lockout) == nil {
+		return
+	}
+	n, err := failures.IncrementInt(key, 1)
+	if err == nil && n >= conf.MaxFailures {
+		// the lockout lasts login.lockout from the last failure
+		failures.Set(key, n, conf.Lockout)
+	}
+}
@@ -0,0 +1,98 @@
+package login
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/config"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
+)
+
+func stubVerifyUser(t *testing.T) *int {
+	gin.SetMode(gin.ReleaseMode)
+	f := VerifyUser
+	t.Cleanup(func() {
+		VerifyUser = f
+		rejected.Flush()
+		failures.Flush()
+		accepted.Flush()
+	})
+	calls := 0
+	VerifyUser = func(user, password string) error {
+		calls += 1
+		if password == "unavailable" {
+			return &tErrors.TaosError{Code: tErrors.RPC_NETWORK_UNAVAIL, ErrStr: "Unable to establish connection"}
+		}
+		if password != "taosdata" {
+			return &tErrors.TaosError{Code: tErrors.RPC_AUTH_FAILURE, ErrStr: "Authentication failure"}
+		}
+		return nil
+	}
+	return &calls
+}
+
+func context(ip string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest(http.MethodGet, "/", nil)
+	c.Request.RemoteAddr = ip + ":6041"
+	return c
+}
+
+func TestAuthenticate(t *testing.T) {
+	defer func(conf *config.Config) { config.Conf = conf }(config.Conf)
+	config.Conf = &config.Config{Login: config.Login{FailureCache: time.Minute, MaxFailures: 3, Lockout: time.Minute}}
+	calls := stubVerifyUser(t)
+	for i := 0; i < config.Conf.Login.MaxFailures; i++ {
+		assert.Equal(t, ErrRejected, Authenticate(context("10.0.0.1"), "test", "lock", "wrong"))
+	}
+	// the refused credentials are not sent to taosd again
+	assert.Equal(t, 1, *calls)
+	assert.Equal(t, ErrLockedOut, Authenticate(context("10.0.0.1"), "test", "other", "taosdata"))
+	// the user is only locked out from the failing ip unless login.lockUser is set
+	assert.NoError(t, Authenticate(context("10.0.0.2"), "test", "lock", "taosdata"))
+
+	// errors other than refused credentials are neither cached nor counted
+	*calls = 0
+	for i := 0; i < config.Conf.Login.MaxFailures+1; i++ {
+		err := Authenticate(context("10.0.0.4"), "test", "lock", "unavailable")
+		assert.Equal(t, int32(tErrors.RPC_NETWORK_UNAVAIL), err.(*tErrors.TaosError).Code)
+	}
+	assert.Equal(t, config.Conf.Login.MaxFailures+1, *calls)
+	assert.Equal(t, 0, failureCount("ip:10.0.0.4"))
+
+	// a successful login clears the failures of the ip
+	assert.Equal(t, ErrRejected, Authenticate(context("10.0.0.5"), "test", "lock", "wrong"))
+	assert.Equal(t, 1, failureCount("ip:10.0.0.5"))
+	assert.NoError(t, Authenticate(context("10.0.0.5"), "test", "lock", "taosdata"))
+	assert.Equal(t, 0, failureCount("ip:10.0.0.5"))
+
+	config.Conf.Login.LockUser = true
+	for i := 0; i < config.Conf.Login.MaxFailures; i++ {
+		_ = Authenticate(context("10.0.1."+strconv.Itoa(i)), "test", "user", "wrong"+strconv.Itoa(i))
+	}
+	assert.Equal(t, ErrLockedOut, Authenticate(context("10.0.0.6"), "test", "user", "taosdata"))
+}
+
+func TestCheck(t *testing.T) {
+	defer func(conf *config.Config) { config.Conf = conf }(config.Conf)
+	config.Conf = &config.Config{Login: config.Login{FailureCache: time.Minute, MaxFailures: 3, Lockout: time.Minute}}
+	calls := stubVerifyUser(t)
+	assert.NoError(t, Check(context("10.0.0.1"), "test", "root", "taosdata"))
+	assert.NoError(t, Check(context("10.0.0.1"), "test", "root", "taosdata"))
+	assert.Equal(t, 1, *calls)
+	// refused credentials are counted as with Authenticate
+	assert.Equal(t, ErrRejected, Check(context("10.0.0.1"), "test", "root", "wrong"))
+	assert.Equal(t, 1, failureCount("ip:10.0.0.1"))
+	assert.Equal(t, 2, *calls)
+}
+
+func TestStatusCode(t *testing.T) {
+	assert.Equal(t, http.StatusUnauthorized, StatusCode(ErrRejected))
+	assert.Equal(t, http.StatusTooManyRequests, StatusCode(ErrLockedOut))
+	assert.Equal(t, http.StatusServiceUnavailable, StatusCode(&tErrors.TaosError{Code: tErrors.RPC_NETWORK_UNAVAIL}))
+}
@@ -0,0 +1,154 @@
+package health
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/blm3/db/async"
+	"github.com/taosdata/blm3/db/commonpool"
+	"github.com/taosdata/blm3/log"
+	"github.com/taosdata/blm3/plugin"
+)
+
+var logger = log.GetLogger("health")
+
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+
+	checkTimeout = 5 * time.Second
+)
+
+type Check struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status  string                   `json:"status"`
+	Taosd   Check                    `json:"taosd"`
+	Plugins map[string]plugin.Status `json:"plugins,omitempty"`
+}
+
+// Checker serves /-/ready and /-/health, both respond 503 when taosd can not be queried, a plugin has failed or a
+// running plugin is not listening. /-/health also reports the state of every plugin.
+type Checker struct {
+	checkTaosd func(ctx context.Context) error
+	statuses   func() map[string]plugin.Status
+	lock       sync.Mutex
+	probe      *probe
+}
+
+// probe is a taosd check in flight, the checks which arrive meanwhile wait for its result instead of starting another
+// one, connecting to taosd does not stop at the deadline.
+type probe struct {
+	done chan struct{}
+	err  error
+}
+
+// New returns a checker which queries taosd as the user of conf.
+func New(conf *config.Monitor) *Checker {
+	return &Checker{
+		checkTaosd: func(ctx context.Context) error {
+			return roundTrip(ctx, conf.User, conf.Password)
+		},
+		statuses: plugin.Statuses,
+	}
+}
+
+func (h *Checker) Register(r gin.IRouter) {
+	r.GET("-/ready", h.ready)
+	r.GET("-/health", h.health)
+}
+
+func (h *Checker) ready(c *gin.Context) {
+	report := h.check(c.Request.Context())
+	report.Plugins = nil
+	respond(c, report)
+}
+
+func (h *Checker) health(c *gin.Context) {
+	respond(c, h.check(c.Request.Context()))
+}
+
+func (h *Checker) check(ctx context.Context) *Report {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	report := &Report{Status: StatusPass, Taosd: Check{Status: StatusPass}}
+	start := time.Now()
+	err := h.probeTaosd(ctx)
+	report.Taosd.Latency = time.Since(start).String()
+	if err != nil {
+		logger.WithError(err).Warn("check taosd error")
+		report.Status = StatusFail
+		report.Taosd.Status = StatusFail
+		report.Taosd.Error = err.Error()
+	}
+	report.Plugins = h.statuses()
+	for _, status := range report.Plugins {
+		if status.State == plugin.StateFailed || (status.State == plugin.StateRunning && status.Enabled && !status.Listening) {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+// probeTaosd waits for the result of the check in flight or starts one, it returns when ctx is done even if the check
+// hangs.
+func (h *Checker) probeTaosd(ctx context.Context) error {
+	h.lock.Lock()
+	p := h.probe
+	if p == nil {
+		p = &probe{done: make(chan struct{})}
+		h.probe = p
+		go func() {
+			checkCtx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+			p.err = h.checkTaosd(checkCtx)
+			cancel()
+			h.lock.Lock()
+			h.probe = nil
+			h.lock.Unlock()
+			close(p.done)
+		}()
+	}
+	h.lock.Unlock()
+	select {
+	case <-p.done:
+		return p.err
+	case <-ctx.Done():
+		return async.QueryTimeoutError
+	}
+}
+
+func respond(c *gin.Context, report *Report) {
+	code := http.StatusOK
+	if report.Status != StatusPass {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, report)
+}
+
+// roundTrip queries the server status through the connection pool, the query stops when ctx is done but connecting
+// blocks until taosc gives up.
+func roundTrip(ctx context.Context, user, password string) error {
+	conn, err := commonpool.GetConnection(user, password)
+	if err != nil {
+		return err
+	}
+	// the connection of a timed out probe goes back to the pool once taosc has freed its result
+	_, err = async.GlobalAsync.TaosExecStream(ctx, conn.TaosConnection, "select server_status()", nil, 0, nil, func(_ [][]driver.Value) error {
+		return nil
+	}, func() {
+		putErr := conn.Put()
+		if putErr != nil {
+			logger.WithError(putErr).Errorln("taos connect pool put error")
+		}
+	})
+	return err
+}
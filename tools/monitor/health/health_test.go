@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/plugin"
+)
+
+func serve(t *testing.T, h *Checker, path string) (int, *Report) {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	h.Register(router)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	router.ServeHTTP(w, req)
+	var report Report
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return w.Code, &report
+}
+
+func TestHealth(t *testing.T) {
+	var taosdErr error
+	statuses := map[string]plugin.Status{
+		"influxdb/v1": {State: plugin.StateRunning, Enabled: true, Listening: true},
+		"statsd/v1":   {State: plugin.StateDisabled},
+		"collectd/v1": {State: plugin.StateStopped, Enabled: true},
+	}
+	h := &Checker{
+		checkTaosd: func(ctx context.Context) error {
+			return taosdErr
+		},
+		statuses: func() map[string]plugin.Status {
+			return statuses
+		},
+	}
+
+	code, report := serve(t, h, "/-/health")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusPass, report.Status)
+	assert.Equal(t, StatusPass, report.Taosd.Status)
+	assert.Equal(t, statuses, report.Plugins)
+
+	code, report = serve(t, h, "/-/ready")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusPass, report.Status)
+	assert.Nil(t, report.Plugins)
+
+	statuses["collectd/v1"] = plugin.Status{State: plugin.StateRunning, Enabled: true, Listening: false}
+	code, report = serve(t, h, "/-/health")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, StatusPass, report.Taosd.Status)
+	statuses["collectd/v1"] = plugin.Status{State: plugin.StateFailed, Error: "address already in use", Enabled: true}
+	code, report = serve(t, h, "/-/health")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusFail, report.Status)
+	delete(statuses, "collectd/v1")
+
+	taosdErr = errors.New("Unable to establish connection")
+	code, report = serve(t, h, "/-/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, StatusFail, report.Taosd.Status)
+	assert.Equal(t, "Unable to establish connection", report.Taosd.Error)
+}
+
+func TestHealthHangingTaosd(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	h := &Checker{
+		checkTaosd: func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return nil
+		},
+		statuses: func() map[string]plugin.Status {
+			return nil
+		},
+	}
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		report := h.check(ctx)
+		cancel()
+		assert.Equal(t, StatusFail, report.Taosd.Status)
+	}
+	// the timed out checks share the hanging one
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	close(release)
+	assert.Eventually(t, func() bool {
+		return h.check(context.Background()).Status == StatusPass
+	}, time.Second, 10*time.Millisecond)
+}
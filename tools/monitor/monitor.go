@@ -0,0 +1,115 @@
+package monitor
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const namespace = "blm3"
+
+// Point states of PluginPoints.
+const (
+	PointsReceived = "received"
+	PointsInserted = "inserted"
+	PointsFailed   = "failed"
+	PointsDropped  = "dropped"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of http requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of http requests by route and method.",
+		Buckets:   []float64{.001, .005, .01, .05, .1, .5, 1, 5, 10, 30, 60},
+	}, []string{"route", "method"})
+	pluginPoints = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "plugin_points_total",
+		Help:      "Number of points by plugin and state, received, inserted, failed or dropped.",
+	}, []string{"plugin", "state"})
+)
+
+// ObserveRequest records a finished http request, route is the registered path.
+func ObserveRequest(route, method string, status int, duration time.Duration) {
+	if len(route) == 0 {
+		route = "unmatched"
+	}
+	httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	httpRequestDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// AddPoints adds n points in state of plugin.
+func AddPoints(plugin, state string, n int) {
+	if n <= 0 {
+		return
+	}
+	pluginPoints.WithLabelValues(plugin, state).Add(float64(n))
+}
+
+// Points returns the number of points in state by plugin.
+func Points(state string) map[string]float64 {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		pluginPoints.Collect(ch)
+		close(ch)
+	}()
+	points := map[string]float64{}
+	for m := range ch {
+		var metric dto.Metric
+		if m.Write(&metric) != nil {
+			continue
+		}
+		var plugin string
+		match := false
+		for _, label := range metric.GetLabel() {
+			switch label.GetName() {
+			case "plugin":
+				plugin = label.GetValue()
+			case "state":
+				match = label.GetValue() == state
+			}
+		}
+		if match {
+			points[plugin] = metric.GetCounter().GetValue()
+		}
+	}
+	return points
+}
+
+// CountLines returns the number of non empty lines of data.
+func CountLines(data []byte) int {
+	n := 0
+	for len(data) != 0 {
+		i := bytes.IndexByte(data, '\n')
+		var line []byte
+		if i < 0 {
+			line, data = data, nil
+		} else {
+			line, data = data[:i], data[i+1:]
+		}
+		if len(bytes.TrimSpace(line)) != 0 {
+			n += 1
+		}
+	}
+	return n
+}
+
+// Handler serves the metrics of the default registry, which also has the go runtime and process metrics.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+func init() {
+	prometheus.MustRegister(httpRequests, httpRequestDuration, pluginPoints)
+}
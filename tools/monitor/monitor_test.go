@@ -0,0 +1,48 @@
+package monitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountLines(t *testing.T) {
+	assert.Equal(t, 0, CountLines(nil))
+	assert.Equal(t, 1, CountLines([]byte("a")))
+	assert.Equal(t, 2, CountLines([]byte("a\n\n  \nb\n")))
+}
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.GET("metrics", Handler())
+	ObserveRequest("/influxdb/v1/write", http.MethodPost, http.StatusNoContent, 10*time.Millisecond)
+	ObserveRequest("", http.MethodGet, http.StatusNotFound, time.Millisecond)
+	AddPoints("statsd", PointsReceived, 3)
+	AddPoints("statsd", PointsDropped, 0)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `blm3_http_requests_total{method="POST",route="/influxdb/v1/write",status="204"} 1`)
+	assert.Contains(t, body, `blm3_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, body, `blm3_http_request_duration_seconds_count{method="POST",route="/influxdb/v1/write"} 1`)
+	assert.Contains(t, body, `blm3_plugin_points_total{plugin="statsd",state="received"} 3`)
+	assert.NotContains(t, body, `state="dropped"`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestPoints(t *testing.T) {
+	before := Points(PointsInserted)["collectd"]
+	AddPoints("collectd", PointsInserted, 2)
+	AddPoints("collectd", PointsInserted, 3)
+	AddPoints("collectd", PointsReceived, 7)
+	assert.Equal(t, before+5, Points(PointsInserted)["collectd"])
+	assert.NotContains(t, Points(PointsFailed), "collectd")
+}
@@ -0,0 +1,95 @@
+package reporter
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// toLines converts the metric families to influxdb lines, one line per metric with the metric name as measurement
+// and the labels plus tags as tags. Counters, gauges and untyped metrics have the field value, summaries and
+// histograms the fields count and sum.
+func toLines(families []*dto.MetricFamily, tags map[string]string, ts time.Time) []byte {
+	timestamp := strconv.FormatInt(ts.UnixNano()/int64(time.Millisecond), 10)
+	var b strings.Builder
+	for _, family := range families {
+		for _, m := range family.Metric {
+			var fields [][2]string
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				fields = appendField(fields, "value", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				fields = appendField(fields, "value", m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				fields = appendField(fields, "value", m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				fields = appendField(fields, "count", float64(m.GetSummary().GetSampleCount()))
+				fields = appendField(fields, "sum", m.GetSummary().GetSampleSum())
+			case dto.MetricType_HISTOGRAM:
+				fields = appendField(fields, "count", float64(m.GetHistogram().GetSampleCount()))
+				fields = appendField(fields, "sum", m.GetHistogram().GetSampleSum())
+			}
+			if len(fields) == 0 {
+				continue
+			}
+			b.WriteString(measurementEscaper.Replace(family.GetName()))
+			for _, tag := range metricTags(m, tags) {
+				b.WriteByte(',')
+				b.WriteString(tagEscaper.Replace(tag[0]))
+				b.WriteByte('=')
+				b.WriteString(tagEscaper.Replace(tag[1]))
+			}
+			for i, field := range fields {
+				if i == 0 {
+					b.WriteByte(' ')
+				} else {
+					b.WriteByte(',')
+				}
+				b.WriteString(field[0])
+				b.WriteByte('=')
+				b.WriteString(field[1])
+			}
+			b.WriteByte(' ')
+			b.WriteString(timestamp)
+			b.WriteByte('\n')
+		}
+	}
+	return []byte(b.String())
+}
+
+// appendField skips NaN and Inf values which influxdb lines can not hold.
+func appendField(fields [][2]string, name string, value float64) [][2]string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fields
+	}
+	return append(fields, [2]string{name, strconv.FormatFloat(value, 'g', -1, 64)})
+}
+
+// metricTags returns the labels of m and tags sorted by name, empty values are skipped.
+func metricTags(m *dto.Metric, tags map[string]string) [][2]string {
+	result := make([][2]string, 0, len(m.Label)+len(tags))
+	for _, label := range m.Label {
+		if _, exist := tags[label.GetName()]; exist || len(label.GetValue()) == 0 {
+			continue
+		}
+		result = append(result, [2]string{label.GetName(), label.GetValue()})
+	}
+	for name, value := range tags {
+		if len(value) != 0 {
+			result = append(result, [2]string{name, value})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i][0] < result[j][0]
+	})
+	return result
+}
@@ -0,0 +1,34 @@
+package reporter
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToLines(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total", Help: "requests"}, []string{"route", "status"})
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "free", Help: "free"})
+	nan := prometheus.NewGauge(prometheus.GaugeOpts{Name: "nan", Help: "nan"})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "duration_seconds", Help: "duration", Buckets: []float64{1}})
+	registry.MustRegister(counter, gauge, nan, histogram)
+	counter.WithLabelValues("/influxdb/v1/write", "204").Add(2)
+	counter.WithLabelValues("a b,c=d", "").Inc()
+	gauge.Set(3.5)
+	nan.Set(math.NaN())
+	histogram.Observe(0.5)
+	histogram.Observe(2)
+
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	ts := time.Unix(1626006833, 639000000)
+	lines := toLines(families, map[string]string{"server_id": "abc", "host": "h1"}, ts)
+	assert.Equal(t, "duration_seconds,host=h1,server_id=abc count=2,sum=2.5 1626006833639\n"+
+		"free,host=h1,server_id=abc value=3.5 1626006833639\n"+
+		`requests_total,host=h1,route=/influxdb/v1/write,server_id=abc,status=204 value=2 1626006833639`+"\n"+
+		`requests_total,host=h1,route=a\ b\,c\=d,server_id=abc value=1 1626006833639`+"\n", string(lines))
+}
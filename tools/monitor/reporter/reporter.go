@@ -0,0 +1,112 @@
+package reporter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/blm3/db/async"
+	"github.com/taosdata/blm3/db/commonpool"
+	"github.com/taosdata/blm3/log"
+	"github.com/taosdata/blm3/schemaless/capi"
+)
+
+var logger = log.GetLogger("monitor")
+
+// Reporter writes the metrics of the default prometheus registry to TDengine by the schemaless interface, tagged
+// with server_id and host so that the metrics of every blm3 can be kept in one database.
+type Reporter struct {
+	conf      *config.Monitor
+	gatherer  prometheus.Gatherer
+	tags      map[string]string
+	created   bool
+	closeChan chan struct{}
+	wg        sync.WaitGroup
+}
+
+func New(conf *config.Monitor) *Reporter {
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.WithError(err).Warn("get hostname error")
+	}
+	return &Reporter{
+		conf:     conf,
+		gatherer: prometheus.DefaultGatherer,
+		tags:     map[string]string{"server_id": log.ServerID, "host": hostname},
+	}
+}
+
+// Start reports the metrics every interval until Stop.
+func (r *Reporter) Start() {
+	if !r.conf.WriteToTD {
+		return
+	}
+	r.closeChan = make(chan struct{})
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.conf.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.closeChan:
+				return
+			case now := <-ticker.C:
+				err := r.report(now)
+				if err != nil {
+					logger.WithError(err).Error("report metrics error")
+				}
+			}
+		}
+	}()
+}
+
+func (r *Reporter) report(now time.Time) error {
+	families, err := r.gatherer.Gather()
+	if err != nil {
+		// the gathered families are still usable
+		logger.WithError(err).Warn("gather metrics error")
+	}
+	data := toLines(families, r.tags, now)
+	if len(data) == 0 {
+		return nil
+	}
+	conn, err := commonpool.GetConnection(r.conf.User, r.conf.Password)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		putErr := conn.Put()
+		if putErr != nil {
+			logger.WithError(putErr).Errorln("taos connect pool put error")
+		}
+	}()
+	if !r.created {
+		_, err = async.GlobalAsync.TaosExec(conn.TaosConnection, fmt.Sprintf("create database if not exists %s", r.conf.DB), nil)
+		if err != nil {
+			return err
+		}
+		r.created = true
+	}
+	result, err := capi.InsertInfluxdb(conn.TaosConnection, data, r.conf.DB, "ms")
+	if err != nil {
+		return err
+	}
+	if result.FailCount != 0 {
+		logger.Errorln("insert metrics error success:", result.SuccessCount, "fail:", result.FailCount, "errors:", strings.Join(result.ErrorList, ","))
+	}
+	return nil
+}
+
+// Stop waits for the running report.
+func (r *Reporter) Stop() {
+	if r.closeChan == nil {
+		return
+	}
+	close(r.closeChan)
+	r.wg.Wait()
+}
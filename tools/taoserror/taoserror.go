@@ -0,0 +1,66 @@
+// Package taoserror classifies the errors of taosd by their code.
+package taoserror
+
+import (
+	"errors"
+
+	tErrors "github.com/taosdata/driver-go/v2/errors"
+)
+
+// unavailable holds the codes of taosd which is not reachable or not ready to serve besides the rpc codes.
+var unavailable = map[int32]bool{
+	tErrors.TSC_INVALID_CONNECTION:   true,
+	tErrors.TSC_DISCONNECTED:         true,
+	tErrors.TSC_CONN_KILLED:          true,
+	tErrors.MND_INVALID_CONNECTION:   true,
+	tErrors.MND_TOO_MANY_SHELL_CONNS: true,
+	tErrors.MND_ACTION_IN_PROGRESS:   true,
+	tErrors.MND_NOT_READY:            true,
+	tErrors.MND_VGROUP_NOT_READY:     true,
+	tErrors.DND_ACTION_IN_PROGRESS:   true,
+	tErrors.VND_ACTION_IN_PROGRESS:   true,
+	tErrors.VND_IS_FLOWCTRL:          true,
+	tErrors.VND_IS_BALANCING:         true,
+	tErrors.VND_IS_CLOSING:           true,
+	tErrors.VND_NOT_SYNCED:           true,
+	tErrors.VND_IS_SYNCING:           true,
+	tErrors.QRY_NOT_READY:            true,
+}
+
+var authFailure = map[int32]bool{
+	tErrors.RPC_AUTH_REQUIRED:       true,
+	tErrors.RPC_AUTH_FAILURE:        true,
+	tErrors.TSC_INVALID_USER_LENGTH: true,
+	tErrors.TSC_INVALID_PASS_LENGTH: true,
+	tErrors.MND_INVALID_USER:        true,
+	tErrors.MND_INVALID_USER_FORMAT: true,
+	tErrors.MND_INVALID_PASS_FORMAT: true,
+}
+
+// Code returns the taosd code of err, ok is false when err does not come from taosd.
+func Code(err error) (code int32, ok bool) {
+	var taosErr *tErrors.TaosError
+	if !errors.As(err, &taosErr) {
+		return 0, false
+	}
+	return taosErr.Code & 0xffff, true
+}
+
+// IsUnavailable reports whether err is a network, connection or server unavailable error, the same request may
+// succeed later. Errors without a taosd code are unavailable errors as well.
+func IsUnavailable(err error) bool {
+	code, ok := Code(err)
+	if !ok {
+		return true
+	}
+	if code > 0 && code < 0x0100 {
+		return !authFailure[code]
+	}
+	return unavailable[code]
+}
+
+// IsAuthFailure reports whether err is a refused user or password.
+func IsAuthFailure(err error) bool {
+	code, ok := Code(err)
+	return ok && authFailure[code]
+}
@@ -0,0 +1,31 @@
+package taoserror
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tErrors "github.com/taosdata/driver-go/v2/errors"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err         error
+		unavailable bool
+		auth        bool
+	}{
+		{err: errors.New("connection pool exhausted"), unavailable: true},
+		{err: &tErrors.TaosError{Code: tErrors.RPC_NETWORK_UNAVAIL}, unavailable: true},
+		{err: &tErrors.TaosError{Code: int32(-2147483637)}, unavailable: true},
+		{err: fmt.Errorf("insert: %w", &tErrors.TaosError{Code: tErrors.VND_IS_SYNCING}), unavailable: true},
+		{err: &tErrors.TaosError{Code: tErrors.RPC_AUTH_FAILURE}, auth: true},
+		{err: &tErrors.TaosError{Code: tErrors.MND_INVALID_USER}, auth: true},
+		{err: &tErrors.TaosError{Code: tErrors.TSC_LINE_SYNTAX_ERROR}},
+		{err: &tErrors.TaosError{Code: tErrors.MND_INVALID_DB}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.unavailable, IsUnavailable(tt.err), tt.err.Error())
+		assert.Equal(t, tt.auth, IsAuthFailure(tt.err), tt.err.Error())
+	}
+}
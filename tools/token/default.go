@@ -0,0 +1,73 @@
+package token
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/taosdata/blm3/config"
+)
+
+var ErrNotInitialized = errors.New("token service not initialized")
+
+var (
+	lock    sync.RWMutex
+	service *Service
+)
+
+// Init creates the token service of blm3, calling it again loads rotated keys and keeps the revoked tokens.
+func Init(conf *config.Token) error {
+	s, err := New(conf)
+	if err != nil {
+		return err
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if service != nil {
+		service.lock.Lock()
+		s.revoked = service.revoked
+		service.lock.Unlock()
+	}
+	service = s
+	return nil
+}
+
+func current() (*Service, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	if service == nil {
+		return nil, ErrNotInitialized
+	}
+	return service, nil
+}
+
+func Issue(user, password string, scopes []string) (string, *Claims, error) {
+	s, err := current()
+	if err != nil {
+		return "", nil, err
+	}
+	return s.Issue(user, password, scopes)
+}
+
+func Verify(token, scope string) (*Claims, error) {
+	s, err := current()
+	if err != nil {
+		return nil, err
+	}
+	return s.Verify(token, scope)
+}
+
+func Revoke(claims *Claims) error {
+	s, err := current()
+	if err != nil {
+		return err
+	}
+	return s.Revoke(claims)
+}
+
+func RevokeUser(user string) error {
+	s, err := current()
+	if err != nil {
+		return err
+	}
+	return s.RevokeUser(user)
+}
@@ -0,0 +1,114 @@
+package token
+
+import (
+	"crypto/des"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/blm3/tools/pool"
+)
+
+// ErrLegacyDisabled is returned for a legacy DES token unless token.legacy is set.
+var ErrLegacyDisabled = errors.New("legacy token not allowed")
+
+var tokenCache = cache.New(30*time.Minute, time.Hour)
+
+var desKey = []byte{
+	64,
+	182,
+	122,
+	48,
+	86,
+	115,
+	253,
+	68,
+}
+
+func DecodeDes(auth string) (user, password string, err error) {
+	d, err := base64.StdEncoding.DecodeString(auth)
+	if err != nil {
+		return "", "", err
+	}
+	if len(d) != 48 {
+		return "", "", errors.New("wrong des length")
+	}
+	block, err := des.NewCipher(desKey)
+	if err != nil {
+		return "", "", err
+	}
+	b := pool.BytesPoolGet()
+	defer pool.BytesPoolPut(b)
+	for i := 0; i < 6; i++ {
+		origData := make([]byte, 8)
+		block.Decrypt(origData, d[i*8:+(i+1)*8])
+		b.Write(origData)
+		if i == 2 {
+			user, err = b.ReadString(0)
+			if err == nil {
+				user = user[:len(user)-1]
+			}
+			b.Reset()
+		}
+	}
+	password, err = b.ReadString(0)
+	if err == nil {
+		password = password[:len(password)-1]
+	}
+	return user, password, nil
+}
+
+func EncodeDes(user, password string) (string, error) {
+	if len(user) > 24 || len(password) > 24 {
+		return "", errors.New("wrong user or password length")
+	}
+
+	b := make([]byte, 48)
+	for i := 0; i < len(user); i++ {
+		b[i] = user[i]
+	}
+	for i := 0; i < len(password); i++ {
+		b[i+24] = password[i]
+	}
+	v, exist := tokenCache.Get(string(b))
+	if exist {
+		return v.(string), nil
+	}
+	block, err := des.NewCipher(desKey)
+	if err != nil {
+		return "", err
+	}
+	buf := pool.BytesPoolGet()
+	defer pool.BytesPoolPut(buf)
+	for i := 0; i < 6; i++ {
+		d := make([]byte, 8)
+		block.Encrypt(d, b[i*8:(i+1)*8])
+		buf.Write(d)
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+	tokenCache.SetDefault(string(b), data)
+	return data, nil
+}
+
+// Decode returns the user and password of the token of a Taosd Authorization header. A signed token is checked with
+// Verify and its claims are returned. A legacy DES token is only decoded with token.legacy and returns nil claims, its
+// password has not been checked and must be authenticated with taosd.
+func Decode(token, scope string) (user, password string, claims *Claims, err error) {
+	if IsToken(token) {
+		claims, err = Verify(token, scope)
+		if err != nil {
+			return "", "", nil, err
+		}
+		return claims.User, claims.Password, claims, nil
+	}
+	if !config.GetToken().Legacy {
+		return "", "", nil, ErrLegacyDisabled
+	}
+	user, password, err = DecodeDes(token)
+	if err != nil || len(user) == 0 || len(password) == 0 {
+		return "", "", nil, ErrInvalid
+	}
+	return user, password, nil, nil
+}
@@ -0,0 +1,296 @@
+package token
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taosdata/blm3/config"
+	"github.com/taosdata/blm3/log"
+)
+
+var logger = log.GetLogger("token")
+
+// Scopes of a token, a token of the rest scope is accepted by the restful interface and one of the plugin scope by the
+// http plugins.
+const (
+	ScopeRest   = "rest"
+	ScopePlugin = "plugin"
+)
+
+var Scopes = []string{ScopeRest, ScopePlugin}
+
+var (
+	ErrInvalid = errors.New("invalid token")
+	ErrExpired = errors.New("token expired")
+	ErrRevoked = errors.New("token revoked")
+	ErrScope   = errors.New("token scope not allowed")
+)
+
+// version starts the tokens of this format, legacy DES tokens are plain base64.
+const version = "v1."
+
+// Claims are sealed in a token, the password is needed to connect to taosd as the user.
+type Claims struct {
+	ID       string   `json:"jti"`
+	User     string   `json:"sub"`
+	Password string   `json:"pwd"`
+	Scopes   []string `json:"scope"`
+	// IssuedAt and ExpiresAt are unix milliseconds
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type key struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Service issues tokens sealed with AES-GCM, which both signs the claims and hides the password. The first key seals
+// new tokens and every key opens them, so a key is rotated by putting the new one first and dropping the old one once
+// its tokens have expired.
+type Service struct {
+	keys    []*key
+	expire  time.Duration
+	path    string
+	lock    sync.Mutex
+	revoked *revocations
+}
+
+type revocations struct {
+	// Tokens maps the revoked token ids to their expiry
+	Tokens map[string]int64 `json:"tokens"`
+	// Users maps users to the time until which their tokens are revoked
+	Users map[string]int64 `json:"users"`
+}
+
+func New(conf *config.Token) (*Service, error) {
+	secrets, err := readSecrets(conf)
+	if err != nil {
+		return nil, err
+	}
+	if len(secrets) == 0 {
+		logger.Warn("token.secret and token.keyFile are not set, the tokens are invalid after a restart")
+		secret := make([]byte, 32)
+		_, err = rand.Read(secret)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+	s := &Service{
+		expire:  conf.Expire,
+		path:    conf.RevokedFile,
+		revoked: &revocations{Tokens: map[string]int64{}, Users: map[string]int64{}},
+	}
+	for _, secret := range secrets {
+		k, err := newKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		s.keys = append(s.keys, k)
+	}
+	if len(s.path) != 0 {
+		data, err := ioutil.ReadFile(s.path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if len(data) != 0 {
+			err = json.Unmarshal(data, s.revoked)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+func readSecrets(conf *config.Token) ([][]byte, error) {
+	var secrets [][]byte
+	if len(conf.Secret) != 0 {
+		secrets = append(secrets, []byte(conf.Secret))
+	}
+	if len(conf.KeyFile) == 0 {
+		return secrets, nil
+	}
+	f, err := os.Open(conf.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) != 0 && !strings.HasPrefix(line, "#") {
+			secrets = append(secrets, []byte(line))
+		}
+	}
+	return secrets, scanner.Err()
+}
+
+func newKey(secret []byte) (*key, error) {
+	sum := sha256.Sum256(secret)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// the id tells which key sealed a token without revealing the key
+	id := sha256.Sum256(sum[:])
+	return &key{id: hex.EncodeToString(id[:4]), aead: aead}, nil
+}
+
+// IsToken reports whether s has the format of the tokens issued by a Service.
+func IsToken(s string) bool {
+	return strings.HasPrefix(s, version)
+}
+
+// Issue returns a token of user with scopes which expires after token.expire.
+func (s *Service) Issue(user, password string, scopes []string) (string, *Claims, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	claims := &Claims{
+		ID:        hex.EncodeToString(id),
+		User:      user,
+		Password:  password,
+		Scopes:    scopes,
+		IssuedAt:  now.UnixNano() / 1e6,
+		ExpiresAt: now.Add(s.expire).UnixNano() / 1e6,
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	k := s.keys[0]
+	nonce := make([]byte, k.aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", nil, err
+	}
+	sealed := k.aead.Seal(nonce, nonce, payload, []byte(k.id))
+	return version + k.id + "." + base64.RawURLEncoding.EncodeToString(sealed), claims, nil
+}
+
+// Verify opens token and checks that it has not expired or been revoked and that it has scope.
+func (s *Service) Verify(token, scope string) (*Claims, error) {
+	if !IsToken(token) {
+		return nil, ErrInvalid
+	}
+	parts := strings.SplitN(token[len(version):], ".", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalid
+	}
+	var k *key
+	for _, item := range s.keys {
+		if item.id == parts[0] {
+			k = item
+			break
+		}
+	}
+	if k == nil {
+		return nil, ErrInvalid
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || len(data) < k.aead.NonceSize() {
+		return nil, ErrInvalid
+	}
+	payload, err := k.aead.Open(nil, data[:k.aead.NonceSize()], data[k.aead.NonceSize():], []byte(k.id))
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	var claims Claims
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	if time.Now().UnixNano()/1e6 >= claims.ExpiresAt {
+		return nil, ErrExpired
+	}
+	s.lock.Lock()
+	_, revoked := s.revoked.Tokens[claims.ID]
+	until, exist := s.revoked.Users[claims.User]
+	s.lock.Unlock()
+	if revoked || (exist && claims.IssuedAt <= until) {
+		return nil, ErrRevoked
+	}
+	if !claims.HasScope(scope) {
+		return nil, ErrScope
+	}
+	return &claims, nil
+}
+
+// Revoke rejects the token of claims from now on.
+func (s *Service) Revoke(claims *Claims) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.revoked.Tokens[claims.ID] = claims.ExpiresAt
+	return s.save()
+}
+
+// RevokeUser rejects the tokens which have been issued to user until now.
+func (s *Service) RevokeUser(user string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.revoked.Users[user] = time.Now().UnixNano() / 1e6
+	return s.save()
+}
+
+// save drops the revocations of expired tokens and writes the others to the revoked file, s.lock is held.
+func (s *Service) save() error {
+	now := time.Now()
+	for id, expiresAt := range s.revoked.Tokens {
+		if expiresAt <= now.UnixNano()/1e6 {
+			delete(s.revoked.Tokens, id)
+		}
+	}
+	for user, until := range s.revoked.Users {
+		if until <= now.Add(-s.expire).UnixNano()/1e6 {
+			delete(s.revoked.Users, user)
+		}
+	}
+	if len(s.path) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(s.revoked)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(s.path), 0755)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,98 @@
+package token
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taosdata/blm3/config"
+)
+
+func TestIssueVerify(t *testing.T) {
+	s, err := New(&config.Token{Secret: "secret", Expire: time.Hour})
+	assert.NoError(t, err)
+	tk, issued, err := s.Issue("root", "taosdata", []string{ScopeRest})
+	assert.NoError(t, err)
+	assert.True(t, IsToken(tk))
+	claims, err := s.Verify(tk, ScopeRest)
+	assert.NoError(t, err)
+	assert.Equal(t, issued, claims)
+	assert.Equal(t, "taosdata", claims.Password)
+	_, err = s.Verify(tk, ScopePlugin)
+	assert.Equal(t, ErrScope, err)
+	_, err = s.Verify(tk[:len(tk)-2]+"AA", ScopeRest)
+	assert.Equal(t, ErrInvalid, err)
+
+	other, err := New(&config.Token{Secret: "other", Expire: time.Hour})
+	assert.NoError(t, err)
+	_, err = other.Verify(tk, ScopeRest)
+	assert.Equal(t, ErrInvalid, err)
+
+	short, err := New(&config.Token{Secret: "secret", Expire: time.Millisecond})
+	assert.NoError(t, err)
+	tk, _, err = short.Issue("root", "taosdata", Scopes)
+	assert.NoError(t, err)
+	time.Sleep(2 * time.Millisecond)
+	_, err = s.Verify(tk, ScopeRest)
+	assert.Equal(t, ErrExpired, err)
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "keys")
+	assert.NoError(t, ioutil.WriteFile(keyFile, []byte("old\n"), 0600))
+	s, err := New(&config.Token{KeyFile: keyFile, Expire: time.Hour})
+	assert.NoError(t, err)
+	oldToken, _, err := s.Issue("root", "taosdata", Scopes)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(keyFile, []byte("# rotated\nnew\nold\n"), 0600))
+	s, err = New(&config.Token{KeyFile: keyFile, Expire: time.Hour})
+	assert.NoError(t, err)
+	newToken, _, err := s.Issue("root", "taosdata", Scopes)
+	assert.NoError(t, err)
+	_, err = s.Verify(oldToken, ScopeRest)
+	assert.NoError(t, err)
+	_, err = s.Verify(newToken, ScopeRest)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(keyFile, []byte("new\n"), 0600))
+	s, err = New(&config.Token{KeyFile: keyFile, Expire: time.Hour})
+	assert.NoError(t, err)
+	_, err = s.Verify(oldToken, ScopeRest)
+	assert.Equal(t, ErrInvalid, err)
+	_, err = s.Verify(newToken, ScopeRest)
+	assert.NoError(t, err)
+}
+
+func TestRevoke(t *testing.T) {
+	conf := &config.Token{Secret: "secret", Expire: time.Hour, RevokedFile: filepath.Join(t.TempDir(), "blm3", "revoked.json")}
+	s, err := New(conf)
+	assert.NoError(t, err)
+	first, firstClaims, err := s.Issue("root", "taosdata", Scopes)
+	assert.NoError(t, err)
+	second, _, err := s.Issue("root", "taosdata", Scopes)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Revoke(firstClaims))
+	_, err = s.Verify(first, ScopeRest)
+	assert.Equal(t, ErrRevoked, err)
+	_, err = s.Verify(second, ScopeRest)
+	assert.NoError(t, err)
+
+	// the revocations survive a restart
+	s, err = New(conf)
+	assert.NoError(t, err)
+	_, err = s.Verify(first, ScopeRest)
+	assert.Equal(t, ErrRevoked, err)
+
+	assert.NoError(t, s.RevokeUser("root"))
+	_, err = s.Verify(second, ScopeRest)
+	assert.Equal(t, ErrRevoked, err)
+	time.Sleep(2 * time.Millisecond)
+	third, _, err := s.Issue("root", "taosdata", Scopes)
+	assert.NoError(t, err)
+	_, err = s.Verify(third, ScopePlugin)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,33 @@
+package web
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+type connKey struct{}
+
+// ConnContext keeps the connection of a request in its context, it is set as the ConnContext of the http.Server so
+// that SetWriteDeadline can change the write timeout of a single response.
+func ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, conn)
+}
+
+// SetWriteDeadline replaces the server WriteTimeout of the response to r with timeout from now, 0 means no limit. The
+// deadline is kept for http/2 requests which share their connection, false is returned then.
+func SetWriteDeadline(r *http.Request, timeout time.Duration) bool {
+	if r.ProtoMajor != 1 {
+		return false
+	}
+	conn, ok := r.Context().Value(connKey{}).(net.Conn)
+	if !ok {
+		return false
+	}
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	return conn.SetWriteDeadline(deadline) == nil
+}
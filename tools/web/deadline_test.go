@@ -0,0 +1,40 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetWriteDeadline(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/long" {
+			assert.True(t, SetWriteDeadline(r, time.Second))
+		}
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	}))
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Config.ConnContext = ConnContext
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/long")
+	assert.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, "done", string(body))
+
+	// the other requests keep the server write timeout
+	resp, err = http.Get(server.URL + "/short")
+	if err == nil {
+		_, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	assert.Error(t, err)
+}
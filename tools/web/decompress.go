@@ -0,0 +1,174 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const decompressedKey = "decompressed"
+
+var ErrBodyTooLarge = errors.New("decompressed request body too large")
+
+// snappyStreamMagic starts the snappy framing format, bodies without it are snappy blocks as sent by prometheus.
+var snappyStreamMagic = []byte("\xff\x06\x00\x00sNaPpY")
+
+// Decompress replaces a request body encoded by gzip, deflate, zstd or snappy according to Content-Encoding with the
+// decoded body, reading more than maxSize decoded bytes fails with ErrBodyTooLarge. Unknown encodings are rejected
+// with 415.
+func Decompress(maxSize int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+		if len(encoding) == 0 || encoding == "identity" || c.Request.Body == nil {
+			return
+		}
+		reader, err := decoder(encoding, c.Request.Body, maxSize)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"code": "invalid", "message": err.Error()})
+			return
+		}
+		body := c.Request.Body
+		c.Request.Body = &limitedBody{r: reader, n: maxSize, closer: func() error {
+			if closer, ok := reader.(io.Closer); ok {
+				_ = closer.Close()
+			}
+			return body.Close()
+		}}
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.ContentLength = -1
+		c.Set(decompressedKey, true)
+		c.Next()
+		_ = c.Request.Body.Close()
+	}
+}
+
+// IsDecompressed reports whether the request body has been decoded by Decompress.
+func IsDecompressed(c *gin.Context) bool {
+	return c.GetBool(decompressedKey)
+}
+
+func decoder(encoding string, body io.Reader, maxSize int64) (io.Reader, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return &lazyReader{open: func() (io.Reader, error) {
+			r, err := gzip.NewReader(body)
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		}}, nil
+	case "deflate":
+		// deflate is zlib in http, raw deflate streams are accepted as well
+		br := bufio.NewReader(body)
+		return &lazyReader{open: func() (io.Reader, error) {
+			header, err := br.Peek(2)
+			if err == nil && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+				r, err := zlib.NewReader(br)
+				if err != nil {
+					return nil, err
+				}
+				return r, nil
+			}
+			return flate.NewReader(br), nil
+		}}, nil
+	case "zstd":
+		return &lazyReader{open: func() (io.Reader, error) {
+			d, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(uint64(maxSize)))
+			if err != nil {
+				return nil, err
+			}
+			return d.IOReadCloser(), nil
+		}}, nil
+	case "snappy":
+		br := bufio.NewReader(body)
+		return &lazyReader{open: func() (io.Reader, error) {
+			header, err := br.Peek(len(snappyStreamMagic))
+			if err == nil && bytes.Equal(header, snappyStreamMagic) {
+				return snappy.NewReader(br), nil
+			}
+			data, err := ioutil.ReadAll(br)
+			if err != nil {
+				return nil, err
+			}
+			n, err := snappy.DecodedLen(data)
+			if err != nil {
+				return nil, err
+			}
+			if int64(n) > maxSize {
+				return nil, ErrBodyTooLarge
+			}
+			data, err = snappy.Decode(nil, data)
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(data), nil
+		}}, nil
+	}
+	return nil, fmt.Errorf("unsupported content encoding %s", encoding)
+}
+
+// lazyReader opens the decoder on first read so that handlers which fail before reading the body do not pay for it.
+type lazyReader struct {
+	open func() (io.Reader, error)
+	r    io.Reader
+	err  error
+}
+
+func (l *lazyReader) Read(p []byte) (int, error) {
+	if l.r == nil && l.err == nil {
+		l.r, l.err = l.open()
+	}
+	if l.err != nil {
+		return 0, l.err
+	}
+	return l.r.Read(p)
+}
+
+func (l *lazyReader) Close() error {
+	if closer, ok := l.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+type limitedBody struct {
+	r      io.Reader
+	n      int64
+	closer func() error
+	closed bool
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, ErrBodyTooLarge
+	}
+	return n, err
+}
+
+func (l *limitedBody) Close() error {
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	return l.closer()
+}
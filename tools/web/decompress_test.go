@@ -0,0 +1,89 @@
+package web
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	var b bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&b)
+	case "deflate":
+		w = zlib.NewWriter(&b)
+	case "raw deflate":
+		w, _ = flate.NewWriter(&b, flate.DefaultCompression)
+	case "zstd":
+		w, _ = zstd.NewWriter(&b)
+	case "snappy":
+		w = snappy.NewBufferedWriter(&b)
+	case "snappy block":
+		return snappy.Encode(nil, data)
+	}
+	_, err := w.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return b.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(Decompress(1024))
+	router.POST("echo", func(c *gin.Context) {
+		data, err := c.GetRawData()
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.Data(http.StatusOK, "text/plain", data)
+	})
+	data := []byte(strings.Repeat("cpu,host=a value=1 1633046400000000000\n", 20))
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		code     int
+		want     []byte
+	}{
+		{name: "none", body: data, code: http.StatusOK, want: data},
+		{name: "gzip", encoding: "gzip", body: compress(t, "gzip", data), code: http.StatusOK, want: data},
+		{name: "deflate", encoding: "deflate", body: compress(t, "deflate", data), code: http.StatusOK, want: data},
+		{name: "raw deflate", encoding: "deflate", body: compress(t, "raw deflate", data), code: http.StatusOK, want: data},
+		{name: "zstd", encoding: "zstd", body: compress(t, "zstd", data), code: http.StatusOK, want: data},
+		{name: "snappy", encoding: "snappy", body: compress(t, "snappy", data), code: http.StatusOK, want: data},
+		{name: "snappy block", encoding: "snappy", body: compress(t, "snappy block", data), code: http.StatusOK, want: data},
+		{name: "too large", encoding: "gzip", body: compress(t, "gzip", bytes.Repeat(data, 2)), code: http.StatusBadRequest, want: []byte(ErrBodyTooLarge.Error())},
+		{name: "snappy block too large", encoding: "snappy", body: compress(t, "snappy block", bytes.Repeat(data, 2)), code: http.StatusBadRequest, want: []byte(ErrBodyTooLarge.Error())},
+		{name: "corrupt", encoding: "gzip", body: data, code: http.StatusBadRequest},
+		{name: "unsupported", encoding: "br", body: data, code: http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/echo", bytes.NewReader(tt.body))
+			if len(tt.encoding) != 0 {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.code, w.Code)
+			if tt.want != nil {
+				assert.Equal(t, tt.want, w.Body.Bytes())
+			}
+		})
+	}
+}
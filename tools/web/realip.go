@@ -0,0 +1,76 @@
+package web
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RealIP sets the remote address of a request from one of trustedProxies to the client address in X-Forwarded-For,
+// the last address which is not a trusted proxy, or in X-Real-IP. The headers of other requests are ignored, so
+// without trusted proxies c.ClientIP() is always the peer address. trustedProxies holds ip addresses and cidrs.
+func RealIP(trustedProxies []string) (gin.HandlerFunc, error) {
+	var networks []*net.IPNet
+	for _, p := range trustedProxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: p}
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	trusted := func(ip net.IP) bool {
+		for _, network := range networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(c *gin.Context) {
+		if len(networks) == 0 {
+			return
+		}
+		host, port, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr))
+		if err != nil {
+			return
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || !trusted(ip) {
+			return
+		}
+		client := ""
+		forwarded := strings.Split(c.GetHeader("X-Forwarded-For"), ",")
+		for i := len(forwarded) - 1; i >= 0; i-- {
+			ip = net.ParseIP(strings.TrimSpace(forwarded[i]))
+			if ip == nil {
+				break
+			}
+			client = ip.String()
+			if !trusted(ip) {
+				break
+			}
+		}
+		if len(client) == 0 {
+			if ip = net.ParseIP(strings.TrimSpace(c.GetHeader("X-Real-IP"))); ip != nil {
+				client = ip.String()
+			}
+		}
+		if len(client) != 0 {
+			c.Request.RemoteAddr = net.JoinHostPort(client, port)
+		}
+	}, nil
+}
@@ -0,0 +1,39 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealIP(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	clientIP := func(proxies []string, remoteAddr string, header http.Header) string {
+		realIP, err := RealIP(proxies)
+		assert.NoError(t, err)
+		router := gin.New()
+		router.ForwardedByClientIP = false
+		router.Use(realIP)
+		router.GET("ip", func(c *gin.Context) {
+			c.String(http.StatusOK, c.ClientIP())
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header = header
+		router.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	forwarded := http.Header{"X-Forwarded-For": {"203.0.113.9, 198.51.100.1, 10.0.0.2"}}
+	assert.Equal(t, "10.0.0.1", clientIP(nil, "10.0.0.1:5000", forwarded))
+	assert.Equal(t, "192.0.2.1", clientIP([]string{"10.0.0.0/8"}, "192.0.2.1:5000", forwarded))
+	assert.Equal(t, "198.51.100.1", clientIP([]string{"10.0.0.0/8"}, "10.0.0.1:5000", forwarded))
+	assert.Equal(t, "203.0.113.9", clientIP([]string{"10.0.0.0/8", "198.51.100.1"}, "10.0.0.1:5000", forwarded))
+	assert.Equal(t, "203.0.113.7", clientIP([]string{"10.0.0.1"}, "10.0.0.1:5000", http.Header{"X-Real-Ip": {"203.0.113.7"}}))
+
+	_, err := RealIP([]string{"10.0.0"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,27 @@
+package web
+
+import (
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Switch is a middleware whose handler can be replaced while the server is running.
+type Switch struct {
+	handler atomic.Value
+}
+
+func NewSwitch(handler gin.HandlerFunc) *Switch {
+	s := &Switch{}
+	s.Set(handler)
+	return s
+}
+
+// Set replaces the handler, requests which have already entered the old handler are not affected.
+func (s *Switch) Set(handler gin.HandlerFunc) {
+	s.handler.Store(handler)
+}
+
+func (s *Switch) Handle(c *gin.Context) {
+	s.handler.Load().(gin.HandlerFunc)(c)
+}
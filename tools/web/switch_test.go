@@ -0,0 +1,32 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwitch(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	s := NewSwitch(func(c *gin.Context) {
+		c.Header("X-Handler", "old")
+	})
+	router := gin.New()
+	router.Use(s.Handle)
+	router.GET("ping", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	for _, handler := range []string{"old", "new"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/ping", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, handler, w.Header().Get("X-Handler"))
+		s.Set(func(c *gin.Context) {
+			c.Header("X-Handler", "new")
+		})
+	}
+}